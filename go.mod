@@ -3,3 +3,9 @@ module webfactory
 go 1.23.4
 
 require github.com/LixenWraith/logger v1.2.1
+
+require github.com/yuin/goldmark v1.8.6
+
+require github.com/andybalholm/brotli v1.2.0
+
+require gopkg.in/yaml.v3 v3.0.1
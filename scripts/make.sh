@@ -140,10 +140,17 @@ if [ "${CGO_ENABLED:-1}" != "0" ]; then
   export CGO_ENABLED=0
 fi
 
+# Version metadata stamped into the binary
+VERSION_PKG="webfactory/src/internal/version"
+BUILD_VERSION="$(git describe --tags --always --dirty 2>/dev/null || echo dev)"
+BUILD_COMMIT="$(git rev-parse --short HEAD 2>/dev/null || echo unknown)"
+BUILD_DATE="$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+LDFLAGS="-X ${VERSION_PKG}.Version=${BUILD_VERSION} -X ${VERSION_PKG}.Commit=${BUILD_COMMIT} -X ${VERSION_PKG}.Date=${BUILD_DATE}"
+
 echo "== Compiling for ${TARGET_OS} ${TARGET_ARCH} =="
 export GOOS="$TARGET_OS"
 export GOARCH="$TARGET_ARCH"
-go build -o "$EXEC_PATH" "$SRC_PATH"
+go build -ldflags "$LDFLAGS" -o "$EXEC_PATH" "$SRC_PATH"
 
 if [ $? -eq 0 ]; then
   echo "++ Compilation successful. ${TARGET_OS} ${TARGET_ARCH} executable created at ${EXEC_PATH}"
@@ -1,59 +1,244 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
-	"webfactory/src/internal/builder"
+	"webfactory/src/internal/config"
+	"webfactory/src/internal/scaffold"
+	"webfactory/src/internal/server"
+	"webfactory/src/internal/version"
+	"webfactory/src/internal/watcher"
+	"webfactory/src/webfactory"
 
 	"github.com/LixenWraith/logger/quick"
 )
 
+// verbosity controls how much informational output the CLI prints
+type verbosity int
+
+const (
+	quiet   verbosity = iota // errors only
+	normal                   // paths and build summaries
+	verbose                  // per-blueprint and per-file progress
+)
+
+// shows reports whether output meant for level is printed at verbosity v
+func (v verbosity) shows(level verbosity) bool {
+	return v >= level
+}
+
+// output is the verbosity selected on the command line
+var output = normal
+
+// printf prints informational output if the selected verbosity shows level
+func printf(level verbosity, format string, args ...any) {
+	if output.shows(level) {
+		fmt.Printf(format, args...)
+	}
+}
+
 type buildConfig struct {
-	sourcePath string
-	targetPath string
-	logPath    string
+	sourcePath  string   // primary source, holding the config file
+	sources     []string // all source roots in priority order, starting with sourcePath
+	targetPath  string
+	library     string
+	logPath     string
+	serve       bool
+	watch       bool
+	port        int
+	generator   bool
+	headTags    bool
+	dedupeCSS   bool
+	cssMap      bool
+	inline      int
+	integrity   bool
+	preload     bool
+	redirects   bool
+	gzip        bool
+	brotli      bool
+	compressMin int
+	fileMode    string
+	dirMode     string
+	dryRun      bool
+	clean       bool
+	env         []string
+	basePath    string
+	baseURL     string
+	errorPage   string
+	failOnWarn  bool
+	strictVars  bool
+	pageCSS     bool
+	symlinks    bool
+	feed        webfactory.Feed
 }
 
 func main() {
-	cfg := processCLI()
+	if len(os.Args) > 1 && os.Args[1] == "new" {
+		runNew(os.Args[2:])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "lint" {
+		cfg := processCLI(os.Args[2:])
+		os.Exit(runLint(cfg))
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "inspect" {
+		cfg := processCLI(os.Args[2:])
+		os.Exit(runInspect(cfg, flag.Args()))
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "graph" {
+		cfg := processCLI(os.Args[2:])
+		os.Exit(runGraph(cfg))
+	}
+
+	cfg := processCLI(os.Args[1:])
 
-	fmt.Println("Source directory: ", cfg.sourcePath)
-	fmt.Println("Target directory: ", cfg.targetPath)
+	printf(normal, "Source directory:  %s\n", strings.Join(cfg.sources, ", "))
+	printf(normal, "Target directory:  %s\n", cfg.targetPath)
 
 	quick.Info("Starting site build", "source path", "target path", cfg.targetPath)
 
-	builder := builder.New(cfg.sourcePath, cfg.targetPath)
+	site := newSite(cfg)
 
-	if err := builder.Build(); err != nil {
+	if cfg.serve {
+		runServer(cfg, site)
+		quick.Shutdown()
+		time.Sleep(300 * time.Millisecond)
+		return
+	}
+
+	if cfg.watch {
+		runWatch(cfg, site)
+		quick.Shutdown()
+		time.Sleep(300 * time.Millisecond)
+		return
+	}
+
+	result, err := site.Build(context.Background())
+	if err != nil {
 		quick.Error("Error building site", "error", err.Error())
+		logPanics(err)
 		fmt.Fprintf(os.Stderr, "Error building site: %v\n", err)
+		quick.Shutdown()
+		time.Sleep(300 * time.Millisecond)
 		os.Exit(1)
 	}
+	printStats(result)
+
+	if cfg.dryRun {
+		printPlanned(result.Planned, result.Removed)
+	} else {
+		printRemoved(result.Removed)
+	}
 
 	quick.Info("Site build completed successfully")
 	quick.Shutdown()
 	time.Sleep(300 * time.Millisecond)
 }
 
-func processCLI() *buildConfig {
+func processCLI(args []string) *buildConfig {
 	cfg := &buildConfig{}
 
 	flag.StringVar(&cfg.targetPath, "t", ".", "Output directory path")
-	flag.StringVar(&cfg.sourcePath, "s", ".", "Source blueprints and components path")
+	flag.StringVar(&cfg.sourcePath, "s", ".", "Source blueprints and components path, or a comma-separated list where earlier paths override later ones")
 	flag.StringVar(&cfg.logPath, "l", "logs", "Log directory path")
-	flag.Parse()
+	flag.StringVar(&cfg.library, "components", "", "Shared component library directory, used for components not found in the source")
+	flag.BoolVar(&cfg.serve, "serve", false, "Serve the target directory and rebuild on source changes")
+	flag.BoolVar(&cfg.watch, "watch", false, "Rebuild on source changes without serving")
+	flag.IntVar(&cfg.port, "port", 8080, "Port for the development server")
+	flag.BoolVar(&cfg.generator, "generator", false, "Stamp a meta generator tag into generated pages")
+	flag.BoolVar(&cfg.headTags, "head", false, "Generate title and meta tags from blueprint front matter")
+	flag.BoolVar(&cfg.dedupeCSS, "dedupe-css", false, "Drop duplicate CSS rules from the merged stylesheet")
+	flag.BoolVar(&cfg.cssMap, "css-map", false, "Write a source map of each page's stylesheet, with -page-css")
+	flag.IntVar(&cfg.inline, "inline", 0, "Embed CSS and JS assets smaller than this many bytes in the page")
+	flag.BoolVar(&cfg.integrity, "sri", false, "Add Subresource Integrity attributes to script tags, and with -page-css stylesheet tags")
+	flag.BoolVar(&cfg.preload, "preload", false, "Emit preload hints for assets of components marked preload")
+	flag.BoolVar(&cfg.redirects, "redirect-duplicates", false, "Write pages identical to another page as redirects to it")
+	flag.BoolVar(&cfg.gzip, "gzip", false, "Also write gzip-compressed copies of text outputs")
+	flag.BoolVar(&cfg.brotli, "brotli", false, "Also write brotli-compressed copies of text outputs")
+	flag.IntVar(&cfg.compressMin, "compress-min", 256, "Skip pre-compressing files smaller than this many bytes")
+	flag.StringVar(&cfg.fileMode, "file-mode", "0644", "Octal permissions of output files")
+	flag.StringVar(&cfg.dirMode, "dir-mode", "0755", "Octal permissions of created output directories")
+	flag.BoolVar(&cfg.dryRun, "dry-run", false, "Report the files a build would write without writing them")
+	flag.BoolVar(&cfg.clean, "clean", false, "Remove files in the output directory that the build did not write")
+	flag.StringVar(&cfg.basePath, "base", "", "URL path the site is served under, such as /docs/, for absolute asset URLs")
+	flag.StringVar(&cfg.baseURL, "base-url", "", "Absolute site URL, such as https://example.com, that root-relative links in pages are rewritten under")
+	flag.StringVar(&cfg.errorPage, "error-page", "", "Blueprint, relative to blueprints/, built as the 404.html not-found page instead of 404.blueprint")
+	flag.BoolVar(&cfg.failOnWarn, "fail-on-warning", false, "Fail the build or lint when templates report warnings, such as undefined variables")
+	flag.BoolVar(&cfg.strictVars, "strict-vars", false, "Fail pages that use a variable without a value or default instead of warning")
+	flag.BoolVar(&cfg.pageCSS, "page-css", false, "Give each page its own stylesheet, css/<page>.css, with only its components' styles")
+	flag.BoolVar(&cfg.symlinks, "follow-symlinks", false, "Follow symbolic links inside the source directories and library")
+	env := flag.String("env", "", "Comma-separated environment variables that templates may read as {{env.NAME}}")
+	showVersion := flag.Bool("version", false, "Print version information and exit")
+	beVerbose := flag.Bool("v", false, "Print progress for each blueprint and written file")
+	beQuiet := flag.Bool("q", false, "Print errors only")
+	flag.CommandLine.Parse(args)
+
+	switch {
+	case *beVerbose && *beQuiet:
+		fmt.Fprintln(os.Stderr, "-v and -q cannot be used together")
+		os.Exit(1)
+	case *beVerbose:
+		output = verbose
+	case *beQuiet:
+		output = quiet
+	}
 
-	// Clean and make absolute paths
+	if *showVersion {
+		fmt.Println(version.String())
+		os.Exit(0)
+	}
+
+	for _, name := range strings.Split(*env, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			cfg.env = append(cfg.env, name)
+		}
+	}
+
+	// Clean and make absolute paths, verifying each source directory exists
 	var err error
-	cfg.sourcePath, err = filepath.Abs(filepath.Clean(cfg.sourcePath))
+	for _, source := range strings.Split(cfg.sourcePath, ",") {
+		source, err = filepath.Abs(filepath.Clean(strings.TrimSpace(source)))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error processing source path: %v\n", err)
+			os.Exit(1)
+		}
+
+		if _, err := os.Stat(source); os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "Source directory does not exist: %s\n", source)
+			os.Exit(1)
+		}
+		cfg.sources = append(cfg.sources, source)
+	}
+	cfg.sourcePath = cfg.sources[0]
+
+	// Config file values apply only where no flag was given
+	file, warnings, err := config.Load(cfg.sourcePath)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error processing source path: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error loading config file: %v\n", err)
 		os.Exit(1)
 	}
+	for _, w := range warnings {
+		quick.Warn("Config file warning", "warning", w)
+		if output.shows(normal) {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+		}
+	}
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	applyConfig(cfg, file, set)
 
 	cfg.targetPath, err = filepath.Abs(filepath.Clean(cfg.targetPath))
 	if err != nil {
@@ -66,12 +251,477 @@ func processCLI() *buildConfig {
 		fmt.Fprintf(os.Stderr, "Error processing log path: %v\n", err)
 		os.Exit(1)
 	}
-
-	// Verify source directory exists
-	if _, err := os.Stat(cfg.sourcePath); os.IsNotExist(err) {
-		fmt.Fprintf(os.Stderr, "Source directory does not exist: %s\n", cfg.sourcePath)
+	if rel, err := filepath.Rel(cfg.targetPath, cfg.logPath); cfg.clean && err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		fmt.Fprintf(os.Stderr, "Log directory %s is inside the output directory, -clean would remove its logs\n", cfg.logPath)
 		os.Exit(1)
 	}
 
+	if cfg.library != "" {
+		cfg.library, err = filepath.Abs(filepath.Clean(cfg.library))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error processing component library path: %v\n", err)
+			os.Exit(1)
+		}
+		if info, err := os.Stat(cfg.library); err != nil || !info.IsDir() {
+			fmt.Fprintf(os.Stderr, "Component library directory does not exist: %s\n", cfg.library)
+			os.Exit(1)
+		}
+	}
+
 	return cfg
+}
+
+// applyConfig copies config file values into cfg for options whose flag was not set
+// Relative paths in the config file are resolved against the source directory
+func applyConfig(cfg *buildConfig, file *config.File, set map[string]bool) {
+	resolve := func(path string) string {
+		if filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(cfg.sourcePath, path)
+	}
+
+	if file.Target != nil && !set["t"] {
+		cfg.targetPath = resolve(*file.Target)
+	}
+	if file.Log != nil && !set["l"] {
+		cfg.logPath = resolve(*file.Log)
+	}
+	if file.Components != nil && !set["components"] {
+		cfg.library = resolve(*file.Components)
+	}
+	if file.Feed != nil {
+		cfg.feed = webfactory.Feed{
+			Collection:  file.Feed.Collection,
+			Path:        file.Feed.Path,
+			Title:       file.Feed.Title,
+			Description: file.Feed.Description,
+			BaseURL:     file.Feed.URL,
+		}
+	}
+	if file.Serve != nil && !set["serve"] {
+		cfg.serve = *file.Serve
+	}
+	if file.Watch != nil && !set["watch"] {
+		cfg.watch = *file.Watch
+	}
+	if file.Port != nil && !set["port"] {
+		cfg.port = *file.Port
+	}
+	if file.Generator != nil && !set["generator"] {
+		cfg.generator = *file.Generator
+	}
+	if file.Head != nil && !set["head"] {
+		cfg.headTags = *file.Head
+	}
+	if file.DedupeCSS != nil && !set["dedupe-css"] {
+		cfg.dedupeCSS = *file.DedupeCSS
+	}
+	if file.CSSMap != nil && !set["css-map"] {
+		cfg.cssMap = *file.CSSMap
+	}
+	if file.Inline != nil && !set["inline"] {
+		cfg.inline = *file.Inline
+	}
+	if file.SRI != nil && !set["sri"] {
+		cfg.integrity = *file.SRI
+	}
+	if file.Preload != nil && !set["preload"] {
+		cfg.preload = *file.Preload
+	}
+	if file.RedirectDuplicates != nil && !set["redirect-duplicates"] {
+		cfg.redirects = *file.RedirectDuplicates
+	}
+	if file.Gzip != nil && !set["gzip"] {
+		cfg.gzip = *file.Gzip
+	}
+	if file.Brotli != nil && !set["brotli"] {
+		cfg.brotli = *file.Brotli
+	}
+	if file.CompressMin != nil && !set["compress-min"] {
+		cfg.compressMin = *file.CompressMin
+	}
+	if file.FileMode != nil && !set["file-mode"] {
+		cfg.fileMode = *file.FileMode
+	}
+	if file.DirMode != nil && !set["dir-mode"] {
+		cfg.dirMode = *file.DirMode
+	}
+	if file.Env != nil && !set["env"] {
+		cfg.env = *file.Env
+	}
+	if file.Base != nil && !set["base"] {
+		cfg.basePath = *file.Base
+	}
+	if file.BaseURL != nil && !set["base-url"] {
+		cfg.baseURL = *file.BaseURL
+	}
+	if file.ErrorPage != nil && !set["error-page"] {
+		cfg.errorPage = *file.ErrorPage
+	}
+	if file.FailOnWarning != nil && !set["fail-on-warning"] {
+		cfg.failOnWarn = *file.FailOnWarning
+	}
+	if file.StrictVars != nil && !set["strict-vars"] {
+		cfg.strictVars = *file.StrictVars
+	}
+	if file.PageCSS != nil && !set["page-css"] {
+		cfg.pageCSS = *file.PageCSS
+	}
+	if file.FollowSymlinks != nil && !set["follow-symlinks"] {
+		cfg.symlinks = *file.FollowSymlinks
+	}
+	if file.Clean != nil && !set["clean"] {
+		cfg.clean = *file.Clean
+	}
+}
+
+// newSite creates the site for cfg, exiting on invalid options
+func newSite(cfg *buildConfig) *webfactory.Site {
+	opts, err := siteOptions(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid output options: %v\n", err)
+		os.Exit(1)
+	}
+
+	site, err := webfactory.New(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return site
+}
+
+// runLint checks the source without building, printing a report and returning the exit code
+func runLint(cfg *buildConfig) int {
+	report, err := newSite(cfg).Lint(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error linting site: %v\n", err)
+		return 1
+	}
+
+	for _, w := range report.Warnings {
+		if output.shows(normal) {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+		}
+	}
+	for _, e := range report.Errors {
+		fmt.Fprintf(os.Stderr, "error: %s\n", e)
+	}
+
+	if !report.OK() || cfg.failOnWarn && len(report.Warnings) > 0 {
+		printf(quiet, "Lint failed: %d errors, %d warnings\n", len(report.Errors), len(report.Warnings))
+		return 1
+	}
+	printf(normal, "Lint passed with %d warnings\n", len(report.Warnings))
+	return 0
+}
+
+// runInspect prints the parsed block tree of each named blueprint
+func runInspect(cfg *buildConfig, paths []string) int {
+	if len(paths) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: webfactory inspect [flags] <blueprint>...")
+		return 2
+	}
+
+	site := newSite(cfg)
+	for i, path := range paths {
+		dump, err := site.Inspect(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Print(dump)
+	}
+	return 0
+}
+
+// runGraph writes the site's page and component graph to stdout in the DOT language
+func runGraph(cfg *buildConfig) int {
+	graph, err := newSite(cfg).Graph(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading site: %v\n", err)
+		return 1
+	}
+	os.Stdout.Write(graph.DOT())
+	return 0
+}
+
+// runNew handles "new component <path>" and "new blueprint <name>", creating starter files in the source
+func runNew(args []string) {
+	flags := flag.NewFlagSet("new", flag.ExitOnError)
+	source := flags.String("s", ".", "Source directory to create the files in")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: webfactory new [-s source] component <path> | blueprint <name>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	var created []string
+	var err error
+	switch kind, name := flags.Arg(0), flags.Arg(1); kind {
+	case "component":
+		created, err = scaffold.Component(*source, name)
+	case "blueprint":
+		var path string
+		path, err = scaffold.Blueprint(*source, name)
+		created = append(created, path)
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	for _, path := range created {
+		fmt.Printf("created %s\n", path)
+	}
+}
+
+// siteOptions builds the site options from cfg, parsing the octal output modes
+func siteOptions(cfg *buildConfig) (webfactory.Options, error) {
+	opts := webfactory.Options{
+		Sources:         cfg.sources,
+		Library:         cfg.library,
+		Target:          cfg.targetPath,
+		Generator:       cfg.generator,
+		HeadTags:        cfg.headTags,
+		DedupeCSS:       cfg.dedupeCSS,
+		CSSMap:          cfg.cssMap,
+		InlineThreshold: cfg.inline,
+		Integrity:       cfg.integrity,
+		Preload:         cfg.preload,
+		Redirects:       cfg.redirects,
+		Gzip:            cfg.gzip,
+		Brotli:          cfg.brotli,
+		CompressMin:     cfg.compressMin,
+		DryRun:          cfg.dryRun,
+		Clean:           cfg.clean,
+		Env:             cfg.env,
+		BasePath:        cfg.basePath,
+		BaseURL:         cfg.baseURL,
+		ErrorPage:       cfg.errorPage,
+		FailOnWarning:   cfg.failOnWarn,
+		StrictVars:      cfg.strictVars,
+		PageCSS:         cfg.pageCSS,
+		FollowSymlinks:  cfg.symlinks,
+		Feed:            cfg.feed,
+		Progress: func(format string, args ...any) {
+			printf(verbose, format+"\n", args...)
+		},
+	}
+
+	fileMode, err := strconv.ParseUint(cfg.fileMode, 8, 32)
+	if err != nil {
+		return opts, fmt.Errorf("file mode %q is not an octal number", cfg.fileMode)
+	}
+	dirMode, err := strconv.ParseUint(cfg.dirMode, 8, 32)
+	if err != nil {
+		return opts, fmt.Errorf("directory mode %q is not an octal number", cfg.dirMode)
+	}
+	opts.FileMode = os.FileMode(fileMode)
+	opts.DirMode = os.FileMode(dirMode)
+
+	return opts, nil
+}
+
+// runServer builds the site, serves it, and rebuilds with browser reload on source changes until interrupted
+func runServer(cfg *buildConfig, site *webfactory.Site) {
+	srv := server.New(cfg.targetPath, cfg.port, cfg.basePath)
+
+	srv.Reload(timedBuild(context.Background(), site))
+	var r rebuilder
+	defer r.stop()
+	stop := watchSource(cfg, func(changes []watcher.Change) {
+		printChanges(changes)
+		r.start(func(ctx context.Context) {
+			if err := timedBuild(ctx, site); !errors.Is(err, context.Canceled) {
+				srv.Reload(err)
+			}
+		})
+	})
+	defer close(stop)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+	printf(normal, "Serving %s at http://localhost%s%s\n", cfg.targetPath, srv.Addr(), srv.Base())
+
+	select {
+	case err := <-errCh:
+		quick.Error("Development server failed", "error", err.Error())
+		fmt.Fprintf(os.Stderr, "Development server failed: %v\n", err)
+	case <-interrupted():
+	}
+}
+
+// runWatch builds the site and rebuilds on source changes until interrupted, never exiting on build failure
+func runWatch(cfg *buildConfig, site *webfactory.Site) {
+	timedBuild(context.Background(), site)
+	var r rebuilder
+	defer r.stop()
+	stop := watchSource(cfg, func(changes []watcher.Change) {
+		printChanges(changes)
+		r.start(func(ctx context.Context) { timedBuild(ctx, site) })
+	})
+	defer close(stop)
+
+	printf(normal, "Watching %s for changes\n", strings.Join(cfg.sources, ", "))
+	<-interrupted()
+}
+
+// watchSource starts watching the source tree in the background, returning a channel that stops it when closed
+func watchSource(cfg *buildConfig, onChange func([]watcher.Change)) chan struct{} {
+	// Output and logs may live inside the source tree; watching them would rebuild forever
+	roots := cfg.sources
+	if cfg.library != "" {
+		roots = append(roots[:len(roots):len(roots)], cfg.library)
+	}
+	w := watcher.New(roots, []string{cfg.targetPath, cfg.logPath}, 250*time.Millisecond, 300*time.Millisecond)
+	stop := make(chan struct{})
+	go w.Run(stop, onChange)
+	return stop
+}
+
+// rebuilder runs builds in the background so the watcher keeps scanning during a rebuild
+// Starting a build cancels the one in flight and waits for it to stop, so builds never overlap.
+type rebuilder struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// start cancels the running build, if any, and runs build in the background
+func (r *rebuilder) start(build func(ctx context.Context)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	r.cancel, r.done = cancel, done
+	go func() {
+		defer close(done)
+		build(ctx)
+	}()
+}
+
+// stop cancels the running build, if any, and waits for it to stop
+func (r *rebuilder) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.wait()
+}
+
+func (r *rebuilder) wait() {
+	if r.cancel != nil {
+		r.cancel()
+		<-r.done
+		r.cancel, r.done = nil, nil
+	}
+}
+
+// timedBuild runs a build, printing its summary or error
+func timedBuild(ctx context.Context, site *webfactory.Site) error {
+	result, err := site.Build(ctx)
+	if errors.Is(err, context.Canceled) {
+		printf(normal, "Build cancelled by new changes\n")
+		return err
+	}
+	if err != nil {
+		quick.Error("Error building site", "error", err.Error())
+		logPanics(err)
+		fmt.Fprintf(os.Stderr, "Error building site: %v\n", err)
+	} else {
+		printStats(result)
+		printRemoved(result.Removed)
+	}
+	return err
+}
+
+// logPanics logs the stack of every blueprint panic recovered during a failed build
+func logPanics(err error) {
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	for _, e := range errs {
+		var panicErr *webfactory.PanicError
+		if errors.As(e, &panicErr) {
+			quick.Error("Recovered panic", "source", panicErr.Source, "panic", fmt.Sprint(panicErr.Value),
+				"stack", string(panicErr.Stack))
+		}
+	}
+}
+
+// printStats prints a one-line build summary, after the build's warnings
+func printStats(stats webfactory.Result) {
+	for _, w := range stats.Warnings {
+		quick.Warn("Template warning", "warning", w)
+		if output.shows(normal) {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+		}
+	}
+
+	quick.Info("Build statistics", "pages", stats.Pages, "css", stats.CSS, "js", stats.JS,
+		"bytes", stats.Bytes, "unchanged", stats.Skipped, "duration", stats.Duration.String())
+	printf(normal, "Built %d pages, %d CSS and %d JS files, %d bytes in %v",
+		stats.Pages, stats.CSS, stats.JS, stats.Bytes, stats.Duration.Round(time.Millisecond))
+	if stats.Skipped > 0 {
+		printf(normal, ", %d files unchanged", stats.Skipped)
+	}
+	printf(normal, "\n")
+}
+
+// printPlanned lists the files a dry run would have written, and those -clean would have removed
+func printPlanned(writes []webfactory.PlannedWrite, removed []string) {
+	for _, w := range writes {
+		action := "create"
+		if w.Overwrite {
+			action = "overwrite"
+		}
+		quick.Info("Dry run", "action", action, "path", w.Path, "bytes", w.Bytes)
+		fmt.Printf("would %s %s (%d bytes)\n", action, w.Path, w.Bytes)
+	}
+	for _, path := range removed {
+		quick.Info("Dry run", "action", "remove", "path", path)
+		fmt.Printf("would remove %s\n", path)
+	}
+	fmt.Printf("Dry run: %d files, nothing written\n", len(writes))
+}
+
+// printRemoved lists the stale files -clean removed from the output directory
+func printRemoved(removed []string) {
+	for _, path := range removed {
+		quick.Info("Removed stale file", "path", path)
+		printf(verbose, "removed %s\n", path)
+	}
+	if len(removed) > 0 {
+		printf(normal, "Removed %d stale files\n", len(removed))
+	}
+}
+
+// printChanges lists the source changes that triggered a rebuild
+func printChanges(changes []watcher.Change) {
+	for _, c := range changes {
+		printf(normal, "%s %s\n", c.Op, c.Path)
+	}
+}
+
+// interrupted returns a channel that receives on the first interrupt signal
+func interrupted() <-chan os.Signal {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	return interrupt
 }
\ No newline at end of file
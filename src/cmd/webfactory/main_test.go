@@ -0,0 +1,89 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"webfactory/src/internal/config"
+)
+
+func TestApplyConfigPrecedence(t *testing.T) {
+	file, _, err := config.Parse([]byte(`{"port": 9000, "gzip": true, "target": "public", "compress-min": 64}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := filepath.FromSlash("/site")
+
+	// Defaults as the flags leave them, with -port and -t given on the command line
+	cfg := &buildConfig{sourcePath: source, targetPath: "out", port: 8081, compressMin: 256}
+	applyConfig(cfg, file, map[string]bool{"port": true, "t": true})
+
+	if cfg.port != 8081 || cfg.targetPath != "out" {
+		t.Errorf("flags overridden by the file: port %d, target %s", cfg.port, cfg.targetPath)
+	}
+	if !cfg.gzip || cfg.compressMin != 64 {
+		t.Errorf("file values not applied over defaults: gzip %v, compress-min %d", cfg.gzip, cfg.compressMin)
+	}
+	if cfg.brotli || cfg.inline != 0 {
+		t.Errorf("defaults of keys absent from the file changed: brotli %v, inline %d", cfg.brotli, cfg.inline)
+	}
+
+	cfg = &buildConfig{sourcePath: source, targetPath: "."}
+	applyConfig(cfg, file, map[string]bool{})
+	if want := filepath.Join(source, "public"); cfg.targetPath != want {
+		t.Errorf("relative target %s, want it resolved against the source as %s", cfg.targetPath, want)
+	}
+}
+
+func TestVerbosityShows(t *testing.T) {
+	tests := []struct {
+		selected verbosity
+		level    verbosity
+		want     bool
+	}{
+		{quiet, quiet, true},
+		{quiet, normal, false},
+		{quiet, verbose, false},
+		{normal, normal, true},
+		{normal, verbose, false},
+		{verbose, normal, true},
+		{verbose, verbose, true},
+	}
+	for _, tt := range tests {
+		if got := tt.selected.shows(tt.level); got != tt.want {
+			t.Errorf("verbosity %d shows level %d = %v, want %v", tt.selected, tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestPrintfGated(t *testing.T) {
+	defer func(v verbosity) { output = v }(output)
+
+	capture := func(selected verbosity) string {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		stdout := os.Stdout
+		os.Stdout = w
+		output = selected
+		printf(normal, "summary\n")
+		printf(verbose, "building %s\n", "index.blueprint")
+		os.Stdout = stdout
+		w.Close()
+		printed, _ := io.ReadAll(r)
+		return string(printed)
+	}
+
+	for selected, want := range map[verbosity]string{
+		quiet:   "",
+		normal:  "summary\n",
+		verbose: "summary\nbuilding index.blueprint\n",
+	} {
+		if got := capture(selected); got != want {
+			t.Errorf("verbosity %d printed %q, want %q", selected, got, want)
+		}
+	}
+}
@@ -3,18 +3,60 @@ package assets
 import (
 	"bytes"
 	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
 	"encoding/hex"
 	"fmt"
+	"maps"
+	"path"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"webfactory/src/internal/component"
 )
 
+// Options controls optional asset processing
+type Options struct {
+	DedupeRules     bool           // Drop duplicate CSS rules from the merged stylesheet
+	InlineThreshold int            // Embed CSS and JS smaller than this many bytes in the page, 0 disables
+	Integrity       bool           // Add Subresource Integrity attributes to linked scripts and a page's own stylesheet
+	Preload         bool           // Emit preload hints for the linked assets of critical components
+	Names           *Names         // Script output names shared by the pages of a build, nil checks within the page only
+	CSSTransforms   []CSSTransform // Applied to the merged stylesheet in order, after deduplication
+	SourceMap       bool           // Write <stylesheet>.css.map mapping the linked stylesheet back to component files, not with DedupeRules
+	Stylesheet      string         // Name of the page's stylesheet in css/ without extension, DefaultStylesheet when empty
+}
+
+// Validate rejects options that contradict each other
+func (o Options) Validate() error {
+	if o.SourceMap && o.DedupeRules {
+		return fmt.Errorf("a CSS source map can't be combined with dropping duplicate CSS rules")
+	}
+	return nil
+}
+
+// DefaultStylesheet is the name of the stylesheet pages link unless given their own
+const DefaultStylesheet = "styles"
+
+// ImageDir is the output directory of component images
+const ImageDir = "img"
+
+// CSSTransform rewrites a page's merged stylesheet, such as to add vendor prefixes
+type CSSTransform func(css []byte) ([]byte, error)
+
 type Manager struct {
-	css     map[string][]byte  // content hash -> content
-	cssKeys []string           // ordered list of css content hashes
-	js      map[string]jsAsset // content hash -> {content, files}
-	jsKeys  []string           // ordered list of js content hashes
+	opts        Options
+	css         map[string][]byte    // content hash -> content
+	cssSources  map[string]cssSource // content hash -> files the content came from
+	cssKeys     []string             // ordered list of css content hashes
+	cssCritical bool                 // a critical component contributed CSS
+	js          map[string]jsAsset   // content hash -> {content, files}
+	jsKeys      []string             // ordered list of js content hashes
+	jsCritical  map[string]bool      // output names of scripts from critical components
+	images      map[string][]byte    // output name -> content of component images
+	merged      []byte               // merged and transformed stylesheet, set by Finalize
+	cssMap      []byte               // source map of the merged stylesheet, set by Finalize
 }
 
 type jsAsset struct {
@@ -22,13 +64,38 @@ type jsAsset struct {
 	files   []string // list of "component-filename.js"
 }
 
-func New() *Manager {
+func New(opts Options) *Manager {
+	if opts.Names == nil {
+		opts.Names = NewNames()
+	}
+
 	return &Manager{
-		css:     make(map[string][]byte),
-		cssKeys: make([]string, 0),
-		js:      make(map[string]jsAsset),
-		jsKeys:  make([]string, 0),
+		opts:       opts,
+		css:        make(map[string][]byte),
+		cssSources: make(map[string]cssSource),
+		cssKeys:    make([]string, 0),
+		js:         make(map[string]jsAsset),
+		jsKeys:     make([]string, 0),
+		jsCritical: make(map[string]bool),
+		images:     make(map[string][]byte),
+	}
+}
+
+// Reset empties the manager for another page with opts, keeping its allocated maps and slices
+func (m *Manager) Reset(opts Options) {
+	if opts.Names == nil {
+		opts.Names = NewNames()
 	}
+	m.opts = opts
+	clear(m.css)
+	clear(m.cssSources)
+	m.cssKeys = m.cssKeys[:0]
+	m.cssCritical = false
+	clear(m.js)
+	m.jsKeys = m.jsKeys[:0]
+	clear(m.jsCritical)
+	clear(m.images)
+	m.merged, m.cssMap = nil, nil
 }
 
 // ProcessComponent handles all assets for a component
@@ -37,20 +104,54 @@ func (m *Manager) ProcessComponent(comp *component.Component) error {
 		return nil
 	}
 
-	// Handle CSS - hash based deduplication with order preservation
+	// Handle CSS - hash based deduplication, ordered by priority then first-seen
 	if len(comp.Styles) > 0 {
-		hash := generateHash(comp.Styles)
+		priority, err := Priority(comp)
+		if err != nil {
+			return err
+		}
+
+		// Shared stylesheets come before the styles importing them, once however many components import them
+		for _, f := range comp.Shared {
+			hash := generateHash(f.CSS)
+			if _, exists := m.css[hash]; !exists {
+				m.css[hash] = f.CSS
+				m.cssSources[hash] = cssSource{files: []component.StyleFile{f}, priority: priority}
+				m.insertCSSKey(hash, priority)
+			}
+		}
+
+		styles := componentCSS(comp)
+		if Scoped(comp) {
+			styles = scopeCSS(styles, ScopeClass(comp.Path))
+		}
+
+		hash := generateHash(styles)
 		if _, exists := m.css[hash]; !exists {
-			m.css[hash] = comp.Styles
-			m.cssKeys = append(m.cssKeys, hash)
+			m.css[hash] = styles
+			m.cssSources[hash] = cssSource{files: comp.StyleFiles, scoped: Scoped(comp), priority: priority}
+			m.insertCSSKey(hash, priority)
+		}
+		if Critical(comp) {
+			m.cssCritical = true
 		}
 	}
 
 	// Handle JS - content based deduplication with filename tracking and order preservation
-	for origName, content := range comp.Scripts {
+	// Scripts are taken in file name order, and a script in a subfolder keeps the folder in its output
+	// name, forms/validate.js of component ui.form becoming ui-form-forms-validate.js.
+	for _, origName := range slices.Sorted(maps.Keys(comp.Scripts)) {
+		content := comp.Scripts[origName]
+		relName := filepath.ToSlash(origName)
 		hash := generateHash(content)
-		baseName := strings.TrimSuffix(origName, ".js")
-		outName := fmt.Sprintf("%s-%s", sanitizeFileName(comp.Path), baseName)
+		baseName := strings.TrimSuffix(relName, ".js")
+		outName := sanitizeFileName(fmt.Sprintf("%s-%s", sanitizeFileName(comp.Path), baseName))
+		if err := m.opts.Names.claim(path.Join("js", outName+".js"), comp.Path, relName); err != nil {
+			return err
+		}
+		if Critical(comp) {
+			m.jsCritical[outName] = true
+		}
 
 		if asset, exists := m.js[hash]; exists {
 			// Add new filename to existing content
@@ -66,51 +167,164 @@ func (m *Manager) ProcessComponent(comp *component.Component) error {
 		}
 	}
 
+	// Images are always linked, under names that keep the component path
+	for _, file := range slices.Sorted(maps.Keys(comp.Images)) {
+		name := ImageName(comp.Path, file)
+		if err := m.opts.Names.claim(path.Join(ImageDir, name), comp.Path, file); err != nil {
+			return err
+		}
+		m.images[name] = comp.Images[file]
+	}
+
 	return nil
 }
 
+// insertCSSKey adds a stylesheet hash after every stylesheet of the same or a higher priority
+func (m *Manager) insertCSSKey(hash string, priority int) {
+	i := len(m.cssKeys)
+	for i > 0 && m.cssSources[m.cssKeys[i-1]].priority < priority {
+		i--
+	}
+	m.cssKeys = slices.Insert(m.cssKeys, i, hash)
+}
+
+// ImageName returns the output name of a component image, logo.svg of component ui.card becoming
+// ui-card-logo.svg
+func ImageName(compPath, file string) string {
+	ext := path.Ext(file)
+	return sanitizeFileName(compPath+"-"+strings.TrimSuffix(file, ext)) + ext
+}
+
+// ImageURL returns the URL of a component image below the prefix leading to the site root
+func ImageURL(prefix, compPath, file string) string {
+	return assetURL(prefix, ImageDir, ImageName(compPath, file))
+}
+
+// Scoped reports whether a component opted into style scoping with "scope = true" in its metadata
+func Scoped(comp *component.Component) bool {
+	return comp.Meta["scope"] == "true"
+}
+
+// Critical reports whether a component marked its assets for preloading with "preload = true" in its metadata
+func Critical(comp *component.Component) bool {
+	return comp.Meta["preload"] == "true"
+}
+
+// Priority returns the stylesheet priority a component declared with "priority = N" in its metadata, 0
+// when not set
+// Stylesheets of a higher priority come first in the merged stylesheet, whatever order their components
+// are rendered in.
+func Priority(comp *component.Component) (int, error) {
+	value := comp.Meta["priority"]
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("priority %q is not a whole number", value)
+	}
+	return n, nil
+}
+
+// assetURL returns the URL of an output asset below the prefix leading to the site root
+// URLs are joined with path, not filepath, so they use forward slashes whatever the host separator;
+// output files are still written with host paths by storage.
+func assetURL(prefix, dir, name string) string {
+	return path.Join(prefix, dir, name)
+}
+
 // GetAssetTags returns both style and script tags
+// Assets smaller than the inline threshold are embedded in the tags instead of linked.
+// The prefix, such as "../" or "/docs/", leads from the page to the site root.
 func (m *Manager) GetAssetTags(prefix string) (styles, scripts string) {
 	// All CSS is merged into one file
 	if len(m.css) > 0 {
-		styles = fmt.Sprintf(`<link rel="stylesheet" href="%s">`,
-			filepath.Join(prefix, "css", "styles.css"))
+		css := m.mergedCSS()
+		if m.inlined(css) {
+			styles = fmt.Sprintf("<style>%s</style>", escapeInline(rebaseImageURLs(css, prefix), "</style"))
+		} else {
+			styles = fmt.Sprintf(`<link rel="stylesheet" href="%s"%s>`,
+				assetURL(prefix, "css", m.stylesheet()), m.stylesheetIntegrity(css))
+		}
 	}
 
-	// Generate script tags for each unique JS file
+	return styles, m.GetScriptTags(prefix, 0, len(m.jsKeys))
+}
+
+// ScriptCount returns the number of distinct scripts added so far, a position in the page's script order
+func (m *Manager) ScriptCount() int {
+	return len(m.jsKeys)
+}
+
+// GetScriptTags returns the script tags of the scripts from position from up to to in the page's script order
+// Positions are those returned by ScriptCount; like GetAssetTags, small scripts are embedded in their tags.
+func (m *Manager) GetScriptTags(prefix string, from, to int) string {
 	var jsB bytes.Buffer
-	for _, asset := range m.js {
+	for _, hash := range m.jsKeys[from:to] {
+		asset := m.js[hash]
 		for _, filename := range asset.files {
-			jsName := sanitizeFileName(filename) + ".js"
-			jsB.WriteString(fmt.Sprintf(`<script src="%s"></script>`,
-				filepath.Join(prefix, "js", jsName)))
+			if m.inlined(asset.content) {
+				jsB.WriteString(fmt.Sprintf("<script>%s</script>", escapeInline(asset.content, "</script")))
+			} else {
+				jsName := sanitizeFileName(filename) + ".js"
+				jsB.WriteString(fmt.Sprintf(`<script src="%s"%s></script>`,
+					assetURL(prefix, "js", jsName), m.integrityAttrs(asset.content)))
+			}
 			jsB.WriteByte('\n')
 		}
 	}
-	scripts = strings.TrimSpace(jsB.String())
+	return strings.TrimSpace(jsB.String())
+}
+
+// GetPreloadTags returns preload hints for the linked assets of critical components
+// Inlined assets need no hint; hrefs and integrity match the tags from GetAssetTags.
+func (m *Manager) GetPreloadTags(prefix string) string {
+	if !m.opts.Preload {
+		return ""
+	}
+
+	var b strings.Builder
+	if m.cssCritical {
+		if css := m.mergedCSS(); !m.inlined(css) {
+			b.WriteString(fmt.Sprintf(`<link rel="preload" href="%s" as="style"%s>`,
+				assetURL(prefix, "css", m.stylesheet()), m.stylesheetIntegrity(css)))
+		}
+	}
+
+	for _, hash := range m.jsKeys {
+		asset := m.js[hash]
+		if m.inlined(asset.content) {
+			continue
+		}
+		for _, filename := range asset.files {
+			if !m.jsCritical[filename] {
+				continue
+			}
+			jsName := sanitizeFileName(filename) + ".js"
+			b.WriteString(fmt.Sprintf(`<link rel="preload" href="%s" as="script"%s>`,
+				assetURL(prefix, "js", jsName), m.integrityAttrs(asset.content)))
+		}
+	}
 
-	return styles, scripts
+	return b.String()
 }
 
-// GetFiles returns all CSS and JS files for output
+// GetFiles returns all CSS, JS, and image files for output, leaving out inlined assets
 func (m *Manager) GetFiles() map[string][]byte {
 	files := make(map[string][]byte)
 
-	// Merge all CSS in order
 	if len(m.css) > 0 {
-		var merged bytes.Buffer
-		for _, hash := range m.cssKeys {
-			if content, exists := m.css[hash]; exists {
-				merged.Write(content)
-				merged.WriteByte('\n')
-			}
+		if css := m.mergedCSS(); !m.inlined(css) {
+			files[m.stylesheet()] = css
+		}
+		if m.cssMap != nil {
+			files[m.stylesheet()+sourceMapExt] = m.cssMap
 		}
-		files["styles.css"] = bytes.TrimSuffix(merged.Bytes(), []byte{'\n'})
 	}
 
 	// Keep JS files separate but ordered
 	for _, hash := range m.jsKeys {
-		if asset, exists := m.js[hash]; exists {
+		if asset, exists := m.js[hash]; exists && !m.inlined(asset.content) {
 			for _, filename := range asset.files {
 				jsName := sanitizeFileName(filename) + ".js"
 				files[jsName] = asset.content
@@ -118,9 +332,122 @@ func (m *Manager) GetFiles() map[string][]byte {
 		}
 	}
 
+	for name, content := range m.images {
+		files[name] = content
+	}
+
 	return files
 }
 
+// Finalize merges the page's stylesheet and runs the CSS transforms over it
+// It must be called once all components are processed and before the tags or files are read.
+func (m *Manager) Finalize() error {
+	if err := m.opts.Validate(); err != nil {
+		return err
+	}
+	if len(m.css) == 0 {
+		return nil
+	}
+	css := m.mergeCSS()
+	for i, transform := range m.opts.CSSTransforms {
+		var err error
+		if css, err = transform(css); err != nil {
+			return fmt.Errorf("CSS transform %d: %w", i+1, err)
+		}
+	}
+
+	// A linked stylesheet points at its map; transforms are assumed to keep rules on their lines
+	if m.opts.SourceMap && !m.inlined(css) {
+		cssMap, err := m.buildSourceMap()
+		if err != nil {
+			return fmt.Errorf("building CSS source map: %w", err)
+		}
+		css = append(css, "\n/*# sourceMappingURL="+m.stylesheet()+sourceMapExt+" */"...)
+		m.cssMap = cssMap
+	}
+	m.merged = css
+	return nil
+}
+
+// stylesheet returns the file name of the page's merged stylesheet in css/
+func (m *Manager) stylesheet() string {
+	if m.opts.Stylesheet == "" {
+		return DefaultStylesheet + ".css"
+	}
+	return m.opts.Stylesheet + ".css"
+}
+
+// mergedCSS returns the stylesheet prepared by Finalize
+func (m *Manager) mergedCSS() []byte {
+	return m.merged
+}
+
+// mergeCSS merges all CSS in priority, then first-seen order
+func (m *Manager) mergeCSS() []byte {
+	var merged bytes.Buffer
+	for _, hash := range m.cssKeys {
+		if content, exists := m.css[hash]; exists {
+			merged.Write(content)
+			merged.WriteByte('\n')
+		}
+	}
+
+	css := bytes.TrimSuffix(merged.Bytes(), []byte{'\n'})
+	if m.opts.DedupeRules {
+		css = dedupeRules(css)
+	}
+	return css
+}
+
+// integrityAttrs returns the SRI attributes for an asset's written content, empty when disabled
+func (m *Manager) integrityAttrs(content []byte) string {
+	if !m.opts.Integrity {
+		return ""
+	}
+	return fmt.Sprintf(` integrity="%s" crossorigin="anonymous"`, integrityHash(content))
+}
+
+// stylesheetIntegrity returns the SRI attributes for the page's stylesheet
+// The shared stylesheet is rewritten by every page with that page's CSS, so a hash of one page's
+// content would not verify on the others; only a page's own stylesheet gets one.
+func (m *Manager) stylesheetIntegrity(css []byte) string {
+	if m.opts.Stylesheet == "" {
+		return ""
+	}
+	return m.integrityAttrs(css)
+}
+
+// integrityHash returns the sha384 Subresource Integrity value of content
+func integrityHash(content []byte) string {
+	sum := sha512.Sum384(content)
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// inlined reports whether an asset is small enough to embed in the page
+func (m *Manager) inlined(content []byte) bool {
+	return len(content) < m.opts.InlineThreshold
+}
+
+// escapeInline keeps embedded content from closing its element early
+func escapeInline(content []byte, closing string) string {
+	var b strings.Builder
+	lower := bytes.ToLower(content)
+	last := 0
+	for {
+		i := bytes.Index(lower[last:], []byte(closing))
+		if i == -1 {
+			break
+		}
+		// "</" becomes "<\/", which CSS and JS both read the same inside strings and comments
+		i += last
+		b.Write(content[last : i+1])
+		b.WriteString("\\")
+		last = i + 1
+	}
+	b.Write(content[last:])
+	return b.String()
+}
+
 // generateHash creates a hash of content for deduplication
 func generateHash(content []byte) string {
 	h := sha256.New()
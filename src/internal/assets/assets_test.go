@@ -0,0 +1,258 @@
+package assets
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"webfactory/src/internal/component"
+)
+
+// newComponent returns a component with files given as pairs of a name and its content
+// Names end in .css for stylesheets, kept in order, or .js for scripts, and are relative to the component's directory.
+func newComponent(path string, files ...string) *component.Component {
+	comp := &component.Component{Path: path, Scripts: map[string][]byte{}, Meta: map[string]string{}}
+	dir := strings.ReplaceAll(path, ".", "/")
+	for i := 0; i+1 < len(files); i += 2 {
+		name, content := files[i], []byte(files[i+1])
+		if strings.HasSuffix(name, ".js") {
+			comp.Scripts[name] = content
+			continue
+		}
+		comp.StyleFiles = append(comp.StyleFiles, component.StyleFile{Name: dir + "/" + name, CSS: content})
+		comp.Styles = append(append(comp.Styles, content...), '\n')
+	}
+	return comp
+}
+
+// finalized returns a manager that processed comps and finalized the page
+func finalized(t *testing.T, opts Options, comps ...*component.Component) *Manager {
+	t.Helper()
+	m := New(opts)
+	for _, comp := range comps {
+		if err := m.ProcessComponent(comp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Finalize(); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestInlineThreshold(t *testing.T) {
+	comps := []*component.Component{newComponent("ui.btn", "btn.css", ".btn { color: red; }"), newComponent("ui.btn", "btn.js", "console.log(1);")}
+	linked := finalized(t, Options{}, comps...).GetFiles()
+	cssSize, jsSize := len(linked["styles.css"]), len(linked["ui-btn-btn.js"])
+
+	tests := []struct {
+		name      string
+		threshold int
+		inlineCSS bool
+		inlineJS  bool
+	}{
+		{"disabled", 0, false, false},
+		{"at the script size", jsSize, false, false},
+		{"just over the script size", jsSize + 1, false, true},
+		{"at the stylesheet size", cssSize, false, true},
+		{"just over the stylesheet size", cssSize + 1, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := finalized(t, Options{InlineThreshold: tt.threshold}, comps...)
+			styles, scripts := m.GetAssetTags("")
+			files := m.GetFiles()
+
+			if inlined := strings.HasPrefix(styles, "<style>"); inlined != tt.inlineCSS {
+				t.Errorf("stylesheet inlined: %v, want %v: %s", inlined, tt.inlineCSS, styles)
+			}
+			if _, written := files["styles.css"]; written == tt.inlineCSS {
+				t.Errorf("styles.css written: %v, want %v", written, !tt.inlineCSS)
+			}
+			if inlined := scripts == "<script>console.log(1);</script>"; inlined != tt.inlineJS {
+				t.Errorf("script inlined: %v, want %v: %s", inlined, tt.inlineJS, scripts)
+			}
+			if _, written := files["ui-btn-btn.js"]; written == tt.inlineJS {
+				t.Errorf("ui-btn-btn.js written: %v, want %v", written, !tt.inlineJS)
+			}
+		})
+	}
+}
+
+func TestInlineEscapesClosingTag(t *testing.T) {
+	m := finalized(t, Options{InlineThreshold: 100}, newComponent("a", "a.js", `s = "</SCRIPT>";`))
+	if _, scripts := m.GetAssetTags(""); strings.Count(strings.ToLower(scripts), "</script") != 1 {
+		t.Errorf("inlined script closes its element early: %s", scripts)
+	}
+}
+
+// attr returns the value of the first name="..." attribute in tag
+func attr(t *testing.T, tag, name string) string {
+	t.Helper()
+	_, rest, found := strings.Cut(tag, name+`="`)
+	value, _, closed := strings.Cut(rest, `"`)
+	if !found || !closed {
+		t.Fatalf("no %s attribute in %s", name, tag)
+	}
+	return value
+}
+
+func TestIntegrityMatchesWrittenFiles(t *testing.T) {
+	comps := []*component.Component{newComponent("ui.btn", "btn.css", ".btn { color: red; }"), newComponent("ui.btn", "btn.js", "console.log(1);")}
+	m := finalized(t, Options{Integrity: true, Stylesheet: "index"}, comps...)
+	styles, scripts := m.GetAssetTags("")
+	files := m.GetFiles()
+
+	for _, check := range []struct{ tag, file string }{{styles, "index.css"}, {scripts, "ui-btn-btn.js"}} {
+		sum := sha512.Sum384(files[check.file])
+		want := "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+		if got := attr(t, check.tag, "integrity"); got != want {
+			t.Errorf("%s integrity %s does not verify, the file hashes to %s", check.file, got, want)
+		}
+		if got := attr(t, check.tag, "crossorigin"); got != "anonymous" {
+			t.Errorf("%s crossorigin = %q, want anonymous", check.file, got)
+		}
+	}
+
+	// Every page rewrites the shared stylesheet, so no one page's hash of it can be given
+	if shared, _ := finalized(t, Options{Integrity: true}, comps...).GetAssetTags(""); strings.Contains(shared, "integrity") {
+		t.Errorf("shared stylesheet linked with integrity: %s", shared)
+	}
+}
+
+func TestIntegrityWithTransform(t *testing.T) {
+	upper := func(css []byte) ([]byte, error) { return bytes.ToUpper(css), nil }
+	m := finalized(t, Options{Integrity: true, CSSTransforms: []CSSTransform{upper}, Stylesheet: "index"}, newComponent("a", "a.css", "a { color: red; }"))
+	styles, _ := m.GetAssetTags("")
+
+	sum := sha512.Sum384(m.GetFiles()["index.css"])
+	if got, want := attr(t, styles, "integrity"), "sha384-"+base64.StdEncoding.EncodeToString(sum[:]); got != want {
+		t.Errorf("integrity %s is not of the transformed stylesheet, want %s", got, want)
+	}
+}
+
+func TestPreloadTags(t *testing.T) {
+	critical := newComponent("ui.hero", "hero.css", ".hero { color: red; }", "hero.js", "hero();")
+	critical.Meta["preload"] = "true"
+	comps := []*component.Component{critical, newComponent("ui.menu", "menu.js", "menu();")}
+
+	m := finalized(t, Options{Preload: true, Integrity: true}, comps...)
+	styles, scripts := m.GetAssetTags("../")
+	preload := m.GetPreloadTags("../")
+
+	// The hints name the linked files with the same integrity as their tags, none for the shared stylesheet
+	want := `<link rel="preload" href="../css/styles.css" as="style">` +
+		`<link rel="preload" href="../js/ui-hero-hero.js" as="script" integrity="` + attr(t, scripts, "integrity") + `" crossorigin="anonymous">`
+	if !strings.Contains(styles, `href="../css/styles.css"`) || !strings.HasPrefix(scripts, `<script src="../js/ui-hero-hero.js"`) {
+		t.Fatalf("unexpected asset tags %s %s", styles, scripts)
+	}
+	files := m.GetFiles()
+	if files["styles.css"] == nil || files["ui-hero-hero.js"] == nil {
+		t.Errorf("preloaded assets not written: %v", slices.Sorted(maps.Keys(files)))
+	}
+	if preload != want {
+		t.Errorf("got preload tags\n%s\nwant\n%s", preload, want)
+	}
+	if strings.Contains(preload, "menu") {
+		t.Errorf("script of a component not marked preload is preloaded: %s", preload)
+	}
+
+	if off := finalized(t, Options{Integrity: true}, comps...).GetPreloadTags("../"); off != "" {
+		t.Errorf("preload tags without the option: %q", off)
+	}
+	if inlined := finalized(t, Options{Preload: true, InlineThreshold: 1000}, comps...).GetPreloadTags(""); inlined != "" {
+		t.Errorf("preload tags for inlined assets: %q", inlined)
+	}
+}
+
+func TestAssetURLsUseForwardSlashes(t *testing.T) {
+	comps := []*component.Component{
+		newComponent("ui.btn", "btn.css", ".btn { color: red; }"),
+		// Script names as listed on Windows, with backslash separators
+		newComponent("ui.btn", `lib\nested\util.js`, "console.log(1);"),
+		newComponent("ui.nav", "nav.js", "console.log(2);"),
+	}
+
+	for _, prefix := range []string{"", "../", "../../", "/", "/docs/", "/a/b/"} {
+		t.Run(prefix, func(t *testing.T) {
+			styles, scripts := finalized(t, Options{}, comps...).GetAssetTags(prefix)
+			urls := []string{attr(t, styles, "href")}
+			for _, tag := range strings.Split(scripts, "\n") {
+				urls = append(urls, attr(t, tag, "src"))
+			}
+			for _, url := range urls {
+				if strings.Contains(url, `\`) || !strings.HasPrefix(url, prefix) || strings.Contains(url[1:], "//") {
+					t.Errorf("asset URL %q is not a clean slash path below %q", url, prefix)
+				}
+			}
+			if want := prefix + "js/ui-btn-lib-nested-util.js"; urls[1] != want {
+				t.Errorf("nested script URL %q, want %q", urls[1], want)
+			}
+		})
+	}
+}
+
+func TestInlinedImageURLsUseForwardSlashes(t *testing.T) {
+	css := `.card { background: url("img/bg.png"); }`
+	comp := newComponent("ui.card", "card.css", css)
+	comp.Images = map[string][]byte{"img/bg.png": []byte("png")}
+	m := finalized(t, Options{InlineThreshold: 1 << 20}, comp)
+
+	styles, _ := m.GetAssetTags("/docs/")
+	if strings.Contains(styles, `\`) || !strings.Contains(styles, `url("/docs/`+ImageDir+"/") {
+		t.Errorf("inlined stylesheet image URL not rebased with slashes: %s", styles)
+	}
+}
+
+func TestCSSTransforms(t *testing.T) {
+	var order []string
+	prefix := func(css []byte) ([]byte, error) {
+		order = append(order, "prefix")
+		return bytes.ReplaceAll(css, []byte("user-select: none;"), []byte("-webkit-user-select: none; user-select: none;")), nil
+	}
+	banner := func(css []byte) ([]byte, error) {
+		order = append(order, "banner")
+		if !bytes.Contains(css, []byte("-webkit-")) {
+			t.Error("second transform did not receive the first one's output")
+		}
+		return append([]byte("/* built */\n"), css...), nil
+	}
+
+	m := finalized(t, Options{DedupeRules: true, CSSTransforms: []CSSTransform{prefix, banner}},
+		newComponent("a", "a.css", ".x { user-select: none; }"), newComponent("b", "b.css", ".x { user-select: none; }"))
+
+	if got := strings.Join(order, ","); got != "prefix,banner" {
+		t.Errorf("transforms ran as %s, want in registration order once", got)
+	}
+	css := string(m.GetFiles()["styles.css"])
+	if !strings.HasPrefix(css, "/* built */\n") || strings.Count(css, "-webkit-user-select: none;") != 1 {
+		t.Errorf("transformed stylesheet, deduplicated first:\n%s", css)
+	}
+}
+
+func TestCSSTransformError(t *testing.T) {
+	errParse := errors.New("unexpected token at 1:4")
+	ran := false
+	m := New(Options{CSSTransforms: []CSSTransform{
+		func(css []byte) ([]byte, error) { return css, nil },
+		func(css []byte) ([]byte, error) { return nil, errParse },
+		func(css []byte) ([]byte, error) { ran = true; return css, nil },
+	}})
+	if err := m.ProcessComponent(newComponent("a", "a.css", "a { color: red; }")); err != nil {
+		t.Fatal(err)
+	}
+
+	err := m.Finalize()
+	if !errors.Is(err, errParse) || err.Error() != "CSS transform 2: unexpected token at 1:4" {
+		t.Errorf("got error %v, want transform 2's error", err)
+	}
+	if ran {
+		t.Error("transform after the failing one ran")
+	}
+}
\ No newline at end of file
@@ -0,0 +1,178 @@
+package assets
+
+import (
+	"bytes"
+	"strings"
+)
+
+// cssUnit is one top-level CSS statement: a rule, an at-rule block, or an at-rule statement
+type cssUnit struct {
+	raw     []byte
+	prelude string // selector or at-rule header, whitespace-normalized
+	body    string // declarations, whitespace-normalized
+	inner   []byte // raw content between the braces, nil for statements
+	atRule  bool
+	props   map[string]bool // properties declared by a plain rule
+	dropped bool
+}
+
+// dedupeRules removes exact duplicate top-level rules from merged CSS, keeping the first occurrence
+// It is conservative to preserve the cascade: at-rule blocks such as @media and @supports are never
+// touched and no rule is deduplicated across one, and a duplicate is only dropped when no rule between
+// it and its first occurrence declares any of the same properties.
+func dedupeRules(css []byte) []byte {
+	units := splitUnits(css)
+
+	for i := range units {
+		if units[i].atRule || units[i].dropped {
+			continue
+		}
+		for j := i + 1; j < len(units); j++ {
+			u := &units[j]
+			if u.atRule {
+				break
+			}
+			if u.dropped || u.prelude != units[i].prelude || u.body != units[i].body {
+				continue
+			}
+			if !overridden(units[i+1:j], units[i].props) {
+				u.dropped = true
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	for _, u := range units {
+		if u.dropped {
+			continue
+		}
+		if out.Len() > 0 {
+			out.WriteByte('\n')
+		}
+		out.Write(u.raw)
+	}
+	return out.Bytes()
+}
+
+// overridden reports whether any kept rule among units declares one of props
+func overridden(units []cssUnit, props map[string]bool) bool {
+	for _, u := range units {
+		if u.dropped {
+			continue
+		}
+		for prop := range u.props {
+			if props[prop] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// splitUnits splits CSS into top-level units, skipping comments and respecting strings and nested blocks
+func splitUnits(css []byte) []cssUnit {
+	var units []cssUnit
+	start := -1
+	depth := 0
+	bodyStart := -1
+
+	for i := 0; i < len(css); i++ {
+		c := css[i]
+
+		// Comments between units are dropped, inside units they are kept in raw
+		if c == '/' && i+1 < len(css) && css[i+1] == '*' {
+			end := bytes.Index(css[i+2:], []byte("*/"))
+			if end == -1 {
+				i = len(css)
+			} else {
+				// Loop increment moves past the closing */
+				i += end + 3
+			}
+			continue
+		}
+
+		if start == -1 {
+			if isSpace(c) {
+				continue
+			}
+			start = i
+		}
+
+		switch c {
+		case '"', '\'':
+			// Skip string contents
+			for i++; i < len(css) && css[i] != c; i++ {
+				if css[i] == '\\' {
+					i++
+				}
+			}
+		case '{':
+			if depth == 0 {
+				bodyStart = i
+			}
+			depth++
+		case '}':
+			if depth == 0 {
+				// A } closing nothing is kept as text, like unterminated trailing content
+				units = append(units, textUnit(css[start:i+1]))
+				start = -1
+				continue
+			}
+			depth--
+			if depth == 0 {
+				units = append(units, newUnit(css[start:i+1], css[start:bodyStart], css[bodyStart+1:i]))
+				start, depth, bodyStart = -1, 0, -1
+			}
+		case ';':
+			if depth == 0 {
+				// At-rule statement such as @import or @charset
+				units = append(units, newUnit(css[start:i+1], css[start:i+1], nil))
+				start = -1
+			}
+		}
+	}
+
+	// Unterminated trailing content is kept as-is
+	if start != -1 && start < len(css) {
+		if raw := bytes.TrimSpace(css[start:]); len(raw) > 0 {
+			units = append(units, textUnit(raw))
+		}
+	}
+
+	return units
+}
+
+// textUnit builds a unit of content that isn't a complete statement, which is passed through unchanged
+func textUnit(raw []byte) cssUnit {
+	return cssUnit{raw: raw, prelude: string(raw), atRule: true}
+}
+
+// newUnit builds a unit, recording the properties declared by plain rules
+func newUnit(raw, prelude, body []byte) cssUnit {
+	u := cssUnit{
+		raw:     raw,
+		prelude: normalizeSpace(string(prelude)),
+		body:    normalizeSpace(string(body)),
+		inner:   body,
+		atRule:  bytes.HasPrefix(bytes.TrimSpace(prelude), []byte("@")),
+		props:   make(map[string]bool),
+	}
+
+	if !u.atRule {
+		for _, decl := range strings.Split(string(body), ";") {
+			if colon := strings.IndexByte(decl, ':'); colon != -1 {
+				u.props[strings.ToLower(strings.TrimSpace(decl[:colon]))] = true
+			}
+		}
+	}
+	return u
+}
+
+// normalizeSpace collapses runs of whitespace so formatting differences don't hide duplicates
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
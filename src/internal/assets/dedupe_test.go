@@ -0,0 +1,48 @@
+package assets
+
+import (
+	"strings"
+	"testing"
+
+	"webfactory/src/internal/component"
+)
+
+func TestDedupeSharedRule(t *testing.T) {
+	m := New(Options{DedupeRules: true})
+	for _, comp := range []*component.Component{
+		newComponent("a", "a.css", ".btn { color: red; }\n.a { margin: 0; }"),
+		newComponent("b", "b.css", ".btn {\n  color: red;\n}\n.b { padding: 0; }"),
+	} {
+		if err := m.ProcessComponent(comp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Finalize(); err != nil {
+		t.Fatal(err)
+	}
+
+	css := string(m.GetFiles()["styles.css"])
+	if n := strings.Count(css, "color: red"); n != 1 {
+		t.Errorf("shared rule kept %d times, want once:\n%s", n, css)
+	}
+	for _, unique := range []string{".a { margin: 0; }", ".b { padding: 0; }"} {
+		if !strings.Contains(css, unique) {
+			t.Errorf("unique rule %q dropped:\n%s", unique, css)
+		}
+	}
+}
+
+func TestDedupeKeepsOverriddenDuplicate(t *testing.T) {
+	css := ".x { color: red; }\n.y { color: blue; }\n.x { color: red; }"
+	if got := string(dedupeRules([]byte(css))); got != css {
+		t.Errorf("duplicate after an override was dropped:\n%s", got)
+	}
+}
+
+func TestDedupeStrayBrace(t *testing.T) {
+	css := "a { color: red; }\n}\na { color: red; }"
+	// The brace is kept as text, and like an at-rule no rule is deduplicated across it
+	if got := string(dedupeRules([]byte(css))); got != css {
+		t.Errorf("stray brace not kept as text:\n%s", got)
+	}
+}
@@ -0,0 +1,26 @@
+package assets
+
+import "fmt"
+
+// Names records which source claimed each script and image output path
+// Sanitizing can map different sources to one name, components/a.b/c.js and components/a/b-c.js both
+// becoming a-b-c.js, and the later file would silently overwrite the earlier one. Sharing Names across
+// the pages of a build catches this when the two files are on different pages.
+type Names struct {
+	owners map[string]string // output path, such as js/a-b-c.js -> "component/file" source
+}
+
+// NewNames creates an empty name table
+func NewNames() *Names {
+	return &Names{owners: make(map[string]string)}
+}
+
+// claim assigns an output path to the source file, failing if another source already has it
+func (n *Names) claim(out, compPath, file string) error {
+	source := compPath + "/" + file
+	if owner, taken := n.owners[out]; taken && owner != source {
+		return fmt.Errorf("%s and %s both map to %s, rename one of them", owner, source, out)
+	}
+	n.owners[out] = source
+	return nil
+}
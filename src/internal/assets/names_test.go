@@ -0,0 +1,68 @@
+package assets
+
+import (
+	"testing"
+
+	"webfactory/src/internal/component"
+)
+
+func TestNameCollisionOnPage(t *testing.T) {
+	tests := []struct {
+		name  string
+		comps []*component.Component
+		err   string
+	}{
+		{
+			name:  "scripts",
+			comps: []*component.Component{newComponent("a.b", "c.js", "one()"), newComponent("a", "b-c.js", "two()")},
+			err:   "a.b/c.js and a/b-c.js both map to js/a-b-c.js, rename one of them",
+		},
+		{
+			name:  "punctuation",
+			comps: []*component.Component{newComponent("ui", "my_btn.js", "one()"), newComponent("ui", "my.btn.js", "two()")},
+			err:   "ui/my_btn.js and ui/my.btn.js both map to js/ui-my-btn.js, rename one of them",
+		},
+		{
+			name: "images",
+			comps: []*component.Component{
+				{Path: "a.b", Images: map[string][]byte{"c.png": []byte("1")}, Meta: map[string]string{}},
+				{Path: "a", Images: map[string][]byte{"b_c.png": []byte("2")}, Meta: map[string]string{}},
+			},
+			err: "a.b/c.png and a/b_c.png both map to img/a-b-c.png, rename one of them",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(Options{})
+			var err error
+			for _, comp := range tt.comps {
+				if err = m.ProcessComponent(comp); err != nil {
+					break
+				}
+			}
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestNameCollisionAcrossPages(t *testing.T) {
+	names := NewNames()
+	first := New(Options{Names: names})
+	if err := first.ProcessComponent(newComponent("a.b", "c.js", "one()")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The same file on another page keeps its name
+	again := New(Options{Names: names})
+	if err := again.ProcessComponent(newComponent("a.b", "c.js", "one()")); err != nil {
+		t.Errorf("same script on a second page: %v", err)
+	}
+
+	other := New(Options{Names: names})
+	if err := other.ProcessComponent(newComponent("a", "b-c.js", "two()")); err == nil {
+		t.Error("colliding script on a second page was accepted")
+	}
+}
@@ -0,0 +1,44 @@
+package assets
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"webfactory/src/internal/component"
+)
+
+func TestPriorityOrder(t *testing.T) {
+	want := []string{"reset", "theme", "card", "later", "print"}
+	priorities := map[string]string{"reset": "10", "theme": "5", "card": "", "later": "0", "print": "-1"}
+
+	for _, order := range [][]string{
+		{"card", "later", "print", "theme", "reset"},
+		{"reset", "theme", "card", "later", "print"},
+		{"print", "card", "reset", "later", "theme"},
+	} {
+		comps := make([]*component.Component, len(order))
+		for i, path := range order {
+			comps[i] = newComponent(path, path+".css", "."+path+" { margin: 0; }")
+			if priority := priorities[path]; priority != "" {
+				comps[i].Meta["priority"] = priority
+			}
+		}
+		css := string(finalized(t, Options{}, comps...).GetFiles()["styles.css"])
+
+		got := slices.SortedFunc(slices.Values(want), func(a, b string) int {
+			return strings.Index(css, "."+a+" {") - strings.Index(css, "."+b+" {")
+		})
+		if !slices.Equal(got, want) {
+			t.Errorf("loaded in order %q, stylesheets merged as %q, want %q", order, got, want)
+		}
+	}
+}
+
+func TestPriorityInvalid(t *testing.T) {
+	comp := newComponent("reset", "reset.css", ".reset { margin: 0; }")
+	comp.Meta["priority"] = "high"
+	if err := New(Options{}).ProcessComponent(comp); err == nil || !strings.Contains(err.Error(), `priority "high" is not a whole number`) {
+		t.Errorf("got error %v, want the invalid priority reported", err)
+	}
+}
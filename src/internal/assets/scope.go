@@ -0,0 +1,123 @@
+package assets
+
+import (
+	"bytes"
+	"strings"
+)
+
+// ScopeClass returns the class that scopes the styles of a component
+func ScopeClass(path string) string {
+	return "wf-" + sanitizeFileName(path)
+}
+
+// scopeCSS restricts css to elements inside, or being, an element with the scope class
+// Rules inside @media, @supports, @container and @layer blocks are scoped as well;
+// other at-rules such as @keyframes and @font-face are left unchanged.
+func scopeCSS(css []byte, class string) []byte {
+	var out bytes.Buffer
+
+	for _, u := range splitUnits(css) {
+		if out.Len() > 0 {
+			out.WriteByte('\n')
+		}
+
+		switch {
+		case u.inner == nil:
+			out.Write(u.raw)
+		case u.atRule && isGroupingRule(u.prelude):
+			out.WriteString(u.prelude)
+			out.WriteString(" {\n")
+			out.Write(scopeCSS(u.inner, class))
+			out.WriteString("\n}")
+		case u.atRule:
+			out.Write(u.raw)
+		default:
+			out.WriteString(scopeSelectors(u.prelude, class))
+			out.WriteString(" {")
+			out.Write(u.inner)
+			out.WriteString("}")
+		}
+	}
+
+	return out.Bytes()
+}
+
+// isGroupingRule reports whether an at-rule holds ordinary style rules
+func isGroupingRule(prelude string) bool {
+	for _, name := range []string{"@media", "@supports", "@container", "@layer"} {
+		if prelude == name || strings.HasPrefix(prelude, name+" ") || strings.HasPrefix(prelude, name+"(") {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeSelectors scopes each selector of a comma-separated selector list
+func scopeSelectors(list string, class string) string {
+	var scoped []string
+	for _, sel := range splitTopLevel(list, ',') {
+		sel = strings.TrimSpace(sel)
+		if sel == "" {
+			continue
+		}
+
+		switch sel {
+		case ":root", "html", "body":
+			// Document-level rules apply to the component root instead
+			scoped = append(scoped, "."+class)
+			continue
+		}
+
+		// Match descendants of the scope root, and the root element itself
+		first, rest := splitCompound(sel)
+		scoped = append(scoped, "."+class+" "+sel, addClass(first, class)+rest)
+	}
+	return strings.Join(scoped, ", ")
+}
+
+// splitTopLevel splits s at sep outside of parentheses and brackets
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	depth := 0
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(', '[':
+			depth++
+		case ')', ']':
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// splitCompound splits a selector after its first compound selector, at the first combinator
+func splitCompound(sel string) (string, string) {
+	depth := 0
+	for i := 0; i < len(sel); i++ {
+		switch sel[i] {
+		case '(', '[':
+			depth++
+		case ')', ']':
+			depth--
+		case ' ', '>', '+', '~':
+			if depth == 0 {
+				return sel[:i], sel[i:]
+			}
+		}
+	}
+	return sel, ""
+}
+
+// addClass adds a class to a compound selector, ahead of any pseudo-element
+func addClass(compound string, class string) string {
+	if i := strings.Index(compound, "::"); i != -1 {
+		return compound[:i] + "." + class + compound[i:]
+	}
+	return compound + "." + class
+}
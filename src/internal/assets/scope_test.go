@@ -0,0 +1,55 @@
+package assets
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScopeCSS(t *testing.T) {
+	tests := []struct {
+		name string
+		css  string
+		want string
+	}{
+		{
+			name: "selector list",
+			css:  ".btn, a > span { color: red; }",
+			want: ".wf-ui-btn .btn, .btn.wf-ui-btn, .wf-ui-btn a > span, a.wf-ui-btn > span { color: red; }",
+		},
+		{
+			name: "pseudo-element",
+			css:  "p::before { content: ''; }",
+			want: ".wf-ui-btn p::before, p.wf-ui-btn::before { content: ''; }",
+		},
+		{
+			name: "document level",
+			css:  ":root { --c: red; }",
+			want: ".wf-ui-btn { --c: red; }",
+		},
+		{
+			name: "keyframes unchanged",
+			css:  "@keyframes spin { from { opacity: 0 } to { opacity: 1 } }",
+			want: "@keyframes spin { from { opacity: 0 } to { opacity: 1 } }",
+		},
+		{
+			name: "media rules scoped",
+			css:  "@media (max-width: 600px) { .btn { padding: 0; } }",
+			want: "@media (max-width: 600px) {\n.wf-ui-btn .btn, .btn.wf-ui-btn { padding: 0; }\n}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(scopeCSS([]byte(tt.css), ScopeClass("ui.btn"))); got != tt.want {
+				t.Errorf("got  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScopeCSSStrayBrace(t *testing.T) {
+	got := string(scopeCSS([]byte("a { color: red; }\n}"), "wf-x"))
+	if !strings.HasPrefix(got, ".wf-x a, a.wf-x { color: red; }") || !strings.HasSuffix(got, "\n}") {
+		t.Errorf("stray brace not passed through: %q", got)
+	}
+}
@@ -0,0 +1,109 @@
+package assets
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"webfactory/src/internal/component"
+)
+
+// sourceMapExt is appended to the merged stylesheet's name for the file its source map is written to
+const sourceMapExt = ".map"
+
+// cssSource records the stylesheets a component's CSS was combined from
+type cssSource struct {
+	files    []component.StyleFile
+	scoped   bool // selectors were rewritten, so lines map only to the start of the first file
+	priority int  // declared priority, ordering the content in the merged stylesheet
+}
+
+// sourceMap is a version 3 source map
+type sourceMap struct {
+	Version        int      `json:"version"`
+	File           string   `json:"file"`
+	Sources        []string `json:"sources"`
+	SourcesContent []string `json:"sourcesContent"`
+	Names          []string `json:"names"`
+	Mappings       string   `json:"mappings"`
+}
+
+// buildSourceMap maps each line of the merged stylesheet to the line of the component file it came from
+// Mapping is by line, as merging only concatenates files; the lines of a scoped component all point
+// to the start of its first stylesheet.
+func (m *Manager) buildSourceMap() ([]byte, error) {
+	sm := sourceMap{Version: 3, File: m.stylesheet(), Sources: []string{}, SourcesContent: []string{}, Names: []string{}}
+	index := make(map[string]int)
+	sourceIndex := func(f component.StyleFile) int {
+		i, ok := index[f.Name]
+		if !ok {
+			i = len(sm.Sources)
+			index[f.Name] = i
+			sm.Sources = append(sm.Sources, f.Name)
+			sm.SourcesContent = append(sm.SourcesContent, string(f.CSS))
+		}
+		return i
+	}
+
+	var mappings strings.Builder
+	prevSource, prevLine := 0, 0
+	line := func(source, srcLine int) {
+		if source >= 0 {
+			mappings.WriteString(encodeVLQ(0))
+			mappings.WriteString(encodeVLQ(source - prevSource))
+			mappings.WriteString(encodeVLQ(srcLine - prevLine))
+			mappings.WriteString(encodeVLQ(0))
+			prevSource, prevLine = source, srcLine
+		}
+		mappings.WriteByte(';')
+	}
+
+	for _, hash := range m.cssKeys {
+		src := m.cssSources[hash]
+		lines := bytes.Count(m.css[hash], []byte{'\n'}) + 1
+		if src.scoped && len(src.files) > 0 {
+			first := sourceIndex(src.files[0])
+			for range lines {
+				line(first, 0)
+			}
+			continue
+		}
+		for _, f := range src.files {
+			i := sourceIndex(f)
+			n := bytes.Count(f.CSS, []byte{'\n'}) + 1
+			for l := range n {
+				line(i, l)
+			}
+			lines -= n
+		}
+		for range lines {
+			line(-1, 0)
+		}
+	}
+
+	sm.Mappings = strings.TrimRight(mappings.String(), ";")
+	return json.Marshal(sm)
+}
+
+// base64VLQ is the digit alphabet of source map mappings
+const base64VLQ = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// encodeVLQ encodes a mapping field as a base64 variable-length quantity, sign in the lowest bit
+func encodeVLQ(n int) string {
+	v := n << 1
+	if n < 0 {
+		v = (-n << 1) | 1
+	}
+
+	var b strings.Builder
+	for {
+		digit := v & 31
+		v >>= 5
+		if v > 0 {
+			digit |= 32
+		}
+		b.WriteByte(base64VLQ[digit])
+		if v == 0 {
+			return b.String()
+		}
+	}
+}
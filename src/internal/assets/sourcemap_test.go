@@ -0,0 +1,70 @@
+package assets
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSourceMap(t *testing.T) {
+	m := finalized(t, Options{SourceMap: true},
+		newComponent("ui.btn", "btn.css", ".btn {\n  color: red;\n}"),
+		newComponent("ui.card", "a.css", ".a { margin: 0; }", "theme/b.css", ".b { padding: 0; }"),
+	)
+	files := m.GetFiles()
+
+	css := string(files["styles.css"])
+	if !strings.HasSuffix(css, "\n/*# sourceMappingURL=styles.css.map */") {
+		t.Errorf("stylesheet does not point at its map:\n%s", css)
+	}
+
+	var sm struct {
+		Version        int      `json:"version"`
+		File           string   `json:"file"`
+		Sources        []string `json:"sources"`
+		SourcesContent []string `json:"sourcesContent"`
+		Mappings       string   `json:"mappings"`
+	}
+	if err := json.Unmarshal(files["styles.css.map"], &sm); err != nil {
+		t.Fatalf("source map is not valid JSON: %v\n%s", err, files["styles.css.map"])
+	}
+	if sm.Version != 3 || sm.File != "styles.css" {
+		t.Errorf("got version %d for %q, want version 3 for styles.css", sm.Version, sm.File)
+	}
+	if want := []string{"ui/btn/btn.css", "ui/card/a.css", "ui/card/theme/b.css"}; !slices.Equal(sm.Sources, want) {
+		t.Errorf("sources %q, want %q", sm.Sources, want)
+	}
+	if len(sm.SourcesContent) != 3 || sm.SourcesContent[2] != ".b { padding: 0; }" {
+		t.Errorf("sources content %q", sm.SourcesContent)
+	}
+	// The three lines of btn.css, the unmapped line ending the component, then the first line of each card file
+	if want := "AAAA;AACA;AACA;;ACFA;ACAA"; !strings.HasPrefix(sm.Mappings, want) {
+		t.Errorf("mappings %q, want to start with %q", sm.Mappings, want)
+	}
+}
+
+func TestSourceMapSkippedWhenInlined(t *testing.T) {
+	m := finalized(t, Options{SourceMap: true, InlineThreshold: 1 << 20}, newComponent("a", "a.css", "a { color: red; }"))
+	if _, ok := m.GetFiles()["styles.css.map"]; ok {
+		t.Error("map written for an inlined stylesheet")
+	}
+}
+
+func TestEncodeVLQ(t *testing.T) {
+	for n, want := range map[int]string{0: "A", 1: "C", -1: "D", 15: "e", 16: "gB", -17: "jB", 1000: "w+B"} {
+		if got := encodeVLQ(n); got != want {
+			t.Errorf("encodeVLQ(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestSourceMapWithDedupe(t *testing.T) {
+	m := New(Options{SourceMap: true, DedupeRules: true})
+	if err := m.ProcessComponent(newComponent("a", "a.css", "a { color: red; }")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Finalize(); err == nil || !strings.Contains(err.Error(), "can't be combined") {
+		t.Errorf("got error %v, want the source map refused with deduplication", err)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,53 @@
+package assets
+
+import (
+	"bytes"
+	"path"
+	"regexp"
+	"strings"
+
+	"webfactory/src/internal/component"
+)
+
+// cssURL matches a url() reference in CSS, quoted or not
+var cssURL = regexp.MustCompile(`(?i)url\(\s*("[^"]*"|'[^']*'|[^)'"\s]*)\s*\)`)
+
+// imageDirFromCSS is how the rewritten references of the linked stylesheet, in css/, lead to ImageDir
+const imageDirFromCSS = `url("../` + ImageDir + `/`
+
+// componentCSS returns a component's styles with url() references to its images pointing at the written
+// images, relative to the page's stylesheet in css/
+// References are resolved from the directory of the stylesheet they are in. Data URIs, absolute and
+// root-relative URLs, and references to files that aren't component images are left unchanged; as
+// rewriting keeps every line, the source map still lines up.
+func componentCSS(comp *component.Component) []byte {
+	if len(comp.Images) == 0 {
+		return comp.Styles
+	}
+
+	compDir := strings.ReplaceAll(comp.Path, ".", "/")
+	var b bytes.Buffer
+	for _, f := range comp.StyleFiles {
+		dir := path.Dir(strings.TrimPrefix(f.Name, compDir+"/"))
+		b.Write(cssURL.ReplaceAllFunc(f.CSS, func(ref []byte) []byte {
+			value := string(cssURL.FindSubmatch(ref)[1])
+			file := strings.TrimSpace(strings.Trim(value, `"'`))
+			if file == "" || strings.HasPrefix(file, "/") || strings.HasPrefix(file, "#") || strings.Contains(file, ":") {
+				return ref
+			}
+			file = path.Join(dir, file)
+			if _, ok := comp.Images[file]; !ok {
+				return ref
+			}
+			return []byte(imageDirFromCSS + ImageName(comp.Path, file) + `")`)
+		}))
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
+
+// rebaseImageURLs points the image references of a stylesheet embedded in the page, rather than linked
+// from css/, at the images from the page
+func rebaseImageURLs(css []byte, prefix string) []byte {
+	return bytes.ReplaceAll(css, []byte(imageDirFromCSS), []byte(`url("`+assetURL(prefix, ImageDir, "")+"/"))
+}
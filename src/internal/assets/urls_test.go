@@ -0,0 +1,47 @@
+package assets
+
+import "testing"
+
+func TestComponentCSS(t *testing.T) {
+	tests := []struct {
+		name string
+		file string // stylesheet, relative to the component directory
+		css  string
+		want string
+	}{
+		{"double quoted", "card.css", `a { background: url("img/bg.png"); }`, `a { background: url("../img/ui-card-img-bg.png"); }`},
+		{"single quoted", "card.css", `a { background: url('./img/bg.png'); }`, `a { background: url("../img/ui-card-img-bg.png"); }`},
+		{"unquoted", "card.css", `a { background: URL( img/bg.png ); }`, `a { background: url("../img/ui-card-img-bg.png"); }`},
+		{"from a subdirectory", "parts/head.css", `a { background: url(../img/bg.png); }`, `a { background: url("../img/ui-card-img-bg.png"); }`},
+		{"data URI", "card.css", `a { background: url("data:image/png;base64,iVBO"); }`, `a { background: url("data:image/png;base64,iVBO"); }`},
+		{"absolute", "card.css", `a { background: url(https://example.com/bg.png); }`, `a { background: url(https://example.com/bg.png); }`},
+		{"root-relative", "card.css", `a { background: url(/img/bg.png); }`, `a { background: url(/img/bg.png); }`},
+		{"fragment", "card.css", `a { filter: url(#blur); }`, `a { filter: url(#blur); }`},
+		{"not an image of the component", "card.css", `a { background: url(other.png); }`, `a { background: url(other.png); }`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comp := newComponent("ui.card", tt.file, tt.css)
+			comp.Images = map[string][]byte{"img/bg.png": []byte("png")}
+			if got := string(componentCSS(comp)); got != tt.want+"\n" {
+				t.Errorf("got  %q\nwant %q", got, tt.want+"\n")
+			}
+		})
+	}
+}
+
+func TestComponentCSSWithoutImages(t *testing.T) {
+	comp := newComponent("card", "card.css", `a { background: url(bg.png); }`)
+	if got := string(componentCSS(comp)); got != string(comp.Styles) {
+		t.Errorf("styles of a component without images changed: %q", got)
+	}
+}
+
+func TestRebaseImageURLs(t *testing.T) {
+	css := `a { background: url("../img/ui-card-bg.png"); } b { background: url("data:image/gif;base64,R0lG"); }`
+	want := `a { background: url("../../img/ui-card-bg.png"); } b { background: url("data:image/gif;base64,R0lG"); }`
+	if got := string(rebaseImageURLs([]byte(css), "../../")); got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
\ No newline at end of file
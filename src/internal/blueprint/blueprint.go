@@ -1,71 +1,426 @@
 package blueprint
 
 import (
+	"fmt"
 	"sort"
 	"strconv"
 	"strings"
 	"unicode"
 )
 
+// SlotVar is the block variable that assigns a block to a named slot of its parent instead of a variable
+const SlotVar = "slot"
+
+// IDVar and ClassVar are block variables also set as the id and added to the class of the root element
+// of the block's rendered component
+const (
+	IDVar    = "id"
+	ClassVar = "class"
+)
+
+// WhenVar is the block variable naming a variable, as in .when = .premium, that must be set for the block
+// and its children to render
+const WhenVar = "when"
+
+// RepeatVar is the block variable rendering a block and its children several times, as in .repeat = 5
+// While rendering, the variable holds the current repetition counting from 0.
+const RepeatVar = "repeat"
+
+// DataVar is the block variable naming a JSON file, relative to the source root, whose fields become
+// variables of the block
+const DataVar = "data"
+
+// LayoutKey is the front matter key naming a layout component, such as layout = site.base, that the
+// page's top-level blocks render into
+const LayoutKey = "layout"
+
 type Block struct {
-	Path  string
-	Index []int
-	ID    int
-	Vars  map[string][]string
+	Path   string
+	Index  []int
+	ID     int
+	Vars   map[string][]string
+	Slot   string // Named slot of the parent template the block renders in, empty for the default
+	HTMLID string // id attribute given to the root element of the rendered component
+	Class  string // Space-separated classes added to the root element of the rendered component
+	When   string // Variable that must be truthy in the block's scope for it to render, empty for always
+	Repeat int    // Times the block renders, 0 when not set renders it once
+	Data   string // Slash-separated path of a JSON file supplying variables, empty for none
 }
 
 type Node struct {
 	Block    Block
 	Children []*Node
+	Meta     map[string]string // Page front matter, set on the root node only
 }
 
+// Reader reads a blueprint by its path relative to the blueprints directory
+type Reader func(path string) ([]byte, error)
+
 // New creates a blueprint tree from content
+// @include directives are rejected since there is no way to resolve them, see Load
 func New(content string) (*Node, error) {
+	meta, content, err := parseFrontMatter(normalize(content))
+	if err != nil {
+		return nil, err
+	}
+
+	blocks, err := parseBlocks(content, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := buildTree(blocks)
+	if err != nil {
+		return nil, err
+	}
+	return withLayout(withMeta(tree, meta), len(blocks)), nil
+}
+
+// Load creates a blueprint tree from the blueprint at path, splicing in the blocks of any
+// @include directives read through read
+func Load(path string, read Reader) (*Node, error) {
+	content, err := read(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading blueprint: %w", err)
+	}
+
+	meta, body, err := parseFrontMatter(normalize(string(content)))
+	if err != nil {
+		return nil, err
+	}
+
+	blocks, err := parseBlocks(body, read, []string{path})
+	if err != nil {
+		return nil, err
+	}
+	tree, err := buildTree(blocks)
+	if err != nil {
+		return nil, err
+	}
+	return withLayout(withMeta(tree, meta), len(blocks)), nil
+}
+
+// normalize strips a leading UTF-8 byte order mark and turns CRLF and lone CR line endings into LF, as
+// some editors save files
+func normalize(content string) string {
+	content = strings.TrimPrefix(content, "\uFEFF")
+	return strings.ReplaceAll(strings.ReplaceAll(content, "\r\n", "\n"), "\r", "\n")
+}
+
+// parseFrontMatter splits an optional front matter section off the top of a blueprint
+// The section is delimited by "---" lines and holds metadata in ParseMeta syntax.
+func parseFrontMatter(content string) (map[string]string, string, error) {
+	trimmed := strings.TrimLeft(content, " \t\r\n")
+	if !strings.HasPrefix(trimmed, "---") {
+		return make(map[string]string), content, nil
+	}
+
+	lines := strings.Split(trimmed, "\n")
+	if strings.TrimSpace(lines[0]) != "---" {
+		return make(map[string]string), content, nil
+	}
+
+	for i, line := range lines[1:] {
+		if strings.TrimSpace(line) == "---" {
+			meta, err := ParseMeta(strings.Join(lines[1:i+1], "\n"))
+			if err != nil {
+				return nil, "", fmt.Errorf("front matter: %w", err)
+			}
+			return meta, strings.Join(lines[i+2:], "\n"), nil
+		}
+	}
+
+	return nil, "", fmt.Errorf("front matter not closed with ---")
+}
+
+// ParseMeta parses "key = value" metadata lines, skipping blank lines and # comments
+func ParseMeta(content string) (map[string]string, error) {
+	meta := make(map[string]string)
+
+	for i, line := range strings.Split(normalize(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		eqIndex := strings.IndexByte(line, '=')
+		if eqIndex == -1 {
+			return nil, fmt.Errorf("line %d: expected key = value: %s", i+1, line)
+		}
+		meta[strings.TrimSpace(line[:eqIndex])] = strings.TrimSpace(line[eqIndex+1:])
+	}
+
+	return meta, nil
+}
+
+// withMeta attaches front matter to a tree, creating an empty root when the blueprint has no blocks
+func withMeta(root *Node, meta map[string]string) *Node {
+	if root == nil {
+		if len(meta) == 0 {
+			return nil
+		}
+		root = &Node{
+			Block:    Block{ID: -1},
+			Children: make([]*Node, 0),
+		}
+	}
+	root.Meta = meta
+	return root
+}
+
+// withLayout moves the top-level blocks of a page with a layout into a block of the layout component
+// The layout block gets the ID after the page's blocks. Page blocks render in its {{component}}, or in
+// the {{slot name}} their .slot names.
+func withLayout(root *Node, id int) *Node {
+	if root == nil || root.Meta[LayoutKey] == "" {
+		return root
+	}
+	layout := &Node{
+		Block: Block{
+			Path:  strings.TrimSpace(root.Meta[LayoutKey]),
+			Index: []int{0},
+			ID:    id,
+			Vars:  make(map[string][]string),
+		},
+		Children: root.Children,
+	}
+	root.Children = []*Node{layout}
+	return root
+}
+
+// parseBlocks parses blueprint content into its blocks, resolving includes
+// stack holds the chain of blueprints being included, for cycle detection
+func parseBlocks(content string, read Reader, stack []string) ([]Block, error) {
 	lines := strings.Split(content, "\n")
 	blocks := make([]Block, 0, len(lines))
-	id := 0
-	var currentBlock *Block
+	current := -1 // index of the block receiving variable lines
+
+	aliases, err := parseAliases(lines)
+	if err != nil {
+		return nil, err
+	}
 
 	for _, line := range lines {
-		line = strings.TrimSpace(line)
+		line = stripComment(strings.TrimSpace(line))
+		if strings.HasPrefix(line, aliasPrefix) {
+			continue
+		}
 
 		if strings.HasPrefix(line, ".") {
-			if currentBlock == nil {
-				continue
-			}
-
-			eqIndex := strings.IndexByte(line, '=')
-			if eqIndex == -1 {
+			if current == -1 {
 				continue
 			}
 
-			varName := strings.TrimSpace(line[:eqIndex])
-			valueStart := eqIndex + 1
-			for ; valueStart < len(line); valueStart++ {
-				if !unicode.IsSpace(rune(line[valueStart])) {
-					break
+			if name, value, ok := parseVar(line); ok {
+				if name == SlotVar {
+					blocks[current].Slot = strings.TrimSpace(value)
+					continue
 				}
+				if name == WhenVar {
+					blocks[current].When = strings.TrimPrefix(strings.TrimSpace(value), ".")
+					continue
+				}
+				if name == DataVar {
+					blocks[current].Data = strings.TrimSpace(value)
+					continue
+				}
+				if name == RepeatVar {
+					n, err := strconv.Atoi(strings.TrimSpace(value))
+					if err != nil || n < 1 {
+						return nil, fmt.Errorf("block %s: .repeat value %q is not a positive number", blocks[current].Path, value)
+					}
+					blocks[current].Repeat = n
+					continue
+				}
+				switch name {
+				case IDVar:
+					blocks[current].HTMLID = strings.TrimSpace(value)
+				case ClassVar:
+					blocks[current].Class = strings.TrimSpace(blocks[current].Class + " " + value)
+				}
+				blocks[current].Vars[name] = append(blocks[current].Vars[name], value)
 			}
-			value := line[valueStart:]
+			continue
+		}
 
-			if strings.HasPrefix(varName, ".") {
-				name := varName[1:] // Remove the dot
-				if _, exists := currentBlock.Vars[name]; !exists {
-					currentBlock.Vars[name] = make([]string, 0)
-				}
-				currentBlock.Vars[name] = append(currentBlock.Vars[name], value)
+		if base, path, ok := parseInclude(line); ok {
+			included, err := includeBlocks(path, read, stack)
+			if err != nil {
+				return nil, err
+			}
+			if base == nil {
+				base = []int{nextTopIndex(blocks)}
+			}
+			for _, block := range included {
+				block.Index = rebase(block.Index, base)
+				blocks = append(blocks, block)
 			}
+			// Variables after an include do not belong to any included block
+			current = -1
 			continue
 		}
 
-		if block, ok := parseLine(line, id); ok {
+		if block, ok := parseLine(line, len(blocks)); ok {
+			if path, ok := aliases[block.Path]; ok {
+				block.Path = path
+			}
 			blocks = append(blocks, block)
-			currentBlock = &blocks[len(blocks)-1]
-			id++
+			current = len(blocks) - 1
 		}
 	}
 
-	return buildTree(blocks), nil
+	// Renumber so IDs follow the order of the spliced blocks
+	for i := range blocks {
+		blocks[i].ID = i
+	}
+
+	return blocks, nil
+}
+
+// includeBlocks reads and parses an included blueprint, guarding against include cycles
+func includeBlocks(path string, read Reader, stack []string) ([]Block, error) {
+	if read == nil {
+		return nil, fmt.Errorf("cannot include %s: no blueprint reader", path)
+	}
+	for _, p := range stack {
+		if p == path {
+			return nil, fmt.Errorf("include cycle: %s -> %s", strings.Join(stack, " -> "), path)
+		}
+	}
+
+	content, err := read(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading included blueprint %s: %w", path, err)
+	}
+
+	blocks, err := parseBlocks(normalize(string(content)), read, append(stack[:len(stack):len(stack)], path))
+	if err != nil {
+		return nil, fmt.Errorf("including %s: %w", path, err)
+	}
+	return blocks, nil
+}
+
+// aliasPrefix starts a line declaring a short name for a component path, as in @alias hero = ui.sections.hero
+const aliasPrefix = "@alias "
+
+// parseAliases collects the aliases a blueprint declares, wherever they are in it
+// Aliases belong to the blueprint declaring them, not to the blueprints it includes. An alias name has
+// no dots, so it can't shadow a nested component path.
+func parseAliases(lines []string) (map[string]string, error) {
+	aliases := make(map[string]string)
+	for _, line := range lines {
+		line = stripComment(strings.TrimSpace(line))
+		if !strings.HasPrefix(line, aliasPrefix) {
+			continue
+		}
+		name, path, found := strings.Cut(strings.TrimPrefix(line, aliasPrefix), "=")
+		name, path = strings.TrimSpace(name), strings.TrimSpace(path)
+		if !found || name == "" || path == "" || strings.ContainsAny(name, ". \t") || strings.ContainsAny(path, " \t") {
+			return nil, fmt.Errorf("invalid alias %q, expected @alias name = component.path", line)
+		}
+		if prev, exists := aliases[name]; exists && prev != path {
+			return nil, fmt.Errorf("alias %s declared as both %s and %s", name, prev, path)
+		}
+		aliases[name] = path
+	}
+	return aliases, nil
+}
+
+// parseInclude recognizes "@include path" and "index @include path" lines
+func parseInclude(line string) ([]int, string, bool) {
+	parts := strings.Fields(line)
+
+	var base []int
+	if len(parts) == 3 {
+		index, ok := parseIndex(parts[0])
+		if !ok {
+			return nil, "", false
+		}
+		base = index
+		parts = parts[1:]
+	}
+
+	if len(parts) != 2 || parts[0] != "@include" {
+		return nil, "", false
+	}
+	return base, parts[1], true
+}
+
+// nextTopIndex returns the top-level index following all blocks so far
+func nextTopIndex(blocks []Block) int {
+	next := 1
+	for _, block := range blocks {
+		if len(block.Index) > 0 && block.Index[0] >= next {
+			next = block.Index[0] + 1
+		}
+	}
+	return next
+}
+
+// rebase moves an included block index so the included top-level block 1 lands at base
+// e.g. with base 2.3, included 1 becomes 2.3, 1.1 becomes 2.3.1 and 2 becomes 2.4
+func rebase(index, base []int) []int {
+	if len(index) == 0 || len(base) == 0 {
+		return index
+	}
+
+	rebased := make([]int, 0, len(base)+len(index)-1)
+	rebased = append(rebased, base[:len(base)-1]...)
+	rebased = append(rebased, base[len(base)-1]+index[0]-1)
+	return append(rebased, index[1:]...)
+}
+
+// ParseVars parses variable lines (".name = value") outside of any block, such as a site globals file
+// Repeated names collect multiple values; blank lines and # comments are skipped.
+func ParseVars(content string) map[string][]string {
+	vars := make(map[string][]string)
+	for _, line := range strings.Split(normalize(content), "\n") {
+		if name, value, ok := parseVar(stripComment(strings.TrimSpace(line))); ok {
+			vars[name] = append(vars[name], value)
+		}
+	}
+	return vars
+}
+
+// stripComment removes a trailing comment from a trimmed line, as in "1 hero # main banner"
+// A comment starts at a # that begins a word, outside double quotes, and is followed by a space or the
+// end of the line, so values such as #fff, page#top, and C# are kept whole.
+func stripComment(line string) string {
+	quoted := false
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '"':
+			quoted = !quoted
+		case c == '#' && !quoted && i > 0 && unicode.IsSpace(rune(line[i-1])) &&
+			(i+1 == len(line) || unicode.IsSpace(rune(line[i+1]))):
+			return strings.TrimSpace(line[:i])
+		}
+	}
+	return line
+}
+
+// parseVar splits a trimmed variable line into its name without the dot and its value
+// A carriage return left at the end of the line is never part of the value; other characters, such as
+// spaces inside quotes, are kept as written.
+func parseVar(line string) (string, string, bool) {
+	line = strings.TrimRight(line, "\r")
+	if !strings.HasPrefix(line, ".") {
+		return "", "", false
+	}
+
+	eqIndex := strings.IndexByte(line, '=')
+	if eqIndex == -1 {
+		return "", "", false
+	}
+
+	varName := strings.TrimSpace(line[:eqIndex])
+	valueStart := eqIndex + 1
+	for ; valueStart < len(line); valueStart++ {
+		if !unicode.IsSpace(rune(line[valueStart])) {
+			break
+		}
+	}
+
+	return varName[1:], line[valueStart:], true
 }
 
 func parseLine(line string, id int) (Block, bool) {
@@ -84,14 +439,9 @@ func parseLine(line string, id int) (Block, bool) {
 		return Block{}, false
 	}
 
-	indexStr := strings.Split(strings.TrimRight(parts[0], "."), ".")
-	index := make([]int, 0, len(indexStr))
-	for _, str := range indexStr {
-		num, err := strconv.Atoi(str)
-		if err != nil {
-			return Block{}, false
-		}
-		index = append(index, num)
+	index, ok := parseIndex(parts[0])
+	if !ok {
+		return Block{}, false
 	}
 
 	return Block{
@@ -102,9 +452,23 @@ func parseLine(line string, id int) (Block, bool) {
 	}, true
 }
 
-func buildTree(blocks []Block) *Node {
+// parseIndex parses a dotted block index such as "1.2" or "1.2."
+func parseIndex(str string) ([]int, bool) {
+	indexStr := strings.Split(strings.TrimRight(str, "."), ".")
+	index := make([]int, 0, len(indexStr))
+	for _, str := range indexStr {
+		num, err := strconv.Atoi(str)
+		if err != nil {
+			return nil, false
+		}
+		index = append(index, num)
+	}
+	return index, true
+}
+
+func buildTree(blocks []Block) (*Node, error) {
 	if len(blocks) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	root := &Node{
@@ -131,8 +495,8 @@ func buildTree(blocks []Block) *Node {
 
 		key := indexKey(block.Index)
 		// Duplicate Index is not allowed
-		if _, exists := nodeMap[key]; exists {
-			return nil
+		if prev, exists := nodeMap[key]; exists {
+			return nil, fmt.Errorf("duplicate block index %s for %s, already used by %s", key, block.Path, prev.Block.Path)
 		}
 		nodeMap[key] = node
 
@@ -166,5 +530,5 @@ func buildTree(blocks []Block) *Node {
 	}
 	sortNodes(root)
 
-	return root
+	return root, nil
 }
\ No newline at end of file
@@ -0,0 +1,376 @@
+package blueprint
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+	"testing"
+)
+
+// mapReader reads blueprints from a map of path to content
+func mapReader(files map[string]string) Reader {
+	return func(path string) ([]byte, error) {
+		content, ok := files[path]
+		if !ok {
+			return nil, fmt.Errorf("%s: %w", path, os.ErrNotExist)
+		}
+		return []byte(content), nil
+	}
+}
+
+// outline lists the blocks of a tree as "index path" in render order, one per line
+func outline(node *Node) string {
+	var lines []string
+	var walk func(node *Node)
+	walk = func(node *Node) {
+		for _, child := range node.Children {
+			index := make([]string, len(child.Block.Index))
+			for i, n := range child.Block.Index {
+				index[i] = fmt.Sprint(n)
+			}
+			lines = append(lines, strings.Join(index, ".")+" "+child.Block.Path)
+			walk(child)
+		}
+	}
+	walk(node)
+	return strings.Join(lines, "\n")
+}
+
+func TestIncludeSharedFooter(t *testing.T) {
+	read := mapReader(map[string]string{
+		"index.blueprint":           "1 hero\n@include partials/footer.blueprint\n",
+		"about.blueprint":           "1 site.nav\n2 text\n  .body=About\n@include partials/footer.blueprint\n",
+		"partials/footer.blueprint": "1 site.footer\n  .year=2024\n1.1 site.links\n",
+	})
+
+	tests := []struct {
+		page string
+		want string
+	}{
+		{"index.blueprint", "1 hero\n2 site.footer\n2.1 site.links"},
+		{"about.blueprint", "1 site.nav\n2 text\n3 site.footer\n3.1 site.links"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.page, func(t *testing.T) {
+			tree, err := Load(tt.page, read)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := outline(tree); got != tt.want {
+				t.Errorf("got blocks\n%s\nwant\n%s", got, tt.want)
+			}
+			footer := tree.Children[len(tree.Children)-1]
+			if year := footer.Block.Vars["year"]; len(year) != 1 || year[0] != "2024" {
+				t.Errorf("footer variables %v, want year 2024", footer.Block.Vars)
+			}
+		})
+	}
+}
+
+func TestIncludeAtIndex(t *testing.T) {
+	tree, err := Load("index.blueprint", mapReader(map[string]string{
+		"index.blueprint": "1 layout\n1.2 @include card.blueprint\n",
+		"card.blueprint":  "1 card\n1.1 card.title\n2 card\n",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := outline(tree), "1 layout\n1.2 card\n1.2.1 card.title\n1.3 card"; got != want {
+		t.Errorf("got blocks\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestIncludeCycle(t *testing.T) {
+	_, err := Load("index.blueprint", mapReader(map[string]string{
+		"index.blueprint": "1 page\n@include a.blueprint\n",
+		"a.blueprint":     "1 a\n@include b.blueprint\n",
+		"b.blueprint":     "1 b\n@include a.blueprint\n",
+	}))
+	if err == nil || !strings.Contains(err.Error(), "include cycle: index.blueprint -> a.blueprint -> b.blueprint -> a.blueprint") {
+		t.Errorf("got error %v, want the include cycle", err)
+	}
+}
+
+func TestIncludeWithoutReader(t *testing.T) {
+	if _, err := New("1 page\n@include footer.blueprint\n"); err == nil {
+		t.Error("New resolved an include without a reader")
+	}
+}
+
+func TestParseFrontMatter(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		meta    map[string]string
+		body    string
+	}{
+		{
+			name:    "with front matter",
+			content: "---\ntitle = Hello = World\n# draft\ndescription =  A page \n---\n1 hero\n",
+			meta:    map[string]string{"title": "Hello = World", "description": "A page"},
+			body:    "1 hero\n",
+		},
+		{
+			name:    "after blank lines",
+			content: "\n\n---\ntitle = Hi\n---\n1 hero",
+			meta:    map[string]string{"title": "Hi"},
+			body:    "1 hero",
+		},
+		{
+			name:    "without front matter",
+			content: "1 hero\n  .title=Hi\n",
+			meta:    map[string]string{},
+			body:    "1 hero\n  .title=Hi\n",
+		},
+		{
+			name:    "dashes not alone on the line",
+			content: "--- not front matter\n1 hero\n",
+			meta:    map[string]string{},
+			body:    "--- not front matter\n1 hero\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta, body, err := parseFrontMatter(tt.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !maps.Equal(meta, tt.meta) || body != tt.body {
+				t.Errorf("got %v and body %q, want %v and %q", meta, body, tt.meta, tt.body)
+			}
+		})
+	}
+}
+
+func TestParseFrontMatterInvalid(t *testing.T) {
+	for _, content := range []string{"---\ntitle = Hi\n1 hero\n", "---\ntitle\n---\n1 hero\n"} {
+		if _, _, err := parseFrontMatter(content); err == nil {
+			t.Errorf("parseFrontMatter(%q) succeeded, want an error", content)
+		}
+	}
+}
+
+func TestFrontMatterOnTree(t *testing.T) {
+	tree, err := New("---\ntitle = Home\n---\n1 hero\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.Meta["title"] != "Home" || len(tree.Children) != 1 {
+		t.Errorf("got meta %v with %d blocks, want title Home and one block", tree.Meta, len(tree.Children))
+	}
+
+	if tree, err = New("1 hero\n"); err != nil || len(tree.Meta) != 0 {
+		t.Errorf("blueprint without front matter has meta %v (error %v)", tree.Meta, err)
+	}
+}
+
+func TestSlotVar(t *testing.T) {
+	tree, err := New("1 layout\n1.1 nav\n  .slot = sidebar\n  .title=Menu\n1.2 body\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nav, body := tree.Children[0].Children[0].Block, tree.Children[0].Children[1].Block
+	if nav.Slot != "sidebar" || body.Slot != "" {
+		t.Errorf("got slots %q and %q, want sidebar and the default", nav.Slot, body.Slot)
+	}
+	if _, ok := nav.Vars[SlotVar]; ok || nav.Vars["title"][0] != "Menu" {
+		t.Errorf("got vars %v, want only title", nav.Vars)
+	}
+}
+
+func TestLayout(t *testing.T) {
+	tree, err := New("---\nlayout = site.base \n---\n1 hero\n2 article\n2.1 card\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := outline(tree), "0 site.base\n1 hero\n2 article\n2.1 card"; got != want {
+		t.Errorf("got outline\n%s\nwant\n%s", got, want)
+	}
+	if layout := tree.Children[0].Block; layout.ID != 3 {
+		t.Errorf("layout block has ID %d, want 3, after the page's blocks", layout.ID)
+	}
+
+	if tree, err = New("1 hero\n"); err != nil || outline(tree) != "1 hero" {
+		t.Errorf("page without a layout got outline %q (error %v)", outline(tree), err)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"BOM", "\uFEFF1 page\n", "1 page\n"},
+		{"CRLF", "1 page\r\n  .title=Hi\r\n", "1 page\n  .title=Hi\n"},
+		{"lone CR", "1 page\r  .title=Hi\r", "1 page\n  .title=Hi\n"},
+		{"BOM and CRLF", "\uFEFF1 page\r\n", "1 page\n"},
+		{"BOM only at the start", "1 page\n  .title=a\uFEFFb\n", "1 page\n  .title=a\uFEFFb\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalize(tt.content); got != tt.want {
+				t.Errorf("normalize(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBOMAndCRLF(t *testing.T) {
+	content := "\uFEFF---\r\ntitle = Home\r\n---\r\n1 hero\r\n  .title=Welcome\r\n  .items=a\r\n  .items=b\r\n1.1 card\r\n"
+	want := "1 hero\n1.1 card"
+
+	check := func(t *testing.T, tree *Node) {
+		t.Helper()
+		if got := outline(tree); got != want {
+			t.Errorf("got outline %q, want %q", got, want)
+		}
+		if tree.Meta["title"] != "Home" {
+			t.Errorf("got front matter %v, want title Home", tree.Meta)
+		}
+		vars := tree.Children[0].Block.Vars
+		if vars["title"][0] != "Welcome" || strings.Join(vars["items"], ",") != "a,b" {
+			t.Errorf("got vars %q, want values without carriage returns", vars)
+		}
+	}
+
+	t.Run("New", func(t *testing.T) {
+		tree, err := New(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, tree)
+	})
+	t.Run("Load with include", func(t *testing.T) {
+		tree, err := Load("index.blueprint", mapReader(map[string]string{
+			"index.blueprint": "\uFEFF---\r\ntitle = Home\r\n---\r\n@include hero.blueprint\r\n",
+			"hero.blueprint":  "\uFEFF1 hero\r\n  .title=Welcome\r\n  .items=a\r\n  .items=b\r\n1.1 card\r\n",
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, tree)
+	})
+}
+
+func TestParseVar(t *testing.T) {
+	tests := []struct {
+		line  string
+		name  string
+		value string
+	}{
+		{".title=Hello", "title", "Hello"},
+		{".title = Hello", "title", "Hello"},
+		{".title=Hello\r", "title", "Hello"},
+		{".title=Hello\r\r", "title", "Hello"},
+		{`.pad="  spaced  "` + "\r", "pad", `"  spaced  "`},
+		{".empty=\r", "empty", ""},
+		{".a=b=c", "a", "b=c"},
+	}
+	for _, tt := range tests {
+		name, value, ok := parseVar(tt.line)
+		if !ok || name != tt.name || value != tt.value {
+			t.Errorf("parseVar(%q) = %q, %q, %v, want %q, %q", tt.line, name, value, ok, tt.name, tt.value)
+		}
+	}
+
+	for _, line := range []string{"title=Hello", ".title", "\r"} {
+		if _, _, ok := parseVar(line); ok {
+			t.Errorf("parseVar(%q) accepted a line that isn't a variable", line)
+		}
+	}
+}
+
+func TestStripComment(t *testing.T) {
+	tests := map[string]string{
+		"1 hero # main banner":   "1 hero",
+		"1 hero #":               "1 hero",
+		".title=Hi   # greeting": ".title=Hi",
+		".color=#fff":            ".color=#fff",
+		".link=page#top":         ".link=page#top",
+		".lang=C# rocks":         ".lang=C# rocks",
+		".tag=a #b":              ".tag=a #b",
+		`.q="a # b" # c`:         `.q="a # b"`,
+		"# a whole-line comment": "# a whole-line comment",
+		"1 hero\t#\tmain banner": "1 hero",
+	}
+	for line, want := range tests {
+		if got := stripComment(line); got != want {
+			t.Errorf("stripComment(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
+
+func TestTrailingComments(t *testing.T) {
+	tree, err := New("# page\n1 hero # main banner\n  .title=Hi # greeting\n  .color=#fff\n1.1 card #\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := outline(tree), "1 hero\n1.1 card"; got != want {
+		t.Errorf("got outline %q, want %q", got, want)
+	}
+	vars := tree.Children[0].Block.Vars
+	if vars["title"][0] != "Hi" || vars["color"][0] != "#fff" {
+		t.Errorf("got vars %q, want title Hi and color #fff", vars)
+	}
+}
+
+func TestParseAliases(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+		err     string
+	}{
+		{"declared", "@alias hero = ui.sections.hero\n@alias btn=ui.btn # short\n", map[string]string{"hero": "ui.sections.hero", "btn": "ui.btn"}, ""},
+		{"repeated", "@alias hero = ui.hero\n@alias hero = ui.hero\n", map[string]string{"hero": "ui.hero"}, ""},
+		{"conflicting", "@alias hero = ui.hero\n@alias hero = ui.banner\n", nil, "alias hero declared as both ui.hero and ui.banner"},
+		{"dotted name", "@alias ui.hero = ui.sections.hero\n", nil, `invalid alias "@alias ui.hero = ui.sections.hero", expected @alias name = component.path`},
+		{"no path", "@alias hero =\n", nil, `invalid alias "@alias hero =", expected @alias name = component.path`},
+		{"no equals", "@alias hero ui.hero\n", nil, `invalid alias "@alias hero ui.hero", expected @alias name = component.path`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAliases(strings.Split(tt.content, "\n"))
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Errorf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || !maps.Equal(got, tt.want) {
+				t.Errorf("got %v (error %v), want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAliases(t *testing.T) {
+	tree, err := Load("index.blueprint", mapReader(map[string]string{
+		"index.blueprint":  "1 hero\n  .title=A\n2 hero\n  .title=B\n3 @include footer.blueprint\n@alias hero = ui.sections.hero\n",
+		"footer.blueprint": "1 hero\n",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The included blueprint doesn't see the alias, so its hero is a component path of its own
+	if got, want := outline(tree), "1 ui.sections.hero\n2 ui.sections.hero\n3 hero"; got != want {
+		t.Errorf("got outline %q, want %q", got, want)
+	}
+}
+
+func TestUnknownAlias(t *testing.T) {
+	tree, err := Load("index.blueprint", mapReader(map[string]string{
+		"index.blueprint": "@alias hero = ui.sections.hero\n1 hero\n2 heor\n",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An undeclared name is left as a component path, which loading then reports missing
+	if got, want := outline(tree), "1 ui.sections.hero\n2 heor"; got != want {
+		t.Errorf("got outline %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,157 @@
+package blueprint
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DataParser turns the content of a data file named by .data into block variables
+type DataParser func(content []byte) (map[string][]string, error)
+
+// CollectionParser turns the content of a collection data file into the variables of each item
+type CollectionParser func(content []byte) ([]map[string][]string, error)
+
+// dataParsers are the built-in data file parsers by extension, extended by RegisterDataParser
+var dataParsers = map[string]DataParser{
+	".json": ParseJSONVars,
+}
+
+// collectionParsers are the built-in collection file parsers by extension, extended by RegisterCollectionParser
+var collectionParsers = map[string]CollectionParser{
+	".json": ParseJSONCollection,
+}
+
+// RegisterDataParser adds a parser for data files with the extension, such as ".yaml"
+// It is meant to be called from init functions, as optional formats built with a build tag do.
+func RegisterDataParser(ext string, parser DataParser) {
+	dataParsers[strings.ToLower(ext)] = parser
+}
+
+// RegisterCollectionParser adds a parser for collection files with the extension, like RegisterDataParser
+func RegisterCollectionParser(ext string, parser CollectionParser) {
+	collectionParsers[strings.ToLower(ext)] = parser
+}
+
+// FindCollectionParser returns the parser for a collection file by its extension
+func FindCollectionParser(name string) (CollectionParser, error) {
+	ext := strings.ToLower(path.Ext(name))
+	if parser, ok := collectionParsers[ext]; ok {
+		return parser, nil
+	}
+	supported := slices.Sorted(maps.Keys(collectionParsers))
+	return nil, fmt.Errorf("no parser for collection file %s, supported are %s", name, strings.Join(supported, ", "))
+}
+
+// FindDataParser returns the parser for a data file by its extension, preferring those in custom
+func FindDataParser(name string, custom map[string]DataParser) (DataParser, error) {
+	ext := strings.ToLower(path.Ext(name))
+	if parser, ok := custom[ext]; ok && parser != nil {
+		return parser, nil
+	}
+	if parser, ok := dataParsers[ext]; ok {
+		return parser, nil
+	}
+
+	supported := slices.Sorted(maps.Keys(dataParsers))
+	for ext := range custom {
+		if !slices.Contains(supported, ext) {
+			supported = append(supported, ext)
+		}
+	}
+	return nil, fmt.Errorf("no parser for data file %s, supported are %s", name, strings.Join(supported, ", "))
+}
+
+// ParseJSONVars turns the fields of a JSON object into variables
+// Strings, numbers, and booleans become single values, arrays of them multiple values, and null an
+// empty value; nested objects are rejected.
+func ParseJSONVars(content []byte) (map[string][]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	var fields map[string]any
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, fmt.Errorf("parsing JSON: %w", err)
+	}
+	return DataVars(fields)
+}
+
+// ParseJSONCollection turns a JSON array of objects into the variables of each item, like ParseJSONVars
+func ParseJSONCollection(content []byte) ([]map[string][]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	var items []map[string]any
+	if err := decoder.Decode(&items); err != nil {
+		return nil, fmt.Errorf("parsing JSON: %w", err)
+	}
+	return CollectionVars(items)
+}
+
+// CollectionVars turns decoded collection items into variables, for CollectionParser implementations
+func CollectionVars(items []map[string]any) ([]map[string][]string, error) {
+	vars := make([]map[string][]string, 0, len(items))
+	for i, item := range items {
+		itemVars, err := DataVars(item)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %w", i+1, err)
+		}
+		vars = append(vars, itemVars)
+	}
+	return vars, nil
+}
+
+// DataVars turns the fields of a decoded data object into variables, for DataParser implementations
+// Scalars become single values and arrays of scalars multiple values; nested objects are rejected.
+func DataVars(fields map[string]any) (map[string][]string, error) {
+	vars := make(map[string][]string, len(fields))
+	for name, field := range fields {
+		items, isArray := field.([]any)
+		if !isArray {
+			items = []any{field}
+		}
+		values := make([]string, 0, len(items))
+		for _, item := range items {
+			value, ok := dataValue(item)
+			if !ok {
+				return nil, fmt.Errorf("field %s: only strings, numbers, booleans, and arrays of them are supported", name)
+			}
+			values = append(values, value)
+		}
+		vars[name] = values
+	}
+	return vars, nil
+}
+
+// dataValue formats a decoded scalar as a variable value
+func dataValue(v any) (string, bool) {
+	switch v := v.(type) {
+	case nil:
+		return "", true
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case int:
+		return strconv.Itoa(v), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case uint64:
+		return strconv.FormatUint(v, 10), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case time.Time:
+		// Unquoted YAML dates decode as times; one without a time of day keeps its date-only form
+		if v.Equal(v.Truncate(24*time.Hour)) && v.Location() == time.UTC {
+			return v.Format(time.DateOnly), true
+		}
+		return v.Format(time.RFC3339), true
+	}
+	return "", false
+}
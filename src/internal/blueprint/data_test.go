@@ -0,0 +1,86 @@
+package blueprint
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseJSONVars(t *testing.T) {
+	vars, err := ParseJSONVars([]byte(`{
+		"title": "Shop",
+		"count": 3,
+		"price": 9.50,
+		"big": 12345678901234567890,
+		"sale": true,
+		"note": null,
+		"tags": ["new", "hot"],
+		"sizes": [1, 2.5, false],
+		"empty": []
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{
+		"title": {"Shop"},
+		"count": {"3"},
+		"price": {"9.50"},
+		"big":   {"12345678901234567890"},
+		"sale":  {"true"},
+		"note":  {""},
+		"tags":  {"new", "hot"},
+		"sizes": {"1", "2.5", "false"},
+		"empty": {},
+	}
+	if !maps.EqualFunc(vars, want, slices.Equal) {
+		t.Errorf("got %q\nwant %q", vars, want)
+	}
+}
+
+func TestParseJSONVarsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		err  string
+	}{
+		{"malformed", `{"title": }`, "parsing JSON: invalid character '}'"},
+		{"not an object", `["a"]`, "parsing JSON: json: cannot unmarshal array"},
+		{"nested object", `{"seo": {"title": "x"}}`, "field seo: only strings, numbers, booleans, and arrays of them are supported"},
+		{"nested array", `{"grid": [[1, 2]]}`, "field grid: only strings"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseJSONVars([]byte(tt.json)); err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestFindDataParser(t *testing.T) {
+	custom := func(content []byte) (map[string][]string, error) {
+		return map[string][]string{"custom": {"yes"}}, nil
+	}
+
+	parse, err := FindDataParser("data/Page.JSON", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vars, _ := parse([]byte(`{"a": "b"}`)); vars["a"][0] != "b" {
+		t.Errorf("upper-case extension not parsed as JSON: %v", vars)
+	}
+
+	if parse, err = FindDataParser("data/page.toml", map[string]DataParser{".toml": custom}); err != nil {
+		t.Fatal(err)
+	}
+	if vars, _ := parse(nil); vars["custom"][0] != "yes" {
+		t.Errorf("custom parser not used: %v", vars)
+	}
+
+	if _, err := FindDataParser("data/page.ini", map[string]DataParser{".toml": custom}); err == nil || !strings.Contains(err.Error(), "no parser for data file data/page.ini, supported are .json") || !strings.HasSuffix(err.Error(), ".toml") {
+		t.Errorf("got error %v, want the supported extensions", err)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,35 @@
+//go:build yaml
+
+package blueprint
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// YAML data files are supported in builds with the yaml tag, which need gopkg.in/yaml.v3
+func init() {
+	RegisterDataParser(".yaml", ParseYAMLVars)
+	RegisterDataParser(".yml", ParseYAMLVars)
+	RegisterCollectionParser(".yaml", ParseYAMLCollection)
+	RegisterCollectionParser(".yml", ParseYAMLCollection)
+}
+
+// ParseYAMLVars turns the fields of a YAML mapping into variables, like ParseJSONVars
+func ParseYAMLVars(content []byte) (map[string][]string, error) {
+	var fields map[string]any
+	if err := yaml.Unmarshal(content, &fields); err != nil {
+		return nil, fmt.Errorf("parsing YAML: %w", err)
+	}
+	return DataVars(fields)
+}
+
+// ParseYAMLCollection turns a YAML sequence of mappings into the variables of each item
+func ParseYAMLCollection(content []byte) ([]map[string][]string, error) {
+	var items []map[string]any
+	if err := yaml.Unmarshal(content, &items); err != nil {
+		return nil, fmt.Errorf("parsing YAML: %w", err)
+	}
+	return CollectionVars(items)
+}
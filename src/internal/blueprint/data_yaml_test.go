@@ -0,0 +1,78 @@
+//go:build yaml
+
+package blueprint
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseYAMLVars(t *testing.T) {
+	vars, err := ParseYAMLVars([]byte(`
+title: Shop
+count: 3
+price: 9.5
+sale: yes
+launch: "2024-01-02"
+opens: 2024-01-02
+updated: 2024-01-02T15:04:05Z
+note: ~
+tags:
+  - new
+  - hot
+sizes: [1, 2.5, false]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{
+		"title":   {"Shop"},
+		"count":   {"3"},
+		"price":   {"9.5"},
+		"sale":    {"yes"},
+		"launch":  {"2024-01-02"},
+		"opens":   {"2024-01-02"},
+		"updated": {"2024-01-02T15:04:05Z"},
+		"note":    {""},
+		"tags":    {"new", "hot"},
+		"sizes":   {"1", "2.5", "false"},
+	}
+	if !maps.EqualFunc(vars, want, slices.Equal) {
+		t.Errorf("got %q\nwant %q", vars, want)
+	}
+}
+
+func TestParseYAMLVarsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		err  string
+	}{
+		{"malformed", "title: [unclosed\n", "parsing YAML: yaml: "},
+		{"bad indentation", "title: a\n  b: c\n", "parsing YAML: yaml: line 2: "},
+		{"not a mapping", "- a\n- b\n", "parsing YAML: yaml: unmarshal errors"},
+		{"nested mapping", "seo:\n  title: x\n", "field seo: only strings, numbers, booleans, and arrays of them are supported"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseYAMLVars([]byte(tt.yaml)); err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestYAMLParsersRegistered(t *testing.T) {
+	for _, name := range []string{"data/page.yaml", "data/page.YML"} {
+		if _, err := FindDataParser(name, nil); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		if _, err := FindCollectionParser(name); err != nil {
+			t.Errorf("%s as a collection: %v", name, err)
+		}
+	}
+}
\ No newline at end of file
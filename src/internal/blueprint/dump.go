@@ -0,0 +1,72 @@
+package blueprint
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Dump renders a parsed blueprint tree as indented text, for seeing how a blueprint was read
+// Front matter comes first, then each block as its index and component path with its variables below
+// it, children indented under their parent. The block a layout adds is shown as "layout". Special
+// variables removed from Vars, such as .slot, are listed with the others.
+func Dump(root *Node) string {
+	if root == nil {
+		return ""
+	}
+
+	var b strings.Builder
+	if len(root.Meta) > 0 {
+		b.WriteString("---\n")
+		for _, key := range slices.Sorted(maps.Keys(root.Meta)) {
+			fmt.Fprintf(&b, "%s = %s\n", key, root.Meta[key])
+		}
+		b.WriteString("---\n")
+	}
+
+	layout := root.Meta[LayoutKey] != ""
+	for _, child := range root.Children {
+		dumpNode(&b, child, 0, layout)
+	}
+	return b.String()
+}
+
+// dumpNode writes a block and its children at the given depth
+func dumpNode(b *strings.Builder, node *Node, depth int, layout bool) {
+	indent := strings.Repeat("  ", depth)
+	index := make([]string, len(node.Block.Index))
+	for i, n := range node.Block.Index {
+		index[i] = strconv.Itoa(n)
+	}
+	label := strings.Join(index, ".")
+	if layout {
+		label = "layout"
+	}
+	fmt.Fprintf(b, "%s%s %s\n", indent, label, node.Block.Path)
+
+	block := node.Block
+	special := []struct{ name, value string }{
+		{SlotVar, block.Slot},
+		{WhenVar, block.When},
+		{DataVar, block.Data},
+	}
+	if block.Repeat > 0 {
+		special = append(special, struct{ name, value string }{RepeatVar, strconv.Itoa(block.Repeat)})
+	}
+	for _, v := range special {
+		if v.value != "" {
+			fmt.Fprintf(b, "%s  .%s = %s\n", indent, v.name, v.value)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(block.Vars)) {
+		for _, value := range block.Vars[name] {
+			fmt.Fprintf(b, "%s  .%s = %s\n", indent, name, value)
+		}
+	}
+
+	for _, child := range node.Children {
+		dumpNode(b, child, depth+1, false)
+	}
+}
@@ -0,0 +1,49 @@
+package blueprint
+
+import "testing"
+
+func TestDump(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name: "nested blocks",
+			content: "---\ntitle = Home\ndate = 2024-05-01\n---\n" +
+				"1 layout\n  .theme=dark\n" +
+				"1.1 nav\n  .slot=sidebar\n  .items=a\n  .items=b\n" +
+				"1.2 card\n  .repeat=2\n  .when=.theme\n  .title=Hi\n" +
+				"1.2.1 ui.btn\n" +
+				"2 footer\n  .data=data/footer.json\n",
+			want: "---\ndate = 2024-05-01\ntitle = Home\n---\n" +
+				"1 layout\n  .theme = dark\n" +
+				"  1.1 nav\n    .slot = sidebar\n    .items = a\n    .items = b\n" +
+				"  1.2 card\n    .when = theme\n    .repeat = 2\n    .title = Hi\n" +
+				"    1.2.1 ui.btn\n" +
+				"2 footer\n  .data = data/footer.json\n",
+		},
+		{
+			name:    "layout",
+			content: "---\nlayout = site.base\n---\n1 hero\n2 text\n  .body=Hi\n",
+			want:    "---\nlayout = site.base\n---\nlayout site.base\n  1 hero\n  2 text\n    .body = Hi\n",
+		},
+		{
+			name:    "empty",
+			content: "",
+			want:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := New(tt.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := Dump(tree); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
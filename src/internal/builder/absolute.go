@@ -0,0 +1,135 @@
+package builder
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// urlAttr matches an href, src, or srcset attribute with a quoted value inside a start tag
+var urlAttr = regexp.MustCompile(`(?i)(\s(?:href|src|srcset)\s*=\s*)("[^"]*"|'[^']*')`)
+
+// absoluteURLs rewrites root-relative URLs in href, src, and srcset attributes to absolute URLs under base
+// Relative, protocol-relative, and absolute URLs, fragments, and other schemes such as mailto: are left
+// alone, as is the content of script and style elements.
+func absoluteURLs(html []byte, base string) []byte {
+	base = strings.TrimRight(base, "/")
+
+	var out bytes.Buffer
+	for i := 0; i < len(html); {
+		start := bytes.IndexByte(html[i:], '<')
+		if start == -1 {
+			out.Write(html[i:])
+			break
+		}
+		start += i
+		out.Write(html[i:start])
+
+		// A < not starting a tag or comment is text
+		if start+1 >= len(html) || !isTagStart(html[start+1]) {
+			out.WriteByte('<')
+			i = start + 1
+			continue
+		}
+		end := tagEnd(html, start)
+		if end == -1 {
+			out.Write(html[start:])
+			break
+		}
+		tag := html[start:end]
+		i = end
+		if html[start+1] == '!' {
+			out.Write(tag)
+			continue
+		}
+
+		out.Write(urlAttr.ReplaceAllFunc(tag, func(attr []byte) []byte {
+			m := urlAttr.FindSubmatch(attr)
+			name, quote, value := string(m[1]), m[2][0], string(m[2][1:len(m[2])-1])
+			if strings.HasSuffix(strings.ToLower(strings.TrimSpace(name)), "srcset=") {
+				value = absoluteSrcset(value, base)
+			} else {
+				value = absoluteURL(value, base)
+			}
+			return []byte(name + string(quote) + value + string(quote))
+		}))
+
+		// Skip raw text elements, whose content is not markup
+		for _, raw := range []string{"script", "style"} {
+			if rawTag(tag, raw) {
+				n := bytes.Index(bytes.ToLower(html[i:]), []byte("</"+raw))
+				if n == -1 {
+					n = len(html) - i
+				}
+				out.Write(html[i : i+n])
+				i += n
+			}
+		}
+	}
+	return out.Bytes()
+}
+
+// absoluteURL prefixes a root-relative URL with base
+func absoluteURL(url, base string) string {
+	trimmed := strings.TrimSpace(url)
+	if !strings.HasPrefix(trimmed, "/") || strings.HasPrefix(trimmed, "//") {
+		return url
+	}
+	return base + trimmed
+}
+
+// absoluteSrcset prefixes the root-relative URLs of a srcset's comma-separated candidates with base
+func absoluteSrcset(srcset, base string) string {
+	candidates := strings.Split(srcset, ",")
+	for i, candidate := range candidates {
+		fields := strings.Fields(candidate)
+		if len(fields) == 0 {
+			continue
+		}
+		fields[0] = absoluteURL(fields[0], base)
+		candidates[i] = strings.Join(fields, " ")
+	}
+	return strings.Join(candidates, ", ")
+}
+
+// tagEnd returns the index after the markup starting with < at start: a tag, ignoring > inside quoted
+// attribute values, or a comment; -1 when it isn't closed
+func tagEnd(html []byte, start int) int {
+	if bytes.HasPrefix(html[start:], []byte("<!--")) {
+		end := bytes.Index(html[start:], []byte("-->"))
+		if end == -1 {
+			return -1
+		}
+		return start + end + 3
+	}
+
+	var quote byte
+	for j := start + 1; j < len(html); j++ {
+		switch c := html[j]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return j + 1
+		}
+	}
+	return -1
+}
+
+// isTagStart reports whether c, following a <, starts a tag, end tag, comment, or doctype
+func isTagStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '/' || c == '!'
+}
+
+// rawTag reports whether tag is a start tag of the named element
+func rawTag(tag []byte, name string) bool {
+	lower := bytes.ToLower(tag)
+	if !bytes.HasPrefix(lower, []byte("<"+name)) || len(lower) <= len(name)+1 {
+		return false
+	}
+	c := lower[len(name)+1]
+	return c == '>' || c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '/'
+}
@@ -0,0 +1,46 @@
+package builder
+
+import "testing"
+
+func TestAbsoluteURLs(t *testing.T) {
+	const base = "https://example.com/"
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"href", `<a href="/blog/post.html">x</a>`, `<a href="https://example.com/blog/post.html">x</a>`},
+		{"src", `<img src='/img/a.png' alt="">`, `<img src='https://example.com/img/a.png' alt="">`},
+		{"srcset", `<img srcset="/a.png 1x, /b.png 2x,img/c.png 3x">`, `<img srcset="https://example.com/a.png 1x, https://example.com/b.png 2x, img/c.png 3x">`},
+		{"uppercase attribute", `<LINK HREF="/css/styles.css">`, `<LINK HREF="https://example.com/css/styles.css">`},
+		{"mailto", `<a href="mailto:me@example.com">`, `<a href="mailto:me@example.com">`},
+		{"fragment", `<a href="#top">`, `<a href="#top">`},
+		{"relative", `<a href="post.html">`, `<a href="post.html">`},
+		{"protocol-relative", `<script src="//cdn.example.net/a.js"></script>`, `<script src="//cdn.example.net/a.js"></script>`},
+		{"absolute", `<a href="https://other.org/">`, `<a href="https://other.org/">`},
+		{"text", `<p>href="/x" and 1 < 2</p>`, `<p>href="/x" and 1 < 2</p>`},
+		{"comment", `<!-- <a href="/x"> -->`, `<!-- <a href="/x"> -->`},
+		{"script content", `<script>el.innerHTML = '<a href="/x">'</script>`, `<script>el.innerHTML = '<a href="/x">'</script>`},
+		{"quoted >", `<a title="a > b" href="/x">`, `<a title="a > b" href="https://example.com/x">`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(absoluteURLs([]byte(tt.html), base)); got != tt.want {
+				t.Errorf("got  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBaseURL(t *testing.T) {
+	files := mustBuild(t, map[string]string{
+		"blueprints/blog/post.blueprint": "1 page\n",
+		"components/page/page.html":      `<a href="/index.html">home</a> <a href="#top">top</a>`,
+	}, Options{BaseURL: "https://example.com"})
+
+	want := `<a href="https://example.com/index.html">home</a> <a href="#top">top</a>`
+	if got := string(files["blog/post.html"]); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
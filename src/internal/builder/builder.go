@@ -1,131 +1,575 @@
 package builder
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"maps"
+	"os"
 	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 
+	"webfactory/src/internal/assets"
 	"webfactory/src/internal/blueprint"
 	"webfactory/src/internal/component"
+	"webfactory/src/internal/plaintext"
 	"webfactory/src/internal/storage"
 	"webfactory/src/internal/template"
+	"webfactory/src/internal/version"
 )
 
+// Options controls optional build behavior
+type Options struct {
+	Generator     bool // Stamp a meta generator tag with the webfactory version into every page
+	HeadTags      bool // Generate title and meta tags from blueprint front matter
+	Assets        assets.Options
+	Progress      func(format string, args ...any) // Receives per-blueprint and per-file progress, may be nil
+	Feed          FeedOptions
+	Filters       map[string]template.FilterFunc  // Custom template filters, checked with template.CheckFilters
+	Env           []string                        // Environment variables templates may read, all others are refused
+	BasePath      string                          // URL path the site is served under, like /docs/; empty for relative asset URLs
+	SCSS          component.SCSSCompiler          // Compiles component .scss files, may be nil
+	Data          map[string]blueprint.DataParser // Parsers of .data files by extension, added to the built-in ones
+	Redirects     bool                            // Write pages identical to another as redirects to it
+	BaseURL       string                          // Absolute site URL that root-relative links are rewritten under, empty to leave them
+	ErrorPage     string                          // Blueprint, relative to blueprints/, built as 404.html instead of its own page
+	FailOnWarning bool                            // Fail the build, once all pages are written, when templates reported warnings
+	StrictVars    bool                            // Fail pages using undefined variables instead of warning about them
+	PageCSS       bool                            // Give each page its own stylesheet, css/<page>.css, instead of css/styles.css
+}
+
+// errorPage is the output path of the not-found page, built from 404.blueprint unless ErrorPage is set
+const errorPage = "404"
+
 // Builder orchestrates the site generation process
 type Builder struct {
-	store *storage.Storage
+	source   storage.Source
+	sink     storage.Sink
+	opts     Options
+	globals  map[string][]string
+	env      map[string]string   // values of the allowed environment variables, read when the build starts
+	registry *component.Registry // components loaded in the current build, shared by its pages
+	names    *assets.Names       // script output names claimed in the current build
+	sheets   map[string]string   // page stylesheet name -> page output path, with PageCSS
+	tokens   *template.Tokens    // component templates tokenized in the current build
+	proc     *template.Processor // renders the pages of the current build in turn, reset for each
+	rendered bytes.Buffer        // the page being rendered, before its asset tags; reused for each
+	written  map[string]int      // output path -> size of the last write in the current build
+	pages    map[string]page     // page output path -> page with its feed metadata, for the current build
+	held     map[string][]byte   // HTML file path -> page held back until all pages are built, with Redirects
+	warnings []string            // template warnings of the current build, prefixed with their blueprint
 }
 
-// New creates a new Builder instance
-func New(sourcePath, outputPath string) *Builder {
-	store := storage.New(sourcePath, outputPath)
+// PanicError is a panic raised while building one blueprint, recovered so the other pages still build
+type PanicError struct {
+	Source string // blueprint, or component when linting, that was being processed
+	Value  any
+	Stack  []byte // stack trace of the panicking goroutine
+}
 
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// BuildStats summarizes a completed build
+type BuildStats struct {
+	Pages    int           // Blueprints built into pages
+	CSS      int           // Unique stylesheet files emitted
+	JS       int           // Unique script files emitted
+	Bytes    int           // Total size of the emitted pages and assets, without compressed copies
+	Duration time.Duration // Wall-clock time of the build
+	Warnings []string      // Template warnings, such as undefined variables, by blueprint
+}
+
+// New creates a new Builder instance building the site in source into sink
+func New(source storage.Source, sink storage.Sink, opts Options) *Builder {
 	return &Builder{
-		store: store,
+		source: source,
+		sink:   sink,
+		opts:   opts,
 	}
 }
 
-// Build processes all blueprints and generates the site
-func (b *Builder) Build() error {
+// Build processes all blueprints and generates the site, returning what it emitted
+// Cancelling ctx stops the build between blueprints with the context's error; pages already written are kept.
+func (b *Builder) Build(ctx context.Context) (BuildStats, error) {
+	start := time.Now()
+	b.written = make(map[string]int)
+	b.pages = make(map[string]page)
+	b.held = make(map[string][]byte)
+	b.warnings = nil
+
+	// Components can't change during a build, so each is loaded once for all pages
+	b.registry = b.newRegistry()
+	b.names = assets.NewNames()
+	b.sheets = make(map[string]string)
+	b.tokens = template.NewTokens()
+	b.proc = nil
+
+	if err := b.checkOptions(); err != nil {
+		return BuildStats{}, err
+	}
+	if err := b.loadGlobals(); err != nil {
+		return BuildStats{}, err
+	}
+
 	// Get list of blueprints
-	blueprints, err := b.store.ListBlueprints()
+	blueprints, err := b.listBlueprints(ctx)
 	if err != nil {
-		return fmt.Errorf("finding blueprints: %w", err)
+		return BuildStats{}, err
 	}
 
-	// Process each blueprint
+	// Process each blueprint, a panicking one failing the build only after the others are written
+	var panics []error
 	for path, outputRel := range blueprints {
-		if err := b.processBlueprint(path, outputRel); err != nil {
-			return fmt.Errorf("processing blueprint %s: %w", path, err)
+		err := b.processBlueprint(ctx, path, outputRel)
+		if err == nil {
+			continue
+		}
+		err = fmt.Errorf("processing blueprint %s: %w", path, err)
+		var panicErr *PanicError
+		if !errors.As(err, &panicErr) {
+			return BuildStats{}, err
 		}
+		panics = append(panics, err)
+	}
+	if len(panics) > 0 {
+		return BuildStats{}, errors.Join(panics...)
+	}
+
+	if err := b.writePages(); err != nil {
+		return BuildStats{}, err
 	}
 
+	if err := b.writeFeed(); err != nil {
+		return BuildStats{}, fmt.Errorf("writing feed: %w", err)
+	}
+
+	sort.Strings(b.warnings)
+	if b.opts.FailOnWarning && len(b.warnings) > 0 {
+		errs := make([]error, len(b.warnings))
+		for i, w := range b.warnings {
+			errs[i] = errors.New(w)
+		}
+		return BuildStats{}, fmt.Errorf("failing on %d warnings: %w", len(b.warnings), errors.Join(errs...))
+	}
+
+	stats := BuildStats{Pages: len(b.pages), Warnings: b.warnings}
+	for path, size := range b.written {
+		switch filepath.Ext(path) {
+		case ".css":
+			stats.CSS++
+		case ".js":
+			stats.JS++
+		}
+		stats.Bytes += size
+	}
+	stats.Duration = time.Since(start)
+
+	return stats, nil
+}
+
+// checkOptions rejects asset options the pages of a build can't be written with
+// Every page rewrites the shared stylesheet, so only a page's own stylesheet can have a map that matches it.
+func (b *Builder) checkOptions() error {
+	if b.opts.Assets.SourceMap && !b.opts.PageCSS {
+		return fmt.Errorf("a CSS source map needs PageCSS, each page writing its own stylesheet")
+	}
+	return b.opts.Assets.Validate()
+}
+
+// loadGlobals reads the optional site-wide variables and the allowed environment variables
+func (b *Builder) loadGlobals() error {
+	b.env = make(map[string]string, len(b.opts.Env))
+	for _, name := range b.opts.Env {
+		b.env[name] = os.Getenv(name)
+	}
+
+	b.globals = nil
+	globals, err := b.source.ReadGlobals()
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("reading globals: %w", err)
+	}
+	if err == nil {
+		b.globals = blueprint.ParseVars(string(globals))
+	}
 	return nil
 }
 
+// progress reports build progress to the Progress callback, if any
+func (b *Builder) progress(format string, args ...any) {
+	if b.opts.Progress != nil {
+		b.opts.Progress(format, args...)
+	}
+}
+
 // processBlueprint handles a single blueprint file
-func (b *Builder) processBlueprint(path, outputRel string) error {
-	// Read and parse blueprint
-	content, err := b.store.ReadBlueprint(path)
+func (b *Builder) processBlueprint(ctx context.Context, path, outputRel string) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	defer recoverPanic(path, &err)
+
+	b.progress("building %s", path)
+
+	tree, pages, err := b.loadBlueprint(path, outputRel)
 	if err != nil {
-		return fmt.Errorf("reading blueprint: %w", err)
+		return err
 	}
+	for _, page := range pages {
+		if _, exists := b.pages[page.outputRel]; exists {
+			return fmt.Errorf("page %s is also built from another blueprint", filepath.ToSlash(page.outputRel)+".html")
+		}
+		result, err := b.render(tree, page.outputRel, page.globals, nil)
+		if err != nil {
+			return err
+		}
+		// A collection item's fields describe its page in the feed over the blueprint's front matter
+		page.meta = maps.Clone(page.meta)
+		if page.meta == nil {
+			page.meta = make(map[string]string, len(result.Meta))
+		}
+		for key, value := range result.Meta {
+			if _, exists := page.meta[key]; !exists {
+				page.meta[key] = value
+			}
+		}
+		b.pages[page.outputRel] = page
+		// Warnings repeated by several pages of a collection are listed once
+		for _, w := range result.Warnings {
+			if w := fmt.Sprintf("%s: %v", path, w); !slices.Contains(b.warnings, w) {
+				b.warnings = append(b.warnings, w)
+			}
+		}
 
-	registry := component.New(b.store)
-	processor := template.New(registry)
+		// Write output files
+		if err := b.writeOutput(page.outputRel, result); err != nil {
+			return fmt.Errorf("writing output: %w", err)
+		}
+	}
 
-	tree, err := blueprint.New(string(content))
+	// processor.Cleanup()
+	// registry.Cleanup()
+	return nil
+}
+
+// listBlueprints lists the page blueprints and their output paths, the ErrorPage blueprint going to the
+// error page
+func (b *Builder) listBlueprints(ctx context.Context) (map[string]string, error) {
+	blueprints, err := b.source.ListBlueprints(ctx)
 	if err != nil {
-		return fmt.Errorf("parsing blueprint: %w", err)
+		return nil, fmt.Errorf("finding blueprints: %w", err)
 	}
+	if b.opts.ErrorPage != "" {
+		// A partial can be the error page too, it then isn't listed
+		blueprints[filepath.FromSlash(b.opts.ErrorPage)] = errorPage
+	}
+	return blueprints, nil
+}
 
-	// Load components referenced in blueprint
-	var loadComponents func(*blueprint.Node) error
-	loadComponents = func(node *blueprint.Node) error {
+// recoverPanic turns a panic in the deferring function into a PanicError stored in err
+func recoverPanic(source string, err *error) {
+	if v := recover(); v != nil {
+		*err = &PanicError{Source: source, Value: v, Stack: debug.Stack()}
+	}
+}
+
+// loadBlueprint parses a blueprint and lists the pages it produces: its own, one per collection item,
+// the numbered pages of a paginated list, or a page per taxonomy term
+func (b *Builder) loadBlueprint(path, outputRel string) (*blueprint.Node, []page, error) {
+	// Read and parse blueprint, resolving includes
+	tree, err := blueprint.Load(path, b.source.ReadBlueprint)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing blueprint: %w", err)
+	}
+	if err := b.loadData(tree); err != nil {
+		return nil, nil, err
+	}
+
+	var pages []page
+	switch {
+	case tree.Meta[collectionKey] != "" && tree.Meta[paginateKey] != "",
+		tree.Meta[collectionKey] != "" && tree.Meta[taxonomyKey] != "",
+		tree.Meta[paginateKey] != "" && tree.Meta[taxonomyKey] != "":
+		return nil, nil, fmt.Errorf("a blueprint can have only one of %s, %s, and %s", collectionKey, paginateKey, taxonomyKey)
+	case tree.Meta[collectionKey] != "":
+		pages, err = b.collectionPages(tree, outputRel)
+	case tree.Meta[paginateKey] != "":
+		pages, err = b.paginatedPages(tree, outputRel)
+	case tree.Meta[taxonomyKey] != "":
+		pages, err = b.taxonomyPages(tree, outputRel)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if pages == nil {
+		pages = []page{{outputRel: outputRel, globals: b.globals}}
+	}
+	return tree, pages, nil
+}
+
+// loadData adds the fields of the data files named by .data to their blocks' variables
+// Variables the blueprint sets on the block take precedence over the file's fields.
+func (b *Builder) loadData(node *blueprint.Node) error {
+	if data := node.Block.Data; data != "" {
+		if !fs.ValidPath(data) {
+			return fmt.Errorf("block %s: data path %s must be relative to the source root", node.Block.Path, data)
+		}
+		content, err := b.source.ReadData(data)
+		if err != nil {
+			return fmt.Errorf("block %s: reading data: %w", node.Block.Path, err)
+		}
+		parse, err := blueprint.FindDataParser(data, b.opts.Data)
+		if err != nil {
+			return fmt.Errorf("block %s: %w", node.Block.Path, err)
+		}
+		vars, err := parse(content)
+		if err != nil {
+			return fmt.Errorf("block %s: data %s: %w", node.Block.Path, data, err)
+		}
+		maps.Copy(vars, node.Block.Vars)
+		node.Block.Vars = vars
+	}
+
+	for _, child := range node.Children {
+		if err := b.loadData(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// render loads the components of a blueprint tree and assembles the page
+// The globals are the page's lowest-precedence variables. If used is not nil, the tree's blocks and the
+// components they include are added to it.
+func (b *Builder) render(tree *blueprint.Node, outputRel string, globals map[string][]string, used map[string]bool) (*template.ProcessResult, error) {
+	registry := b.registry
+	stylesheet, err := b.pageStylesheet(outputRel)
+	if err != nil {
+		return nil, err
+	}
+	opts := template.Options{
+		Globals:     globals,
+		HeadTags:    b.opts.HeadTags,
+		AssetPrefix: b.assetPrefix(outputRel),
+		Assets:      b.assetOptions(stylesheet),
+		Filters:     b.opts.Filters,
+		Env:         b.env,
+		Tokens:      b.tokens,
+		StrictVars:  b.opts.StrictVars,
+	}
+	if b.proc == nil {
+		b.proc = template.New(registry, opts)
+	} else {
+		b.proc.Reset(opts)
+	}
+	processor := b.proc
+
+	// Load components referenced in blueprint, collecting every failure rather than stopping at the first
+	var loadErrs []error
+	var loadComponents func(*blueprint.Node)
+	loadComponents = func(node *blueprint.Node) {
 		if node == nil {
-			return nil
+			return
 		}
 
 		if node.Block.ID != -1 {
 			_, err := registry.Load(node.Block.Path)
+			if used != nil {
+				markUsed(registry, node.Block.Path, used)
+			}
 			if err != nil {
-				return fmt.Errorf("loading component %s: %w", node.Block.Path, err)
+				loadErrs = append(loadErrs, loadError(node.Block.Path, err))
 			}
 		}
 
 		for _, child := range node.Children {
-			if err := loadComponents(child); err != nil {
-				return err
-			}
+			loadComponents(child)
 		}
-		return nil
 	}
 
-	if err := loadComponents(tree); err != nil {
-		return fmt.Errorf("loading components: %w", err)
+	loadComponents(tree)
+	if len(loadErrs) > 0 {
+		return nil, fmt.Errorf("loading components: %w", errors.Join(loadErrs...))
 	}
 
-	// Process template
-	result, err := processor.Assembler(tree)
-	if err != nil {
-		return fmt.Errorf("processing template: %w", err)
+	// Render the page into the reused buffer, then put in its asset tags; template errors already say so
+	b.rendered.Reset()
+	if err := processor.ProcessTo(&b.rendered, tree); err != nil {
+		return nil, fmt.Errorf("processing template: %w", err)
 	}
+	return processor.Assemble(b.rendered.Bytes())
+}
 
-	// Write output files
-	if err := b.writeOutput(outputRel, result); err != nil {
-		return fmt.Errorf("writing output: %w", err)
+// loadError wraps the error loading a block's component
+// A missing component named without dots may be meant as an alias its blueprint doesn't declare, so it says so.
+func loadError(path string, err error) error {
+	var notFound *component.NotFoundError
+	if errors.As(err, &notFound) && notFound.Path == path && !strings.Contains(path, ".") {
+		return fmt.Errorf("loading component %s, which is no alias its blueprint declares: %w", path, err)
 	}
+	return fmt.Errorf("loading component %s: %w", path, err)
+}
 
-	// processor.Cleanup()
-	// registry.Cleanup()
-	return nil
+// newRegistry creates the component registry of a build, finding includes as templates are tokenized
+func (b *Builder) newRegistry() *component.Registry {
+	return component.New(b.source, component.Options{SCSS: b.opts.SCSS, Includes: template.Includes})
+}
+
+// markUsed adds a component and, once loaded, everything it includes to used
+func markUsed(registry *component.Registry, path string, used map[string]bool) {
+	if used[path] {
+		return
+	}
+	used[path] = true
+
+	if comp := registry.Get(path); comp != nil {
+		for _, include := range comp.Includes {
+			markUsed(registry, include, used)
+		}
+	}
+}
+
+// assetOptions returns the asset options of a page linking the named stylesheet, sharing the build's
+// script names
+func (b *Builder) assetOptions(stylesheet string) assets.Options {
+	opts := b.opts.Assets
+	opts.Names = b.names
+	opts.Stylesheet = stylesheet
+	return opts
+}
+
+// pageStylesheet returns the name of a page's own stylesheet with PageCSS, its output path with slashes
+// turned into dashes, or empty for the shared one
+// Two pages whose paths give the same name, like blog/post and blog-post, are an error.
+func (b *Builder) pageStylesheet(outputRel string) (string, error) {
+	if !b.opts.PageCSS || outputRel == "" {
+		return "", nil
+	}
+	name := strings.ReplaceAll(filepath.ToSlash(outputRel), "/", "-")
+	if other, taken := b.sheets[name]; taken && other != outputRel {
+		return "", fmt.Errorf("pages %s and %s would both link css/%s.css", other, outputRel, name)
+	}
+	b.sheets[name] = outputRel
+	return name, nil
+}
+
+// assetPrefix returns the URL prefix leading from a page to the site root: the base path when one
+// is set, otherwise a relative path
+// The error page is served in place of any missing URL, so it always gets an absolute prefix.
+func (b *Builder) assetPrefix(outputRel string) string {
+	if base := strings.Trim(b.opts.BasePath, "/"); base != "" {
+		return "/" + base + "/"
+	}
+	if b.opts.BasePath != "" || outputRel == errorPage {
+		return "/"
+	}
+	return rootPrefix(outputRel)
+}
+
+// rootPrefix returns the relative path from a page in a subdirectory back to the site root
+func rootPrefix(outputRel string) string {
+	depth := strings.Count(filepath.ToSlash(outputRel), "/")
+	return strings.Repeat("../", depth)
 }
 
 // writeOutput writes all generated files to disk
-func (b *Builder) writeOutput(outputRel string, result *template.ProcessResult) error {
+func (b *Builder) writeOutput(outputPath string, result *template.ProcessResult) error {
 	files := make(map[string][]byte)
 
-	// Strip the blueprints/ prefix if present and get base output path
-	outputPath := strings.TrimPrefix(outputRel, "blueprints/")
-
 	// Add main HTML file
-	files[outputPath+".html"] = result.HTML
+	html := result.HTML
+	if b.opts.Generator {
+		html = stampGenerator(html)
+	}
+	if b.opts.BaseURL != "" {
+		html = absoluteURLs(html, b.opts.BaseURL)
+	}
+	// With Redirects, pages are written once all are built and duplicates are known
+	if b.opts.Redirects {
+		b.held[outputPath+".html"] = html
+	} else {
+		files[outputPath+".html"] = html
+	}
+
+	// Additional formats requested in front matter are rendered from the final page
+	formats, err := outputFormats(result.Meta)
+	if err != nil {
+		return err
+	}
+	if formats["text"] {
+		files[outputPath+".txt"] = []byte(plaintext.Render(result.HTML))
+	}
 
 	// Add asset files to appropriate directories
 	for name, content := range result.Files {
 		var dir string
-		switch filepath.Ext(name) {
-		case ".css":
+		switch ext := filepath.Ext(strings.TrimSuffix(name, ".map")); {
+		case ext == ".css":
 			dir = "css"
-		case ".js":
+		case ext == ".js":
 			dir = "js"
+		case slices.Contains(component.ImageExts, ext):
+			dir = assets.ImageDir
 		default:
 			dir = "assets"
 		}
 		files[filepath.Join(dir, name)] = content
 	}
 
-	// Write all files
-	targetPath := b.store.GetTargetPath()
-	return b.store.WriteOutput(targetPath, files)
+	return b.write(files)
+}
+
+// write writes files to the sink, recording them for the build statistics
+func (b *Builder) write(files map[string][]byte) error {
+	paths := make([]string, 0, len(files))
+	for path, content := range files {
+		b.written[path] = len(content)
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		b.progress("  writing %s (%d bytes)", path, len(files[path]))
+		if err := b.sink.Write(path, files[path]); err != nil {
+			return fmt.Errorf("writing %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// outputFormats parses the "formats" front matter key, a comma-separated list of extra formats to write
+func outputFormats(meta map[string]string) (map[string]bool, error) {
+	formats := make(map[string]bool)
+	for _, format := range strings.Split(meta["formats"], ",") {
+		switch format = strings.TrimSpace(format); format {
+		case "":
+		case "text":
+			formats[format] = true
+		default:
+			return nil, fmt.Errorf("unknown output format %q", format)
+		}
+	}
+	return formats, nil
+}
+
+// stampGenerator inserts the generator meta tag at the start of the page head, or at the top without one
+func stampGenerator(html []byte) []byte {
+	tag := []byte(version.GeneratorTag())
+
+	if start := bytes.Index(html, []byte("<head")); start != -1 {
+		if end := bytes.IndexByte(html[start:], '>'); end != -1 {
+			pos := start + end + 1
+			stamped := make([]byte, 0, len(html)+len(tag))
+			stamped = append(stamped, html[:pos]...)
+			stamped = append(stamped, tag...)
+			return append(stamped, html[pos:]...)
+		}
+	}
+	return append(tag, html...)
 }
\ No newline at end of file
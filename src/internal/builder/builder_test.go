@@ -0,0 +1,850 @@
+package builder
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"webfactory/src/internal/assets"
+	"webfactory/src/internal/blueprint"
+	"webfactory/src/internal/storage"
+)
+
+// memorySource returns an in-memory source of files given by their path below the source root
+func memorySource(files map[string]string) *storage.Memory {
+	source := make(map[string][]byte, len(files))
+	for name, content := range files {
+		source[name] = []byte(content)
+	}
+	return storage.NewMemory(source)
+}
+
+// buildSite builds a site from files given by their path below the source root into memory
+func buildSite(t *testing.T, files map[string]string, opts Options) (*storage.MemorySink, BuildStats, error) {
+	t.Helper()
+	sink := storage.NewMemorySink()
+	stats, err := New(memorySource(files), sink, opts).Build(context.Background())
+	return sink, stats, err
+}
+
+// mustBuild is buildSite for sites expected to build, returning their files
+func mustBuild(t *testing.T, files map[string]string, opts Options) map[string][]byte {
+	t.Helper()
+	sink, _, err := buildSite(t, files, opts)
+	if err != nil {
+		t.Fatalf("building: %v", err)
+	}
+	return sink.Files()
+}
+
+func TestGlobals(t *testing.T) {
+	files := mustBuild(t, map[string]string{
+		"globals.vars":               "# site-wide\n.site = Example\n.author = Ann\n",
+		"blueprints/index.blueprint": "1 page\n",
+		"blueprints/about.blueprint": "1 page\n  .author=Bob\n",
+		"components/page/page.html":  "{{.site}} by {{.author}}",
+	}, Options{})
+
+	for page, want := range map[string]string{"index.html": "Example by Ann", "about.html": "Example by Bob"} {
+		if got := string(files[page]); got != want {
+			t.Errorf("%s = %q, want %q", page, got, want)
+		}
+	}
+}
+
+func TestNestedPages(t *testing.T) {
+	files := mustBuild(t, map[string]string{
+		"blueprints/docs/api.blueprint":         "1 page\n",
+		"blueprints/docs/api/v2/auth.blueprint": "1 page\n",
+		"components/page/page.html":             "{{styles}}",
+		"components/page/page.css":              "p { margin: 0; }",
+	}, Options{})
+
+	for page, want := range map[string]string{
+		"docs/api.html":         `<link rel="stylesheet" href="../css/styles.css">`,
+		"docs/api/v2/auth.html": `<link rel="stylesheet" href="../../../css/styles.css">`,
+	} {
+		if got := string(files[page]); got != want {
+			t.Errorf("%s = %q, want %q", page, got, want)
+		}
+	}
+}
+
+func TestIndexPages(t *testing.T) {
+	sink, _, err := buildSite(t, map[string]string{
+		"blueprints/index.blueprint":      "1 page\n  .name=home\n",
+		"blueprints/about.blueprint":      "1 page\n  .name=about\n",
+		"blueprints/blog/index.blueprint": "1 page\n  .name=blog\n",
+		"blueprints/blog/first.blueprint": "1 page\n  .name=first\n",
+		"components/page/page.html":       "{{.name}}",
+	}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"index.html": "home", "about.html": "about", "blog/index.html": "blog", "blog/first.html": "first"}
+	if got := sink.Paths(); len(got) != len(want) {
+		t.Errorf("wrote %v, want the %d pages", got, len(want))
+	}
+	for page, name := range want {
+		if got := string(sink.Files()[page]); got != name {
+			t.Errorf("%s = %q, want %q", page, got, name)
+		}
+	}
+}
+
+func TestBuildStats(t *testing.T) {
+	sink, stats, err := buildSite(t, map[string]string{
+		"blueprints/index.blueprint": "1 page\n1.1 ui.btn\n",
+		"blueprints/about.blueprint": "1 page\n  .name=x\n",
+		"components/page/page.html":  "<main>{{component}}</main>{{styles}}{{script}}",
+		"components/page/page.css":   "main { margin: 0; }",
+		"components/ui/btn/btn.html": "<button>{{.name}}</button>",
+		"components/ui/btn/btn.js":   "go();",
+		"components/ui/btn/other.js": "stop();",
+	}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var bytes int
+	for _, content := range sink.Files() {
+		bytes += len(content)
+	}
+	want := BuildStats{Pages: 2, CSS: 1, JS: 2, Bytes: bytes}
+	if stats.Pages != want.Pages || stats.CSS != want.CSS || stats.JS != want.JS || stats.Bytes != want.Bytes {
+		t.Errorf("got %+v, want %+v", stats, want)
+	}
+	if stats.Duration <= 0 {
+		t.Errorf("duration %v not measured", stats.Duration)
+	}
+	if len(stats.Warnings) != 1 || !strings.HasPrefix(stats.Warnings[0], "index.blueprint: ") {
+		t.Errorf("warnings %q, want the undefined .name of index.blueprint", stats.Warnings)
+	}
+}
+
+func TestTextFormat(t *testing.T) {
+	files := mustBuild(t, map[string]string{
+		"blueprints/news.blueprint":  "---\nformats = text\n---\n1 page\n",
+		"blueprints/about.blueprint": "1 page\n",
+		"components/page/page.html":  `<h1>News</h1><p>See <a href="/blog">the blog</a>.</p>`,
+	}, Options{})
+
+	if got, want := string(files["news.txt"]), "News\n\nSee the blog (/blog).\n"; got != want {
+		t.Errorf("news.txt = %q, want %q", got, want)
+	}
+	if _, ok := files["about.txt"]; ok {
+		t.Error("about.txt written without the text format")
+	}
+}
+
+func TestBuildInMemory(t *testing.T) {
+	sink, stats, err := buildSite(t, map[string]string{
+		"globals.vars":                  ".site = Tiny\n",
+		"blueprints/index.blueprint":    "1 layout\n1.1 ui.card\n  .title=Hello\n",
+		"blueprints/_nav.blueprint":     "1 layout\n",
+		"components/layout/layout.html": "<title>{{.site}}</title>{{styles}}{{component}}{{script}}",
+		"components/ui/card/card.html":  "<div class=\"card\">{{.title}}</div>",
+		"components/ui/card/card.css":   ".card { padding: 1rem; }",
+		"components/ui/card/card.js":    "console.log('card')",
+	}, Options{})
+	if err != nil {
+		t.Fatalf("building: %v", err)
+	}
+
+	if got := strings.Join(sink.Paths(), " "); got != "css/styles.css index.html js/ui-card-card.js" {
+		t.Errorf("wrote %s", got)
+	}
+	files := sink.Files()
+	html := string(files["index.html"])
+	for _, want := range []string{"<title>Tiny</title>", `href="css/styles.css"`, "<div class=\"card\">Hello</div>", `src="js/ui-card-card.js"`} {
+		if !strings.Contains(html, want) {
+			t.Errorf("index.html does not contain %q:\n%s", want, html)
+		}
+	}
+	if css := string(files["css/styles.css"]); !strings.Contains(css, ".card { padding: 1rem; }") {
+		t.Errorf("styles.css = %q", css)
+	}
+	if stats.Pages != 1 {
+		t.Errorf("built %d pages, want 1", stats.Pages)
+	}
+}
+
+func TestBuildFromFSMatchesDisk(t *testing.T) {
+	site := map[string]string{
+		"globals.vars":                  ".site = Embedded\n",
+		"blueprints/index.blueprint":    "1 layout\n1.1 ui.card\n  .title=Home\n",
+		"blueprints/docs/a.blueprint":   "@include _body.blueprint\n",
+		"blueprints/_body.blueprint":    "1 layout\n1.1 ui.card\n  .title=Docs\n",
+		"components/layout/layout.html": "<title>{{.site}}</title>{{styles}}{{component}}",
+		"components/ui/card/card.html":  "<div class=\"card\">{{.title}}</div>",
+		"components/ui/card/card.css":   ".card { padding: 1rem; }",
+	}
+
+	fsys := make(fstest.MapFS, len(site))
+	root := t.TempDir()
+	for name, content := range site {
+		fsys[name] = &fstest.MapFile{Data: []byte(content)}
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	build := func(source storage.Source) map[string][]byte {
+		sink := storage.NewMemorySink()
+		if _, err := New(source, sink, Options{}).Build(context.Background()); err != nil {
+			t.Fatalf("building: %v", err)
+		}
+		return sink.Files()
+	}
+	fromFS := build(storage.NewFS(fsys))
+	fromDisk := build(storage.NewDisk([]string{root}, "", storage.DiskOptions{}))
+
+	if len(fromFS) != 3 || !maps.EqualFunc(fromFS, fromDisk, bytes.Equal) {
+		t.Errorf("fs.FS build %q differs from disk build %q", slices.Sorted(maps.Keys(fromFS)), slices.Sorted(maps.Keys(fromDisk)))
+	}
+}
+
+func TestSinkCapturesAllOutputs(t *testing.T) {
+	sink, stats, err := buildSite(t, map[string]string{
+		"blueprints/index.blueprint":   "1 card\n",
+		"blueprints/blog/a.blueprint":  "---\ntitle = A\ndate = 2024-01-01\nformats = text\n---\n1 card\n",
+		"components/card/card.html":    `<div class="card"><img src="img/logo.png">{{styles}}{{script}}</div>`,
+		"components/card/card.css":     ".card { padding: 1rem; }",
+		"components/card/card.js":      "console.log('card')",
+		"components/card/img/logo.png": "png",
+	}, Options{Feed: FeedOptions{Collection: "blog", BaseURL: "https://example.com"}})
+	if err != nil {
+		t.Fatalf("building: %v", err)
+	}
+
+	want := []string{"blog/a.html", "blog/a.txt", "css/styles.css", "feed.xml", "img/card-img-logo.png", "index.html", "js/card-card.js"}
+	if got := sink.Paths(); !slices.Equal(got, want) {
+		t.Errorf("sink received %q, want %q", got, want)
+	}
+	size := 0
+	for _, content := range sink.Files() {
+		size += len(content)
+	}
+	if stats.Bytes != size {
+		t.Errorf("stats count %d bytes, the sink received %d", stats.Bytes, size)
+	}
+}
+
+func TestBuildCancelled(t *testing.T) {
+	source := map[string][]byte{"components/page/page.html": []byte("<p>{{.n}}</p>")}
+	for i := range 5 {
+		source[fmt.Sprintf("blueprints/p%d.blueprint", i)] = []byte(fmt.Sprintf("1 page\n  .n=%d\n", i))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	built := 0
+	sink := storage.NewMemorySink()
+	_, err := New(storage.NewMemory(source), sink, Options{
+		Progress: func(format string, args ...any) {
+			// Cancel while the first blueprint builds, as a new change would in watch mode
+			if strings.HasPrefix(format, "building") {
+				built++
+				cancel()
+			}
+		},
+	}).Build(ctx)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want context.Canceled", err)
+	}
+	if built != 1 || len(sink.Paths()) != 1 {
+		t.Errorf("built %d blueprints writing %q after cancelling, want to stop after the first", built, sink.Paths())
+	}
+}
+
+func TestBuildCancelledBeforeStart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	sink := storage.NewMemorySink()
+	_, err := New(storage.NewMemory(map[string][]byte{
+		"blueprints/index.blueprint": []byte("1 page\n"),
+		"components/page/page.html":  []byte("<p></p>"),
+	}), sink, Options{}).Build(ctx)
+
+	if !errors.Is(err, context.Canceled) || len(sink.Paths()) != 0 {
+		t.Errorf("got error %v writing %q, want context.Canceled and nothing written", err, sink.Paths())
+	}
+}
+
+// panickingSource is a Source that panics reading the files of the boom component
+type panickingSource struct {
+	storage.Source
+}
+
+func (s panickingSource) ReadComponent(componentPath, filename string) ([]byte, error) {
+	if componentPath == "boom" {
+		var index []int
+		return []byte(fmt.Sprint(index[1])), nil
+	}
+	return s.Source.ReadComponent(componentPath, filename)
+}
+
+func TestBuildRecoversPanic(t *testing.T) {
+	source := panickingSource{memorySource(map[string]string{
+		"blueprints/a.blueprint":    "1 page\n",
+		"blueprints/bad.blueprint":  "1 boom\n",
+		"blueprints/bad2.blueprint": "1 boom\n",
+		"blueprints/z.blueprint":    "1 page\n",
+		"components/page/page.html": "<p>fine</p>",
+		"components/boom/boom.html": "<p>boom</p>",
+	})}
+	sink := storage.NewMemorySink()
+	_, err := New(source, sink, Options{}).Build(context.Background())
+
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("got error %v, want a PanicError", err)
+	}
+	if !strings.HasPrefix(panicErr.Source, "bad") || len(panicErr.Stack) == 0 {
+		t.Errorf("panic from %q with a %d byte stack, want a bad blueprint and its stack", panicErr.Source, len(panicErr.Stack))
+	}
+	for _, want := range []string{
+		"processing blueprint bad.blueprint: panic: runtime error: index out of range",
+		"processing blueprint bad2.blueprint: panic: runtime error: index out of range",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not report %q", err, want)
+		}
+	}
+	if got := strings.Join(sink.Paths(), " "); got != "a.html z.html" {
+		t.Errorf("wrote %s, want the other pages", got)
+	}
+}
+
+func TestEnvVars(t *testing.T) {
+	t.Setenv("WF_TEST_DEPLOY", "staging")
+	t.Setenv("WF_TEST_SECRET", "hunter2")
+	t.Setenv("WF_TEST_EMPTY", "")
+	files := map[string]string{
+		"blueprints/index.blueprint": "1 page\n",
+		"components/page/page.html":  "{{env.WF_TEST_DEPLOY | upper}}[{{env.WF_TEST_EMPTY}}]",
+	}
+	opts := Options{Env: []string{"WF_TEST_DEPLOY", "WF_TEST_EMPTY"}}
+
+	if got := string(mustBuild(t, files, opts)["index.html"]); got != "STAGING[]" {
+		t.Errorf("index.html = %q, want the allowed variables", got)
+	}
+
+	files["components/page/page.html"] = "{{env.WF_TEST_SECRET}}"
+	sink, _, err := buildSite(t, files, opts)
+	if err == nil || !strings.Contains(err.Error(), "[env.WF_TEST_SECRET]: environment variable WF_TEST_SECRET is not allowed") {
+		t.Errorf("got error %v, want WF_TEST_SECRET refused", err)
+	}
+	if page := sink.Files()["index.html"]; strings.Contains(string(page), "hunter2") {
+		t.Errorf("refused variable written: %q", page)
+	}
+}
+
+func TestBasePath(t *testing.T) {
+	site := map[string]string{
+		"blueprints/index.blueprint":      "1 page\n",
+		"blueprints/docs/api/a.blueprint": "1 page\n",
+		"blueprints/404.blueprint":        "1 page\n",
+		"components/page/page.html":       "{{styles}}{{script}}",
+		"components/page/page.css":        "p { margin: 0; }",
+		"components/page/page.js":         "console.log('page')",
+	}
+	tests := []struct {
+		basePath string
+		pages    map[string]string
+	}{
+		{"/myproject/", map[string]string{
+			"index.html":      `<link rel="stylesheet" href="/myproject/css/styles.css"><script src="/myproject/js/page-page.js"></script>`,
+			"docs/api/a.html": `<link rel="stylesheet" href="/myproject/css/styles.css"><script src="/myproject/js/page-page.js"></script>`,
+			"404.html":        `<link rel="stylesheet" href="/myproject/css/styles.css"><script src="/myproject/js/page-page.js"></script>`,
+		}},
+		{"a/b", map[string]string{
+			"docs/api/a.html": `<link rel="stylesheet" href="/a/b/css/styles.css"><script src="/a/b/js/page-page.js"></script>`,
+		}},
+		{"/", map[string]string{
+			"docs/api/a.html": `<link rel="stylesheet" href="/css/styles.css"><script src="/js/page-page.js"></script>`,
+		}},
+		{"", map[string]string{
+			"index.html":      `<link rel="stylesheet" href="css/styles.css"><script src="js/page-page.js"></script>`,
+			"docs/api/a.html": `<link rel="stylesheet" href="../../css/styles.css"><script src="../../js/page-page.js"></script>`,
+			"404.html":        `<link rel="stylesheet" href="/css/styles.css"><script src="/js/page-page.js"></script>`,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.basePath, func(t *testing.T) {
+			files := mustBuild(t, site, Options{BasePath: tt.basePath})
+			for page, want := range tt.pages {
+				if got := string(files[page]); got != want {
+					t.Errorf("%s = %q, want %q", page, got, want)
+				}
+			}
+			// The files themselves stay at the root of the output
+			if _, ok := files["css/styles.css"]; !ok {
+				t.Errorf("stylesheet not written to css/styles.css: %q", slices.Sorted(maps.Keys(files)))
+			}
+		})
+	}
+}
+
+func TestNestedAssetFolders(t *testing.T) {
+	files := mustBuild(t, map[string]string{
+		"blueprints/index.blueprint":                  "1 ui.form\n",
+		"components/ui/form/form.html":                "<form></form>{{styles}}{{script}}",
+		"components/ui/form/css/base.css":             ".form { margin: 0; }",
+		"components/ui/form/css/theme/dark.css":       ".form { color: white; }",
+		"components/ui/form/forms/validate.js":        "validateForm()",
+		"components/ui/form/widgets/validate.js":      "validateWidget()",
+		"components/ui/form/widgets/date/validate.js": "validateDate()",
+	}, Options{})
+
+	for out, want := range map[string]string{
+		"js/ui-form-forms-validate.js":        "validateForm()",
+		"js/ui-form-widgets-validate.js":      "validateWidget()",
+		"js/ui-form-widgets-date-validate.js": "validateDate()",
+	} {
+		if got := string(files[out]); got != want {
+			t.Errorf("%s = %q, want %q", out, got, want)
+		}
+	}
+	css := string(files["css/styles.css"])
+	if !strings.Contains(css, ".form { margin: 0; }") || !strings.Contains(css, ".form { color: white; }") {
+		t.Errorf("nested stylesheets not merged:\n%s", css)
+	}
+	want := `<script src="js/ui-form-forms-validate.js"></script>` + "\n" +
+		`<script src="js/ui-form-widgets-date-validate.js"></script>` + "\n" +
+		`<script src="js/ui-form-widgets-validate.js"></script>`
+	if html := string(files["index.html"]); !strings.HasSuffix(html, want) {
+		t.Errorf("scripts not linked in file name order:\n%s", html)
+	}
+}
+
+func TestSCSSError(t *testing.T) {
+	errSyntax := errors.New("line 1: expected '}'")
+	_, _, err := buildSite(t, map[string]string{
+		"blueprints/index.blueprint":   "1 ui.card\n",
+		"components/ui/card/card.html": "<div></div>",
+		"components/ui/card/card.scss": ".card {",
+	}, Options{SCSS: func(path string, source []byte) ([]byte, error) {
+		return nil, errSyntax
+	}})
+
+	if !errors.Is(err, errSyntax) || !strings.Contains(err.Error(), "loading component ui.card: compiling SCSS card.scss: ") {
+		t.Errorf("got error %v, want the compiler's error for ui.card", err)
+	}
+}
+
+func TestCSSTransformError(t *testing.T) {
+	errParse := errors.New("unexpected token")
+	sink, _, err := buildSite(t, map[string]string{
+		"blueprints/index.blueprint": "1 page\n",
+		"components/page/page.html":  "{{styles}}",
+		"components/page/page.css":   "p { margin: 0; }",
+	}, Options{Assets: assets.Options{CSSTransforms: []assets.CSSTransform{
+		func(css []byte) ([]byte, error) { return nil, errParse },
+	}}})
+
+	if !errors.Is(err, errParse) || !strings.Contains(err.Error(), "processing blueprint index.blueprint: processing assets: CSS transform 1: unexpected token") {
+		t.Errorf("got error %v, want the transform's error with its page", err)
+	}
+	if len(sink.Paths()) != 0 {
+		t.Errorf("wrote %q for a failed page", sink.Paths())
+	}
+}
+
+func TestDataFile(t *testing.T) {
+	source := storage.NewMemory(map[string][]byte{
+		"data/product.json": []byte(`{"name": "Lamp", "price": 25, "features": ["dimmable", "USB"], "sku": "L-1"}`),
+	})
+	tree, err := blueprint.New("1 product\n  .data = data/product.json\n  .sku=OVERRIDE\n1.1 product\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := New(source, storage.NewMemorySink(), Options{}).loadData(tree); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{
+		"name":     {"Lamp"},
+		"price":    {"25"},
+		"features": {"dimmable", "USB"},
+		"sku":      {"OVERRIDE"},
+	}
+	block := tree.Children[0].Block
+	if !maps.EqualFunc(block.Vars, want, slices.Equal) {
+		t.Errorf("block vars %q, want %q", block.Vars, want)
+	}
+	if child := tree.Children[0].Children[0].Block; len(child.Vars) != 0 {
+		t.Errorf("child without .data got vars %q", child.Vars)
+	}
+}
+
+func TestDataFileInRange(t *testing.T) {
+	files := mustBuild(t, map[string]string{
+		"data/product.json":               `{"name": "Lamp", "features": ["dimmable", "USB"]}`,
+		"blueprints/index.blueprint":      "1 product\n  .data=data/product.json\n",
+		"components/product/product.html": "<h2>{{.name}}</h2><ul>{{range .features}}<li>{{.features}}</li>{{range end}}</ul>",
+	}, Options{})
+
+	if got, want := string(files["index.html"]), "<h2>Lamp</h2><ul><li>dimmable</li><li>USB</li></ul>"; got != want {
+		t.Errorf("index.html = %q, want %q", got, want)
+	}
+}
+
+func TestDataFileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{"missing", "data/nope.json", "block product: reading data: "},
+		{"outside the source", "../secret.json", "block product: data path ../secret.json must be relative to the source root"},
+		{"no parser", "data/product.toml", "block product: no parser for data file data/product.toml"},
+		{"malformed", "data/bad.json", "block product: data data/bad.json: parsing JSON: "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := buildSite(t, map[string]string{
+				"data/product.toml":               "name = 'Lamp'",
+				"data/bad.json":                   "{",
+				"blueprints/index.blueprint":      "1 product\n  .data=" + tt.data + "\n",
+				"components/product/product.html": "<h2>{{.name}}</h2>",
+			}, Options{})
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestCustomDataParser(t *testing.T) {
+	// A stand-in for a YAML parser, reading "name: value" lines with comma-separated sequences
+	parse := func(content []byte) (map[string][]string, error) {
+		vars := make(map[string][]string)
+		for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+			name, value, ok := strings.Cut(line, ": ")
+			if !ok {
+				return nil, fmt.Errorf("line %q is not a field", line)
+			}
+			vars[name] = strings.Split(value, ",")
+		}
+		return vars, nil
+	}
+	site := map[string]string{
+		"data/page.yaml":             "title: Shop\ntags: new,hot\n",
+		"data/bad.yaml":              "title Shop\n",
+		"blueprints/index.blueprint": "1 page\n  .data=data/page.yaml\n",
+		"components/page/page.html":  "<h1>{{.title}}</h1>{{range .tags}}<i>{{.tags}}</i>{{range end}}",
+	}
+	opts := Options{Data: map[string]blueprint.DataParser{".yaml": parse}}
+
+	if got, want := string(mustBuild(t, site, opts)["index.html"]), "<h1>Shop</h1><i>new</i><i>hot</i>"; got != want {
+		t.Errorf("index.html = %q, want %q", got, want)
+	}
+
+	site["blueprints/index.blueprint"] = "1 page\n  .data=data/bad.yaml\n"
+	if _, _, err := buildSite(t, site, opts); err == nil || !strings.Contains(err.Error(), `block page: data data/bad.yaml: line "title Shop" is not a field`) {
+		t.Errorf("got error %v, want the parser's error", err)
+	}
+}
+
+func TestComponentImages(t *testing.T) {
+	files := mustBuild(t, map[string]string{
+		"blueprints/blog/post.blueprint":   "1 ui.card\n",
+		"components/ui/card/card.html":     `<img src="logo.svg">`,
+		"components/ui/card/logo.svg":      "<svg/>",
+		"components/ui/unused/unused.html": "<p></p>",
+		"components/ui/unused/icon.png":    "png",
+	}, Options{})
+
+	if got := string(files["img/ui-card-logo.svg"]); got != "<svg/>" {
+		t.Errorf("img/ui-card-logo.svg = %q, want the component's image", got)
+	}
+	if got, want := string(files["blog/post.html"]), `<img src="../img/ui-card-logo.svg">`; got != want {
+		t.Errorf("blog/post.html = %q, want %q", got, want)
+	}
+	if _, ok := files["img/ui-unused-icon.png"]; ok {
+		t.Error("image of an unused component was written")
+	}
+}
+
+func TestLayoutFrontMatter(t *testing.T) {
+	files := mustBuild(t, map[string]string{
+		"blueprints/index.blueprint":     "---\nlayout = site.base\n---\n1 text\n  .body=Welcome\n",
+		"blueprints/about.blueprint":     "---\nlayout = site.base\n---\n1 text\n  .body=Who we are\n2 text\n  .body=Contact\n  .slot=aside\n",
+		"components/site/base/base.html": "<html><main>{{component}}</main><aside>{{slot aside}}</aside></html>",
+		"components/text/text.html":      "<p>{{.body}}</p>",
+		"blueprints/plain.blueprint":     "1 text\n  .body=No layout\n",
+	}, Options{})
+
+	tests := map[string]string{
+		"index.html": "<html><main><p>Welcome</p></main><aside></aside></html>",
+		"about.html": "<html><main><p>Who we are</p></main><aside><p>Contact</p></aside></html>",
+		"plain.html": "<p>No layout</p>",
+	}
+	for path, want := range tests {
+		if got := string(files[path]); got != want {
+			t.Errorf("%s = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestErrorPage(t *testing.T) {
+	site := map[string]string{
+		"blueprints/404.blueprint":            "1 page\n  .title=Not found\n",
+		"blueprints/errors/missing.blueprint": "1 page\n  .title=Gone missing\n",
+		"blueprints/_oops.blueprint":          "1 page\n  .title=Oops\n",
+		"components/page/page.html":           "{{styles}}<h1>{{.title | upper}}</h1>",
+		"components/page/page.css":            "h1 { margin: 0; }",
+	}
+	tests := []struct {
+		name      string
+		errorPage string
+		want      string
+		absent    string // output of the blueprint when it isn't the error page
+	}{
+		{"404.blueprint", "", "<h1>NOT FOUND</h1>", ""},
+		{"configured", "errors/missing.blueprint", "<h1>GONE MISSING</h1>", "errors/missing.html"},
+		{"configured partial", "_oops.blueprint", "<h1>OOPS</h1>", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := maps.Clone(site)
+			if tt.errorPage != "" {
+				delete(source, "blueprints/404.blueprint")
+			}
+			files := mustBuild(t, source, Options{ErrorPage: tt.errorPage})
+			page := string(files["404.html"])
+			// Served at any depth, the error page links its assets from the site root
+			if want := `<link rel="stylesheet" href="/css/styles.css">` + tt.want; page != want {
+				t.Errorf("404.html = %q, want %q", page, want)
+			}
+			if _, ok := files[tt.absent]; tt.absent != "" && ok {
+				t.Errorf("error page also written to %s", tt.absent)
+			}
+		})
+	}
+}
+
+func TestErrorPageCollision(t *testing.T) {
+	_, _, err := buildSite(t, map[string]string{
+		"blueprints/404.blueprint":     "1 page\n",
+		"blueprints/missing.blueprint": "1 page\n",
+		"components/page/page.html":    "<h1></h1>",
+	}, Options{ErrorPage: "missing.blueprint"})
+	if err == nil || !strings.Contains(err.Error(), "page 404.html is also built from another blueprint") {
+		t.Errorf("got error %v, want the two blueprints building 404.html reported", err)
+	}
+}
+
+func TestFailOnWarning(t *testing.T) {
+	site := map[string]string{
+		"blueprints/index.blueprint": "1 page\n",
+		"blueprints/about.blueprint": "1 page\n  .name=About\n",
+		"components/page/page.html":  "<h1>{{.name}}</h1>",
+	}
+
+	_, stats, err := buildSite(t, site, Options{})
+	if err != nil || len(stats.Warnings) != 1 {
+		t.Fatalf("got warnings %q and error %v, want one warning and no error", stats.Warnings, err)
+	}
+
+	sink, _, err := buildSite(t, site, Options{FailOnWarning: true})
+	if err == nil || err.Error() != "failing on 1 warnings: "+stats.Warnings[0] {
+		t.Errorf("got error %v, want the warning %q as the failure", err, stats.Warnings[0])
+	}
+	if _, ok := sink.Files()["about.html"]; !ok {
+		t.Error("pages not written before failing")
+	}
+
+	site["blueprints/contact.blueprint"] = "1 page\n"
+	if _, _, err = buildSite(t, site, Options{FailOnWarning: true}); err == nil ||
+		!strings.HasPrefix(err.Error(), "failing on 2 warnings: contact.blueprint: ") || !strings.Contains(err.Error(), "\nindex.blueprint: ") {
+		t.Errorf("got error %v, want every warning listed", err)
+	}
+}
+
+func TestUnknownAlias(t *testing.T) {
+	_, _, err := buildSite(t, map[string]string{
+		"blueprints/index.blueprint":   "@alias hero = ui.hero\n1 hero\n2 banner\n",
+		"components/ui/hero/hero.html": "<header></header>",
+	}, Options{})
+	if err == nil || !strings.Contains(err.Error(), "loading component banner, which is no alias its blueprint declares: component banner not found") {
+		t.Errorf("got error %v, want the undeclared banner reported as neither an alias nor a component", err)
+	}
+}
+
+func TestStrictVars(t *testing.T) {
+	site := map[string]string{
+		"blueprints/index.blueprint": "1 page\n",
+		"blueprints/about.blueprint": "1 page\n  .name=About\n",
+		"components/page/page.html":  "<h1>{{.name}}</h1>",
+	}
+	if _, _, err := buildSite(t, site, Options{StrictVars: true}); err == nil ||
+		!strings.Contains(err.Error(), "processing blueprint index.blueprint: ") || !strings.Contains(err.Error(), "page line 1:5 [.name]: undefined variable .name renders empty") {
+		t.Errorf("got error %v, want the undefined .name of index.blueprint", err)
+	}
+
+	site["components/page/page.html"] = `<h1>{{.name | default "Home"}}</h1>`
+	files := mustBuild(t, site, Options{StrictVars: true})
+	if got := string(files["index.html"]); got != "<h1>Home</h1>" {
+		t.Errorf("index.html = %q, want the default", got)
+	}
+}
+
+func TestSharedStylesheetOnce(t *testing.T) {
+	files := mustBuild(t, map[string]string{
+		"blueprints/index.blueprint": "1 a\n2 b\n",
+		"components/a/a.html":        "<a></a>",
+		"components/a/a.css":         "@import \"shared/vars.css\";\n.a { color: var(--c); }",
+		"components/b/b.html":        "<b></b>",
+		"components/b/b.css":         "@import \"shared/vars.css\";\n.b { color: var(--c); }",
+		"shared/vars.css":            ":root { --c: red; }",
+	}, Options{})
+
+	css := string(files["css/styles.css"])
+	if n := strings.Count(css, ":root { --c: red; }"); n != 1 {
+		t.Errorf("shared stylesheet included %d times, want once:\n%s", n, css)
+	}
+	if strings.Contains(css, "@import") {
+		t.Errorf("import left in the stylesheet:\n%s", css)
+	}
+	if vars, a := strings.Index(css, "--c: red"), strings.Index(css, ".a {"); vars > a || a > strings.Index(css, ".b {") {
+		t.Errorf("shared stylesheet not before the components importing it:\n%s", css)
+	}
+}
+
+func TestPageCSS(t *testing.T) {
+	site := map[string]string{
+		"blueprints/index.blueprint":     "1 layout\n1.1 hero\n",
+		"blueprints/blog/post.blueprint": "1 layout\n1.1 card\n",
+		"components/layout/layout.html":  "<head>{{styles}}</head>{{component}}",
+		"components/layout/layout.css":   "body { margin: 0; }",
+		"components/hero/hero.html":      "<header></header>",
+		"components/hero/hero.css":       ".hero { height: 50vh; }",
+		"components/card/card.html":      "<div></div>",
+		"components/card/card.css":       ".card { padding: 1rem; }",
+	}
+	files := mustBuild(t, site, Options{PageCSS: true})
+
+	tests := []struct {
+		page, sheet, link string
+		has, lacks        string
+	}{
+		{"index.html", "css/index.css", `href="css/index.css"`, ".hero", ".card"},
+		{"blog/post.html", "css/blog-post.css", `href="../css/blog-post.css"`, ".card", ".hero"},
+	}
+	for _, tt := range tests {
+		css := string(files[tt.sheet])
+		if !strings.Contains(css, "body { margin: 0; }") || !strings.Contains(css, tt.has+" {") || strings.Contains(css, tt.lacks+" {") {
+			t.Errorf("%s has not only the styles of %s:\n%s", tt.sheet, tt.page, css)
+		}
+		if html := string(files[tt.page]); !strings.Contains(html, tt.link) {
+			t.Errorf("%s does not link its own stylesheet: %q", tt.page, html)
+		}
+	}
+	if _, ok := files["css/styles.css"]; ok {
+		t.Error("shared stylesheet written with PageCSS")
+	}
+
+	site["blueprints/blog-post.blueprint"] = "1 layout\n"
+	if _, _, err := buildSite(t, site, Options{PageCSS: true}); err == nil || !strings.Contains(err.Error(), "would both link css/blog-post.css") {
+		t.Errorf("got error %v, want the colliding page stylesheets reported", err)
+	}
+}
+
+func TestPageCSSIntegrity(t *testing.T) {
+	site := map[string]string{
+		"blueprints/index.blueprint":    "1 layout\n1.1 hero\n",
+		"blueprints/about.blueprint":    "1 layout\n1.1 card\n",
+		"components/layout/layout.html": "<head>{{styles}}</head>{{component}}",
+		"components/layout/layout.css":  "body { margin: 0; }",
+		"components/hero/hero.html":     "<header></header>",
+		"components/hero/hero.css":      ".hero { height: 50vh; }",
+		"components/card/card.html":     "<div></div>",
+		"components/card/card.css":      ".card { padding: 1rem; }",
+	}
+
+	files := mustBuild(t, site, Options{PageCSS: true, Assets: assets.Options{Integrity: true}})
+	for page, sheet := range map[string]string{"index.html": "css/index.css", "about.html": "css/about.css"} {
+		html := string(files[page])
+		_, rest, _ := strings.Cut(html, `integrity="`)
+		got, _, _ := strings.Cut(rest, `"`)
+		sum := sha512.Sum384(files[sheet])
+		if want := "sha384-" + base64.StdEncoding.EncodeToString(sum[:]); got != want {
+			t.Errorf("%s links %s with integrity %q, the written file hashes to %s", page, sheet, got, want)
+		}
+	}
+
+	// Without PageCSS the pages share one stylesheet, which no single hash verifies
+	files = mustBuild(t, site, Options{Assets: assets.Options{Integrity: true}})
+	for _, page := range []string{"index.html", "about.html"} {
+		if html := string(files[page]); strings.Contains(html, "integrity") {
+			t.Errorf("%s links the shared stylesheet with integrity: %q", page, html)
+		}
+	}
+}
+
+func TestPageCSSSourceMap(t *testing.T) {
+	site := map[string]string{
+		"blueprints/index.blueprint":    "1 layout\n1.1 hero\n",
+		"blueprints/about.blueprint":    "1 layout\n1.1 card\n",
+		"components/layout/layout.html": "<head>{{styles}}</head>{{component}}",
+		"components/layout/layout.css":  "body { margin: 0; }",
+		"components/hero/hero.html":     "<header></header>",
+		"components/hero/hero.css":      ".hero { height: 50vh; }",
+		"components/card/card.html":     "<div></div>",
+		"components/card/card.css":      ".card { padding: 1rem; }",
+	}
+
+	files := mustBuild(t, site, Options{PageCSS: true, Assets: assets.Options{SourceMap: true}})
+	for sheet, want := range map[string][]string{
+		"index.css": {"layout/layout.css", "hero/hero.css"},
+		"about.css": {"layout/layout.css", "card/card.css"},
+	} {
+		if css := string(files["css/"+sheet]); !strings.HasSuffix(css, "/*# sourceMappingURL="+sheet+".map */") {
+			t.Errorf("%s does not point at its own map:\n%s", sheet, css)
+		}
+		var sm struct {
+			File    string   `json:"file"`
+			Sources []string `json:"sources"`
+		}
+		if err := json.Unmarshal(files["css/"+sheet+".map"], &sm); err != nil {
+			t.Fatalf("map of %s: %v", sheet, err)
+		}
+		if sm.File != sheet || !slices.Equal(sm.Sources, want) {
+			t.Errorf("map of %s is for %s from %q, want sources %q", sheet, sm.File, sm.Sources, want)
+		}
+	}
+	if _, ok := files["css/styles.css.map"]; ok {
+		t.Error("shared map written with PageCSS")
+	}
+
+	for _, opts := range []Options{
+		{Assets: assets.Options{SourceMap: true}},
+		{PageCSS: true, Assets: assets.Options{SourceMap: true, DedupeRules: true}},
+	} {
+		if _, _, err := buildSite(t, site, opts); err == nil || !strings.Contains(err.Error(), "CSS source map") {
+			t.Errorf("options %+v: got error %v, want the source map refused", opts, err)
+		}
+	}
+}
\ No newline at end of file
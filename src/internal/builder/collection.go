@@ -0,0 +1,258 @@
+package builder
+
+import (
+	"fmt"
+	"io/fs"
+	"maps"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"webfactory/src/internal/blueprint"
+)
+
+// Front matter keys of a blueprint expanded into one page per item of a data collection
+const (
+	collectionKey = "collection" // data file holding the items, relative to the source root
+	slugKey       = "slug"       // item field naming each page, "slug" when not set
+	paginateKey   = "paginate"   // data file whose items are listed across numbered pages
+	perPageKey    = "per-page"   // items on each numbered page, defaultPerPage when not set
+	taxonomyKey   = "taxonomy"   // data file whose items are grouped into a page per term
+	termsKey      = "terms"      // item field holding each item's terms, "tags" when not set
+)
+
+// defaultPerPage is the number of items on each page of a paginated blueprint without a per-page key
+const defaultPerPage = 10
+
+// page is one output page of a blueprint
+type page struct {
+	outputRel string              // output path without extension
+	globals   map[string][]string // lowest-precedence variables of the page
+	meta      map[string]string   // fields overriding the blueprint's front matter in the page's feed item
+	listing   bool                // a numbered or term page listing other pages, never a feed item
+}
+
+// collectionPages lists the pages of a blueprint whose front matter names a collection, nil for others
+// Each item becomes a page named by its slug field, in a directory named after the blueprint, with the
+// item's fields as variables overriding the globals.
+func (b *Builder) collectionPages(tree *blueprint.Node, outputRel string) ([]page, error) {
+	file := tree.Meta[collectionKey]
+	if file == "" {
+		return nil, nil
+	}
+	slugField := tree.Meta[slugKey]
+	if slugField == "" {
+		slugField = slugKey
+	}
+
+	items, err := b.readCollection(file)
+	if err != nil {
+		return nil, err
+	}
+	dir := collectionDir(outputRel)
+
+	pages := make([]page, 0, len(items))
+	seen := make(map[string]int)
+	for i, item := range items {
+		var slug string
+		if values := item[slugField]; len(values) > 0 {
+			slug = strings.TrimSpace(values[0])
+		}
+		if slug == "" || slug == "." || slug == ".." || strings.ContainsAny(slug, `/\`) {
+			return nil, fmt.Errorf("collection %s: item %d has no usable %s field for its page name", file, i+1, slugField)
+		}
+		if prev, exists := seen[slug]; exists {
+			return nil, fmt.Errorf("collection %s: items %d and %d have the same %s %q", file, prev, i+1, slugField, slug)
+		}
+		seen[slug] = i + 1
+
+		pages = append(pages, page{outputRel: filepath.Join(dir, slug), globals: b.pageGlobals(item), meta: itemMeta(item)})
+	}
+	return pages, nil
+}
+
+// paginatedPages lists the numbered pages of a blueprint whose front matter names a file to paginate,
+// nil for others
+// Page n is written to page/n.html in a directory named after the blueprint. Each field of the page's
+// items is a variable holding one value per item, with page, totalPages, hasPrev, hasNext, prevPage,
+// and nextPage describing the page's position.
+func (b *Builder) paginatedPages(tree *blueprint.Node, outputRel string) ([]page, error) {
+	file := tree.Meta[paginateKey]
+	if file == "" {
+		return nil, nil
+	}
+	perPage := defaultPerPage
+	if value := tree.Meta[perPageKey]; value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("%s value %q is not a positive number", perPageKey, value)
+		}
+		perPage = n
+	}
+
+	items, err := b.readCollection(file)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(collectionDir(outputRel), "page")
+
+	// An empty collection still gets a first page
+	total := max(1, (len(items)+perPage-1)/perPage)
+	pages := make([]page, 0, total)
+	for n := 1; n <= total; n++ {
+		slice := items[min((n-1)*perPage, len(items)):min(n*perPage, len(items))]
+		vars := pageItems(slice)
+		vars["page"] = []string{strconv.Itoa(n)}
+		vars["totalPages"] = []string{strconv.Itoa(total)}
+		vars["hasPrev"] = []string{strconv.FormatBool(n > 1)}
+		vars["hasNext"] = []string{strconv.FormatBool(n < total)}
+		vars["prevPage"] = []string{strconv.Itoa(max(n-1, 1))}
+		vars["nextPage"] = []string{strconv.Itoa(min(n+1, total))}
+		pages = append(pages, page{outputRel: filepath.Join(dir, strconv.Itoa(n)), globals: b.pageGlobals(vars), listing: true})
+	}
+	return pages, nil
+}
+
+// taxonomyPages lists a page per term of a blueprint whose front matter names a file to group by
+// terms, nil for others
+// Items are grouped by the values of their terms field, tags unless set with the terms key. The page of
+// a term is named after it, lowercased with other characters than letters and digits turned into dashes,
+// in a directory named after the blueprint. Its variables are those of a paginated page's items, with
+// term holding the term as written.
+func (b *Builder) taxonomyPages(tree *blueprint.Node, outputRel string) ([]page, error) {
+	file := tree.Meta[taxonomyKey]
+	if file == "" {
+		return nil, nil
+	}
+	field := tree.Meta[termsKey]
+	if field == "" {
+		field = "tags"
+	}
+
+	items, err := b.readCollection(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var terms []string
+	grouped := make(map[string][]map[string][]string)
+	for _, item := range items {
+		listed := make(map[string]bool) // an item giving a term twice is listed once
+		for _, term := range item[field] {
+			term = strings.TrimSpace(term)
+			if term == "" || listed[term] {
+				continue
+			}
+			listed[term] = true
+			if _, exists := grouped[term]; !exists {
+				terms = append(terms, term)
+			}
+			grouped[term] = append(grouped[term], item)
+		}
+	}
+
+	dir := collectionDir(outputRel)
+	pages := make([]page, 0, len(terms))
+	names := make(map[string]string)
+	for _, term := range terms {
+		name := termSlug(term)
+		if name == "" {
+			return nil, fmt.Errorf("taxonomy %s: term %q has no letters or digits to name its page", file, term)
+		}
+		if other, exists := names[name]; exists {
+			return nil, fmt.Errorf("taxonomy %s: terms %q and %q both name the page %s.html", file, other, term, name)
+		}
+		names[name] = term
+
+		vars := pageItems(grouped[term])
+		vars["term"] = []string{term}
+		pages = append(pages, page{outputRel: filepath.Join(dir, name), globals: b.pageGlobals(vars), listing: true})
+	}
+	return pages, nil
+}
+
+// termSlug turns a taxonomy term into a file name: lowercase letters and digits, other runs as a dash
+func termSlug(term string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(term) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// itemMeta returns the first value of each field of a collection item, as its page's feed metadata
+func itemMeta(item map[string][]string) map[string]string {
+	meta := make(map[string]string, len(item))
+	for name, values := range item {
+		if len(values) > 0 {
+			meta[name] = values[0]
+		}
+	}
+	return meta
+}
+
+// pageItems collects the fields of items into variables holding one value per item, in order
+// An item missing a field others have gets an empty value, keeping the values of every field aligned.
+func pageItems(items []map[string][]string) map[string][]string {
+	vars := make(map[string][]string)
+	for i, item := range items {
+		for name, values := range item {
+			if _, exists := vars[name]; !exists {
+				vars[name] = make([]string, len(items))
+			}
+			if len(values) > 0 {
+				vars[name][i] = values[0]
+			}
+		}
+	}
+	return vars
+}
+
+// readCollection reads and parses a collection data file
+func (b *Builder) readCollection(file string) ([]map[string][]string, error) {
+	if !fs.ValidPath(file) {
+		return nil, fmt.Errorf("collection path %s must be relative to the source root", file)
+	}
+	content, err := b.source.ReadData(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading collection: %w", err)
+	}
+	parse, err := blueprint.FindCollectionParser(file)
+	if err != nil {
+		return nil, err
+	}
+	items, err := parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("collection %s: %w", file, err)
+	}
+	return items, nil
+}
+
+// collectionDir returns the directory a blueprint's generated pages go in
+// An index blueprint names the directory it is in, any other blueprint becomes one.
+func collectionDir(outputRel string) string {
+	if filepath.Base(outputRel) == "index" {
+		return filepath.Dir(outputRel)
+	}
+	return outputRel
+}
+
+// pageGlobals returns the site globals overridden by a generated page's own variables
+func (b *Builder) pageGlobals(vars map[string][]string) map[string][]string {
+	globals := maps.Clone(b.globals)
+	if globals == nil {
+		globals = make(map[string][]string, len(vars))
+	}
+	maps.Copy(globals, vars)
+	return globals
+}
\ No newline at end of file
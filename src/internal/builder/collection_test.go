@@ -0,0 +1,73 @@
+package builder
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTaxonomyPages(t *testing.T) {
+	files := mustBuild(t, map[string]string{
+		"posts.json": `[
+			{"slug": "a", "title": "Alpha", "tags": ["Go", "Web Dev"]},
+			{"slug": "b", "title": "Beta", "tags": ["Go", "Go"]},
+			{"slug": "c", "title": "Gamma", "tags": ["Web Dev"]}
+		]`,
+		"blueprints/tags.blueprint": "---\ntaxonomy = posts.json\n---\n1 list\n",
+		"components/list/list.html": "<h1>{{.term}}</h1>{{range .title}}<li>{{.title}}</li>{{range end}}",
+	}, Options{})
+
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"tags/go.html", "<h1>Go</h1><li>Alpha</li><li>Beta</li>"},
+		{"tags/web-dev.html", "<h1>Web Dev</h1><li>Alpha</li><li>Gamma</li>"},
+	}
+	for _, tt := range tests {
+		if got := string(files[tt.file]); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.file, got, tt.want)
+		}
+	}
+	if len(files) != len(tests) {
+		t.Errorf("built %d files, want only the term pages", len(files))
+	}
+}
+
+func TestTaxonomyPagesErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		posts string
+		want  string
+	}{
+		{"unnamed term", `[{"tags": ["!!"]}]`, `taxonomy posts.json: term "!!" has no letters or digits to name its page`},
+		{"colliding terms", `[{"tags": ["Web Dev", "web-dev"]}]`, `taxonomy posts.json: terms "Web Dev" and "web-dev" both name the page web-dev.html`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := buildSite(t, map[string]string{
+				"posts.json":                tt.posts,
+				"blueprints/tags.blueprint": "---\ntaxonomy = posts.json\n---\n1 list\n",
+				"components/list/list.html": "{{.term}}",
+			}, Options{})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTermSlug(t *testing.T) {
+	tests := map[string]string{
+		"Go":            "go",
+		"Web Dev":       "web-dev",
+		"  C++ / Rust ": "c-rust",
+		"Ünïcode":       "ünïcode",
+		"!!":            "",
+	}
+	for term, want := range tests {
+		if got := termSlug(term); got != want {
+			t.Errorf("termSlug(%q) = %q, want %q", term, got, want)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package builder
+
+import (
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FeedOptions configures an RSS feed built from a collection of pages
+type FeedOptions struct {
+	Collection  string // Blueprint directory whose pages become feed items, empty disables the feed
+	Path        string // Output path of the feed, feed.xml when empty
+	Title       string
+	Description string
+	BaseURL     string // Absolute site URL that item links are resolved against
+}
+
+// feedDateLayouts are the accepted front matter date formats
+var feedDateLayouts = []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	PubDate     string    `xml:"pubDate,omitempty"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate"`
+	Description string `xml:"description,omitempty"`
+	date        time.Time
+}
+
+// writeFeed writes the RSS feed of the configured collection, newest items first
+// Items take title, date, and summary (or description) from the front matter of each page, or the
+// fields of a collection item; a page without a valid date is an error so it cannot silently drop out
+// of the feed. Numbered and term pages only list other pages and are left out.
+func (b *Builder) writeFeed() error {
+	opts := b.opts.Feed
+	if opts.Collection == "" {
+		return nil
+	}
+
+	base := strings.TrimSuffix(opts.BaseURL, "/")
+	prefix := strings.Trim(filepath.ToSlash(opts.Collection), "/") + "/"
+
+	var items []rssItem
+	for outputRel, built := range b.pages {
+		page := filepath.ToSlash(outputRel)
+		if !strings.HasPrefix(page, prefix) || page == prefix+"index" || built.listing {
+			continue
+		}
+		meta := built.meta
+
+		date, err := parseFeedDate(meta["date"])
+		if err != nil {
+			return fmt.Errorf("page %s: %w", page, err)
+		}
+
+		link := base + "/" + pageURL(page)
+		items = append(items, rssItem{
+			Title:       meta["title"],
+			Link:        link,
+			GUID:        link,
+			PubDate:     date.Format(time.RFC1123Z),
+			Description: firstNonEmpty(meta["summary"], meta["description"]),
+			date:        date,
+		})
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if !items[i].date.Equal(items[j].date) {
+			return items[i].date.After(items[j].date)
+		}
+		return items[i].Link < items[j].Link
+	})
+
+	feed := rss{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       opts.Title,
+			Link:        base + "/",
+			Description: opts.Description,
+			Items:       items,
+		},
+	}
+	if len(items) > 0 {
+		feed.Channel.PubDate = items[0].PubDate
+	}
+
+	content, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := opts.Path
+	if path == "" {
+		path = "feed.xml"
+	}
+	return b.write(map[string][]byte{path: append([]byte(xml.Header), content...)})
+}
+
+// parseFeedDate parses a front matter date in one of feedDateLayouts
+func parseFeedDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("missing date in front matter")
+	}
+	for _, layout := range feedDateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD or RFC 3339", value)
+}
+
+// pageURL returns the site-relative URL of a page output path, index pages mapping to their directory
+func pageURL(page string) string {
+	if page == "index" {
+		return ""
+	}
+	if dir, ok := strings.CutSuffix(page, "/index"); ok {
+		return dir + "/"
+	}
+	return page + ".html"
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
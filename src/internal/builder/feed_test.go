@@ -0,0 +1,125 @@
+package builder
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// feedItems returns the titles of the items of a built feed, in order
+func feedItems(t *testing.T, content []byte) []string {
+	t.Helper()
+	var feed rss
+	if err := xml.Unmarshal(content, &feed); err != nil {
+		t.Fatalf("feed is not valid XML: %v\n%s", err, content)
+	}
+	titles := make([]string, len(feed.Channel.Items))
+	for i, item := range feed.Channel.Items {
+		titles[i] = item.Title
+	}
+	return titles
+}
+
+func TestFeedNewestFirst(t *testing.T) {
+	files := mustBuild(t, map[string]string{
+		"blueprints/blog/b.blueprint": "---\ntitle = Middle\ndate = 2024-03-01\n---\n1 post\n",
+		"blueprints/blog/a.blueprint": "---\ntitle = Newest\ndate = 2024-05-01T10:00:00Z\nsummary = Fresh & new\n---\n1 post\n",
+		"blueprints/blog/c.blueprint": "---\ntitle = Oldest\ndate = 2023-12-31\n---\n1 post\n",
+		"blueprints/about.blueprint":  "1 post\n",
+		"components/post/post.html":   "<h1>{{.title}}</h1>",
+	}, Options{Feed: FeedOptions{Collection: "blog", Title: "News <daily>", BaseURL: "https://example.com/"}})
+
+	content := files["feed.xml"]
+	if !strings.HasPrefix(string(content), xml.Header) {
+		t.Errorf("feed does not start with the XML header:\n%s", content)
+	}
+	var feed rss
+	if err := xml.Unmarshal(content, &feed); err != nil {
+		t.Fatalf("feed is not valid XML: %v\n%s", err, content)
+	}
+	if feed.Channel.Title != "News <daily>" {
+		t.Errorf("channel title %q", feed.Channel.Title)
+	}
+	got := strings.Join(feedItems(t, content), ",")
+	if want := "Newest,Middle,Oldest"; got != want {
+		t.Errorf("feed items %q, want %q", got, want)
+	}
+	newest := feed.Channel.Items[0]
+	if newest.Link != "https://example.com/blog/a.html" || newest.Description != "Fresh & new" {
+		t.Errorf("newest item %+v", newest)
+	}
+	if feed.Channel.PubDate != newest.PubDate || newest.PubDate != "Wed, 01 May 2024 10:00:00 +0000" {
+		t.Errorf("channel pubDate %q, newest item %q", feed.Channel.PubDate, newest.PubDate)
+	}
+}
+
+func TestFeedMissingDate(t *testing.T) {
+	_, _, err := buildSite(t, map[string]string{
+		"blueprints/blog/a.blueprint": "---\ntitle = Undated\n---\n1 post\n",
+		"components/post/post.html":   "<h1>{{.title}}</h1>",
+	}, Options{Feed: FeedOptions{Collection: "blog"}})
+	if err == nil || !strings.Contains(err.Error(), "page blog/a: missing date in front matter") {
+		t.Errorf("got error %v, want the missing date of blog/a", err)
+	}
+}
+
+// postsJSON returns a collection of n posts, post i dated on day i of January 2024
+func postsJSON(n int) string {
+	items := make([]string, n)
+	for i := range items {
+		items[i] = fmt.Sprintf(`{"slug": "p%d", "title": "Post %d", "date": "2024-01-%02d"}`, i+1, i+1, i+1)
+	}
+	return "[" + strings.Join(items, ",") + "]"
+}
+
+func TestFeedOverCollection(t *testing.T) {
+	files := mustBuild(t, map[string]string{
+		"posts.json":                      postsJSON(3),
+		"blueprints/blog/post.blueprint":  "---\ncollection = posts.json\n---\n1 post\n",
+		"components/post/post.html":       "<h1>{{.title}}</h1>",
+		"blueprints/blog/index.blueprint": "1 post\n  .title=Blog\n",
+	}, Options{Feed: FeedOptions{Collection: "blog", BaseURL: "https://example.com"}})
+
+	for i := 1; i <= 3; i++ {
+		if _, ok := files[fmt.Sprintf("blog/post/p%d.html", i)]; !ok {
+			t.Errorf("no page for record %d", i)
+		}
+	}
+	got := strings.Join(feedItems(t, files["feed.xml"]), ",")
+	if want := "Post 3,Post 2,Post 1"; got != want {
+		t.Errorf("feed items %q, want %q", got, want)
+	}
+}
+
+func TestFeedSkipsNumberedPages(t *testing.T) {
+	tests := []struct {
+		name  string
+		posts int
+		pages int
+	}{
+		{"exact multiple", 4, 2},
+		{"remainder", 5, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := mustBuild(t, map[string]string{
+				"posts.json":                      postsJSON(tt.posts),
+				"blueprints/blog/post.blueprint":  "---\ncollection = posts.json\n---\n1 post\n",
+				"blueprints/blog/index.blueprint": "---\npaginate = posts.json\nper-page = 2\n---\n1 post\n  .title=Page\n",
+				"components/post/post.html":       "<h1>{{.title}}</h1>",
+			}, Options{Feed: FeedOptions{Collection: "blog", BaseURL: "https://example.com"}})
+
+			for n := 1; n <= tt.pages+1; n++ {
+				_, ok := files[fmt.Sprintf("blog/page/%d.html", n)]
+				if want := n <= tt.pages; ok != want {
+					t.Errorf("page %d written: %v, want %v", n, ok, want)
+				}
+			}
+			if items := feedItems(t, files["feed.xml"]); len(items) != tt.posts {
+				t.Errorf("feed has %d items %q, want the %d posts", len(items), items, tt.posts)
+			}
+		})
+	}
+}
\ No newline at end of file
@@ -0,0 +1,143 @@
+package builder
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"webfactory/src/internal/blueprint"
+	"webfactory/src/internal/component"
+)
+
+// Graph is the component structure of a site: which components pages use and how they nest
+type Graph struct {
+	Pages    map[string][]string // page output path -> components placed at the top level of its blueprint
+	Children map[string][]string // component -> components nested in its blocks by any blueprint
+	Includes map[string][]string // component -> components its template includes
+}
+
+// Graph reads every blueprint and the components it uses, without rendering pages
+// Cancelling ctx stops it between blueprints with the context's error.
+func (b *Builder) Graph(ctx context.Context) (*Graph, error) {
+	blueprints, err := b.listBlueprints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	b.registry = b.newRegistry()
+
+	paths := make([]string, 0, len(blueprints))
+	for path := range blueprints {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	g := &Graph{
+		Pages:    make(map[string][]string),
+		Children: make(map[string][]string),
+		Includes: make(map[string][]string),
+	}
+	var loadErrs []error
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		tree, err := blueprint.Load(path, b.source.ReadBlueprint)
+		if err != nil {
+			return nil, fmt.Errorf("parsing blueprint %s: %w", path, err)
+		}
+		page := filepath.ToSlash(blueprints[path]) + ".html"
+		g.Pages[page] = []string{}
+
+		var walk func(node *blueprint.Node, parent string)
+		walk = func(node *blueprint.Node, parent string) {
+			comp := node.Block.Path
+			if parent == "" {
+				g.Pages[page] = appendNew(g.Pages[page], comp)
+			} else {
+				g.Children[parent] = appendNew(g.Children[parent], comp)
+			}
+			if err := g.addIncludes(b.registry, comp); err != nil {
+				loadErrs = append(loadErrs, loadError(comp, err))
+			}
+			for _, child := range node.Children {
+				walk(child, comp)
+			}
+		}
+		for _, child := range tree.Children {
+			walk(child, "")
+		}
+	}
+	if len(loadErrs) > 0 {
+		return nil, fmt.Errorf("loading components: %w", errors.Join(loadErrs...))
+	}
+
+	return g, nil
+}
+
+// Dump parses one blueprint, by its path relative to blueprints/, and renders its block tree as text
+// Includes are spliced in and aliases expanded, as for a build; data files are not read.
+func (b *Builder) Dump(path string) (string, error) {
+	tree, err := blueprint.Load(filepath.FromSlash(path), b.source.ReadBlueprint)
+	if err != nil {
+		return "", fmt.Errorf("parsing blueprint %s: %w", path, err)
+	}
+	return blueprint.Dump(tree), nil
+}
+
+// addIncludes loads a component and records the includes of it and of the components it includes
+func (g *Graph) addIncludes(registry *component.Registry, path string) error {
+	if _, seen := g.Includes[path]; seen {
+		return nil
+	}
+	comp, err := registry.Load(path)
+	if err != nil {
+		return err
+	}
+	g.Includes[path] = []string{}
+	for _, include := range comp.Includes {
+		g.Includes[path] = appendNew(g.Includes[path], include)
+		if err := g.addIncludes(registry, include); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DOT renders the graph in the Graphviz DOT language
+// Pages are boxes, nesting edges are solid, and include edges are dashed.
+func (g *Graph) DOT() []byte {
+	var b bytes.Buffer
+	b.WriteString("digraph webfactory {\n")
+	for _, page := range slices.Sorted(maps.Keys(g.Pages)) {
+		fmt.Fprintf(&b, "\t%q [shape=box];\n", page)
+		for _, comp := range g.Pages[page] {
+			fmt.Fprintf(&b, "\t%q -> %q;\n", page, comp)
+		}
+	}
+	for _, comp := range slices.Sorted(maps.Keys(g.Children)) {
+		for _, child := range g.Children[comp] {
+			fmt.Fprintf(&b, "\t%q -> %q;\n", comp, child)
+		}
+	}
+	for _, comp := range slices.Sorted(maps.Keys(g.Includes)) {
+		for _, include := range g.Includes[comp] {
+			fmt.Fprintf(&b, "\t%q -> %q [style=dashed];\n", comp, include)
+		}
+	}
+	b.WriteString("}\n")
+	return b.Bytes()
+}
+
+// appendNew appends s to list unless it is already listed
+func appendNew(list []string, s string) []string {
+	if slices.Contains(list, s) {
+		return list
+	}
+	return append(list, s)
+}
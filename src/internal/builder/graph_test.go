@@ -0,0 +1,84 @@
+package builder
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"testing"
+
+	"webfactory/src/internal/storage"
+)
+
+// graphSite is a two-page site: both pages share a layout, the docs page nesting a card that includes a button
+var graphSite = map[string][]byte{
+	"blueprints/index.blueprint":    []byte("1 layout\n1.1 hero\n1.2 hero\n2 footer\n"),
+	"blueprints/docs/api.blueprint": []byte("1 layout\n1.1 card\n1.1.1 hero\n"),
+	"components/layout/layout.html": []byte("<main>{{component}}</main>"),
+	"components/hero/hero.html":     []byte("<h1></h1>"),
+	"components/footer/footer.html": []byte("<footer></footer>"),
+	"components/card/card.html":     []byte(`<div>{{component}}{{include "ui.btn"}}</div>`),
+	"components/ui/btn/btn.html":    []byte("<button></button>"),
+	"components/unused/unused.html": []byte("<p></p>"),
+}
+
+func TestGraph(t *testing.T) {
+	g, err := New(storage.NewMemory(graphSite), storage.NewMemorySink(), Options{}).Graph(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checks := []struct {
+		name      string
+		got, want map[string][]string
+	}{
+		{"pages", g.Pages, map[string][]string{
+			"index.html":    {"layout", "footer"},
+			"docs/api.html": {"layout"},
+		}},
+		{"children", g.Children, map[string][]string{
+			// In first use, docs/api.blueprint sorting first
+			"layout": {"card", "hero"},
+			"card":   {"hero"},
+		}},
+		{"includes", g.Includes, map[string][]string{
+			"layout": {}, "hero": {}, "footer": {}, "card": {"ui.btn"}, "ui.btn": {},
+		}},
+	}
+	for _, c := range checks {
+		if !maps.EqualFunc(c.got, c.want, slices.Equal) {
+			t.Errorf("%s = %q, want %q", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestGraphDOT(t *testing.T) {
+	g, err := New(storage.NewMemory(graphSite), storage.NewMemorySink(), Options{}).Graph(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `digraph webfactory {
+	"docs/api.html" [shape=box];
+	"docs/api.html" -> "layout";
+	"index.html" [shape=box];
+	"index.html" -> "layout";
+	"index.html" -> "footer";
+	"card" -> "hero";
+	"layout" -> "card";
+	"layout" -> "hero";
+	"card" -> "ui.btn" [style=dashed];
+}
+`
+	if got := string(g.DOT()); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestGraphMissingComponent(t *testing.T) {
+	_, err := New(storage.NewMemory(map[string][]byte{
+		"blueprints/index.blueprint": []byte("1 nope\n"),
+	}), storage.NewMemorySink(), Options{}).Graph(context.Background())
+	if err == nil {
+		t.Error("graph of a page using a missing component succeeded")
+	}
+}
@@ -0,0 +1,156 @@
+package builder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+
+	"webfactory/src/internal/assets"
+	"webfactory/src/internal/blueprint"
+	"webfactory/src/internal/template"
+)
+
+// LintReport lists the problems found by Lint
+type LintReport struct {
+	Errors   []string
+	Warnings []string
+}
+
+// OK reports whether lint found no errors
+func (r *LintReport) OK() bool {
+	return len(r.Errors) == 0
+}
+
+// addError records every problem in err, attributed to source, skipping repeats
+func (r *LintReport) addError(source string, err error) {
+	for _, e := range splitErrors(err) {
+		msg := fmt.Sprintf("%s: %v", source, e)
+		if !slices.Contains(r.Errors, msg) {
+			r.Errors = append(r.Errors, msg)
+		}
+	}
+}
+
+// Lint checks every blueprint and component the way Build does, without writing output
+// Variables a template uses but its page doesn't define are reported as warnings. Components that
+// no blueprint uses are reported as warnings too and checked on their own, rendered as the only
+// block of a page without variables.
+// Cancelling ctx stops lint between blueprints and components with the context's error.
+func (b *Builder) Lint(ctx context.Context) (*LintReport, error) {
+	if err := b.loadGlobals(); err != nil {
+		return nil, err
+	}
+
+	blueprints, err := b.listBlueprints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	b.registry = b.newRegistry()
+	b.names = assets.NewNames()
+	b.sheets = make(map[string]string)
+	b.tokens = template.NewTokens()
+	b.proc = nil
+
+	paths := make([]string, 0, len(blueprints))
+	for path := range blueprints {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	report := &LintReport{}
+	used := make(map[string]bool)
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		warnings, err := b.lintBlueprint(path, blueprints[path], used)
+		if err != nil {
+			report.addError(path, err)
+			continue
+		}
+		for _, w := range warnings {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("%s: %v", path, w))
+		}
+	}
+
+	components, err := b.source.ListComponents()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(components)
+
+	for _, comp := range components {
+		if used[comp] {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		report.Warnings = append(report.Warnings, fmt.Sprintf("component %s is not used by any blueprint", comp))
+
+		if err := b.lintComponent(comp); err != nil {
+			report.addError("component "+comp, err)
+		}
+	}
+
+	return report, nil
+}
+
+// lintBlueprint renders every page of a blueprint, recovering a panic as its error
+// Warnings repeated by several pages of a collection are listed once.
+func (b *Builder) lintBlueprint(path, outputRel string, used map[string]bool) (warnings []template.ProcessError, err error) {
+	defer recoverPanic(path, &err)
+
+	tree, pages, err := b.loadBlueprint(path, outputRel)
+	if err != nil {
+		return nil, err
+	}
+	for _, page := range pages {
+		result, err := b.render(tree, page.outputRel, page.globals, used)
+		if err != nil {
+			return nil, err
+		}
+		for _, w := range result.Warnings {
+			if !slices.Contains(warnings, w) {
+				warnings = append(warnings, w)
+			}
+		}
+	}
+	return warnings, nil
+}
+
+// lintComponent renders a component as the only block of a page, recovering a panic as its error
+func (b *Builder) lintComponent(comp string) (err error) {
+	defer recoverPanic(comp, &err)
+
+	tree := &blueprint.Node{
+		Block: blueprint.Block{ID: -1},
+		Children: []*blueprint.Node{{
+			Block: blueprint.Block{Path: comp, Index: []int{1}, Vars: make(map[string][]string)},
+		}},
+	}
+	_, err = b.render(tree, "", b.globals, nil)
+	return err
+}
+
+// splitErrors breaks a build error into its individual problems
+// Template errors are reported one per directive and component loading failures one per component.
+func splitErrors(err error) []error {
+	var procErrs template.ProcessErrors
+	if errors.As(err, &procErrs) {
+		errs := make([]error, len(procErrs))
+		for i, e := range procErrs {
+			errs[i] = e
+		}
+		return errs
+	}
+
+	var joined interface{ Unwrap() []error }
+	if errors.As(err, &joined) {
+		return joined.Unwrap()
+	}
+
+	return []error{err}
+}
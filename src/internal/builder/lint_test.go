@@ -0,0 +1,96 @@
+package builder
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"webfactory/src/internal/storage"
+)
+
+// lintSite lints a site from files given by their path below the source root, checking it writes nothing
+func lintSite(t *testing.T, files map[string]string) *LintReport {
+	t.Helper()
+	sink := storage.NewMemorySink()
+	report, err := New(memorySource(files), sink, Options{}).Lint(context.Background())
+	if err != nil {
+		t.Fatalf("linting: %v", err)
+	}
+	if paths := sink.Paths(); len(paths) > 0 {
+		t.Errorf("lint wrote %v", paths)
+	}
+	return report
+}
+
+func TestLintErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "missing component",
+			files: map[string]string{
+				"blueprints/index.blueprint":       "1 page\n1.1 ui.buton\n",
+				"components/page/page.html":        "{{component}}",
+				"components/ui/button/button.html": "<button></button>",
+			},
+			want: "index.blueprint: loading component ui.buton: component ui.buton not found (searched components/ui/buton), did you mean ui.button?",
+		},
+		{
+			name: "bad range",
+			files: map[string]string{
+				"blueprints/index.blueprint": "1 page\n  .items=a\n",
+				"components/page/page.html":  "<ul>\n{{range .items}}<li>{{.items}}</li>\n</ul>",
+			},
+			want: "index.blueprint: page line 2:1 [range .items]: unclosed range started at line 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := lintSite(t, tt.files)
+			if report.OK() || len(report.Errors) != 1 || report.Errors[0] != tt.want {
+				t.Errorf("got errors %q, want only %q", report.Errors, tt.want)
+			}
+		})
+	}
+}
+
+func TestLintClean(t *testing.T) {
+	report := lintSite(t, map[string]string{
+		"blueprints/index.blueprint": "1 page\n  .title=Hi\n",
+		"components/page/page.html":  "<h1>{{.title}}</h1>",
+	})
+	if !report.OK() || len(report.Warnings) > 0 {
+		t.Errorf("clean site reported errors %q and warnings %q", report.Errors, report.Warnings)
+	}
+}
+
+func TestLintBlueprintErrorsDoNotStopOthers(t *testing.T) {
+	report := lintSite(t, map[string]string{
+		"blueprints/a.blueprint":    "1 page\n  .items=a\n",
+		"blueprints/b.blueprint":    "1 nope\n",
+		"components/page/page.html": "{{range end}}",
+	})
+	if len(report.Errors) != 2 || !strings.HasPrefix(report.Errors[0], "a.blueprint: ") || !strings.HasPrefix(report.Errors[1], "b.blueprint: ") {
+		t.Errorf("got errors %q, want one for each blueprint", report.Errors)
+	}
+}
+
+func TestLintUnusedComponents(t *testing.T) {
+	report := lintSite(t, map[string]string{
+		"blueprints/index.blueprint":    "1 page\n",
+		"components/page/page.html":     `<main>{{include "ui.logo"}}</main>`,
+		"components/ui/logo/logo.html":  "<svg></svg>",
+		"components/old/card/card.html": "<div>{{unknown}}</div>",
+	})
+
+	if want := "component old.card is not used by any blueprint"; len(report.Warnings) != 1 || report.Warnings[0] != want {
+		t.Errorf("got warnings %q, want %q", report.Warnings, want)
+	}
+	// Unused components are still checked on their own
+	if want := "component old.card: old.card line 1:6 [unknown]: unknown directive {{unknown}}"; len(report.Errors) != 1 || report.Errors[0] != want {
+		t.Errorf("got errors %q, want %q", report.Errors, want)
+	}
+}
@@ -0,0 +1,52 @@
+package builder
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"html"
+	"path/filepath"
+	"sort"
+)
+
+// writePages writes the HTML pages held back to find duplicates
+// Of pages with identical HTML, the one first in path order is written and the others become redirects
+// to it, so the choice doesn't depend on the order blueprints are built in.
+func (b *Builder) writePages() error {
+	paths := make([]string, 0, len(b.held))
+	for path := range b.held {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	files := make(map[string][]byte, len(paths))
+	canonical := make(map[[sha256.Size]byte]string)
+	for _, path := range paths {
+		content := b.held[path]
+		sum := sha256.Sum256(content)
+		if first, seen := canonical[sum]; seen {
+			b.progress("  %s duplicates %s, redirecting", path, first)
+			files[path] = b.redirectPage(path, first)
+			continue
+		}
+		canonical[sum] = path
+		files[path] = content
+	}
+	return b.write(files)
+}
+
+// redirectPage returns a page sending visitors and search engines from one page to another
+func (b *Builder) redirectPage(from, to string) []byte {
+	outputRel := from[:len(from)-len(filepath.Ext(from))]
+	url := html.EscapeString(b.assetPrefix(outputRel) + filepath.ToSlash(to))
+	return []byte(fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Redirecting</title>
+<link rel="canonical" href="%[1]s">
+<meta http-equiv="refresh" content="0; url=%[1]s">
+</head>
+<body><a href="%[1]s">%[1]s</a></body>
+</html>
+`, url))
+}
@@ -0,0 +1,50 @@
+package builder
+
+import (
+	"strings"
+	"testing"
+)
+
+// duplicateSite has two blueprints building the same page and one building a different page
+var duplicateSite = map[string]string{
+	"blueprints/about.blueprint":      "1 page\n  .title=About\n",
+	"blueprints/docs/about.blueprint": "1 page\n  .title=About\n",
+	"blueprints/index.blueprint":      "1 page\n  .title=Home\n",
+	"components/page/page.html":       "<h1>{{.title}}</h1>",
+}
+
+func TestRedirects(t *testing.T) {
+	files := mustBuild(t, duplicateSite, Options{Redirects: true})
+
+	if got := string(files["about.html"]); got != "<h1>About</h1>" {
+		t.Errorf("canonical about.html = %q, want the page", got)
+	}
+	if got := string(files["index.html"]); got != "<h1>Home</h1>" {
+		t.Errorf("index.html = %q, want the page", got)
+	}
+	redirect := string(files["docs/about.html"])
+	for _, want := range []string{
+		`<link rel="canonical" href="../about.html">`,
+		`<meta http-equiv="refresh" content="0; url=../about.html">`,
+	} {
+		if !strings.Contains(redirect, want) {
+			t.Errorf("docs/about.html does not contain %q:\n%s", want, redirect)
+		}
+	}
+}
+
+func TestRedirectsBasePath(t *testing.T) {
+	files := mustBuild(t, duplicateSite, Options{Redirects: true, BasePath: "/site/"})
+	if redirect := string(files["docs/about.html"]); !strings.Contains(redirect, `url=/site/about.html"`) {
+		t.Errorf("redirect does not use the base path:\n%s", redirect)
+	}
+}
+
+func TestNoRedirects(t *testing.T) {
+	files := mustBuild(t, duplicateSite, Options{})
+	for _, path := range []string{"about.html", "docs/about.html"} {
+		if got := string(files[path]); got != "<h1>About</h1>" {
+			t.Errorf("%s = %q, want the page written in full", path, got)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"webfactory/src/internal/storage"
+)
+
+// countingSource is a Source counting the component files read through it
+type countingSource struct {
+	storage.Source
+	reads int
+}
+
+func (s *countingSource) ReadComponent(componentPath, filename string) ([]byte, error) {
+	s.reads++
+	return s.Source.ReadComponent(componentPath, filename)
+}
+
+// sharedSite returns a site of n pages all using the same styled card component
+func sharedSite(n int) map[string][]byte {
+	files := map[string][]byte{
+		"components/card/card.html": []byte("<div class=\"card\">{{.title}}</div>"),
+		"components/card/card.css":  []byte(".card { padding: 1rem; }"),
+	}
+	for i := range n {
+		files[fmt.Sprintf("blueprints/p%d.blueprint", i)] = []byte("1 card\n  .title=Same\n")
+	}
+	return files
+}
+
+// componentReads builds a site and returns how many component files it read
+func componentReads(t testing.TB, files map[string][]byte) int {
+	t.Helper()
+	source := &countingSource{Source: storage.NewMemory(files)}
+	if _, err := New(source, storage.NewMemorySink(), Options{}).Build(context.Background()); err != nil {
+		t.Fatalf("building: %v", err)
+	}
+	return source.reads
+}
+
+func TestSharedRegistryReadsOnce(t *testing.T) {
+	one, many := componentReads(t, sharedSite(1)), componentReads(t, sharedSite(20))
+	if one == 0 || many != one {
+		t.Errorf("20 pages read %d component files, one page read %d", many, one)
+	}
+}
+
+func TestSharedRegistryOutput(t *testing.T) {
+	files := mustBuild(t, map[string]string{
+		"blueprints/a.blueprint":     "1 card\n  .title=Same\n",
+		"blueprints/b.blueprint":     "1 card\n  .title=Same\n",
+		"blueprints/plain.blueprint": "1 text\n",
+		"components/card/card.html":  "<div class=\"card\">{{.title}}</div>",
+		"components/card/card.css":   ".card { padding: 1rem; }",
+		"components/text/text.html":  "<p>Text</p>",
+	}, Options{})
+
+	a, b := string(files["a.html"]), string(files["b.html"])
+	if a != b {
+		t.Errorf("pages sharing a component differ:\n%s\n%s", a, b)
+	}
+	if !strings.Contains(a, "<div class=\"card\">Same</div>") || !strings.Contains(a, "styles.css") {
+		t.Errorf("page not rendered with its stylesheet:\n%s", a)
+	}
+	// The stylesheet link belongs to pages whose components have styles, it must not carry over
+	if plain := string(files["plain.html"]); strings.Contains(plain, "styles.css") {
+		t.Errorf("page without styles links a stylesheet:\n%s", plain)
+	}
+}
+
+func BenchmarkBuildSharedComponent(b *testing.B) {
+	files := sharedSite(100)
+	var reads int
+	for range b.N {
+		reads = componentReads(b, files)
+	}
+	b.ReportMetric(float64(reads), "reads/build")
+}
\ No newline at end of file
@@ -2,32 +2,82 @@ package component
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io/fs"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
 	"strings"
+	"webfactory/src/internal/blueprint"
 	"webfactory/src/internal/storage"
 )
 
+// MetaFile is the optional per-component options file, in "key = value" lines
+const MetaFile = "component.meta"
+
+// SharedDir is the directory of the source root holding stylesheets that components share through @import
+const SharedDir = "shared"
+
+// sharedImport matches an @import of a shared stylesheet on a line of its own, such as @import "shared/vars.css";
+var sharedImport = regexp.MustCompile(`(?m)^[ \t]*@import[ \t]+(?:url\([ \t]*)?["'](` + SharedDir + `/[^"']+\.css)["'][ \t]*\)?[ \t]*;[ \t\r]*$`)
+
+// ImageExts are the extensions of the image files a component carries with it
+var ImageExts = []string{".svg", ".png", ".jpg", ".jpeg", ".webp", ".gif"}
+
 // Component represents a parsed and loaded component
 type Component struct {
-	Path     string            // Dot-separated path (e.g., "simple" or "composite.layout")
-	Template []byte            // Raw template content
-	Styles   []byte            // Combined CSS content
-	Scripts  map[string][]byte // JS content for each file
-	Children map[string]*Component
+	Path       string            // Dot-separated path (e.g., "simple" or "composite.layout")
+	Template   []byte            // Raw template content
+	Markdown   bool              // Template is Markdown and is rendered to HTML after substitution
+	Styles     []byte            // Combined CSS content
+	StyleFiles []StyleFile       // The stylesheets Styles combines, each followed by a newline
+	Shared     []StyleFile       // Shared stylesheets imported by the styles, each after those it imports
+	Scripts    map[string][]byte // JS content for each file
+	Images     map[string][]byte // Image content for each file, by slash path relative to the component
+	Includes   []string          // Paths of components included by the template
+	Meta       map[string]string // Options from the optional component.meta file
+	Children   map[string]*Component
+}
+
+// StyleFile is one stylesheet of a component, as CSS
+type StyleFile struct {
+	Name string // Slash-separated path relative to the components directory, such as ui/card/card.css, or for
+	// a shared stylesheet relative to the source root, such as shared/vars.css
+	CSS []byte
+}
+
+// SCSSCompiler compiles a component's SCSS file to CSS
+// The path is the file's location in the source, for resolving imports and for messages.
+type SCSSCompiler func(path string, source []byte) ([]byte, error)
+
+// IncludeFinder returns the paths of the components a template includes, in order of first use
+type IncludeFinder func(template []byte) []string
+
+// Options configures how components are loaded
+type Options struct {
+	SCSS     SCSSCompiler  // Compiles .scss files; without one a component with SCSS fails to load
+	Includes IncludeFinder // Finds a template's includes, which are loaded with it; none are found without one
 }
 
 // Registry manages all loaded components
 type Registry struct {
-	store  *storage.Storage
-	loaded map[string]*Component // key is "path.name"
+	store   storage.Source
+	opts    Options
+	loaded  map[string]*Component // key is "path.name"
+	loading map[string]bool       // components currently being loaded, for include cycle detection
+	shared  map[string][]byte     // shared stylesheets read, by slash path relative to the source root
 }
 
 // New creates a new component registry
-func New(store *storage.Storage) *Registry {
+func New(store storage.Source, opts Options) *Registry {
 	return &Registry{
-		store:  store,
-		loaded: make(map[string]*Component),
+		store:   store,
+		opts:    opts,
+		loaded:  make(map[string]*Component),
+		loading: make(map[string]bool),
+		shared:  make(map[string][]byte),
 	}
 }
 
@@ -36,16 +86,38 @@ func (r *Registry) Load(path string) (*Component, error) {
 	if comp, exists := r.loaded[path]; exists {
 		return comp, nil
 	}
+	if r.loading[path] {
+		return nil, fmt.Errorf("include cycle detected at component %s", path)
+	}
+	r.loading[path] = true
+	defer delete(r.loading, path)
 
 	comp := &Component{
 		Path:     path,
 		Children: make(map[string]*Component),
 		Scripts:  make(map[string][]byte),
+		Images:   make(map[string][]byte),
 	}
 
 	parts := strings.Split(path, ".")
 	fsPath := filepath.Join(parts...)
 
+	if !r.store.HasComponent(fsPath) {
+		return nil, r.notFound(path, fsPath)
+	}
+
+	// Load optional component metadata
+	comp.Meta = make(map[string]string)
+	metaContent, err := r.store.ReadComponent(fsPath, MetaFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("reading %s: %w", MetaFile, err)
+	}
+	if err == nil {
+		if comp.Meta, err = blueprint.ParseMeta(string(metaContent)); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", MetaFile, err)
+		}
+	}
+
 	// Find and load HTML template
 	templateFile, err := r.store.FindTemplateFile(fsPath)
 	if err != nil {
@@ -56,20 +128,51 @@ func (r *Registry) Load(path string) (*Component, error) {
 		return nil, err
 	}
 	comp.Template = template
+	comp.Markdown = filepath.Ext(templateFile) == ".md"
+
+	// Load components included by the template
+	if r.opts.Includes != nil {
+		comp.Includes = r.opts.Includes(template)
+	}
+	for _, inc := range comp.Includes {
+		if _, err := r.Load(inc); err != nil {
+			return nil, fmt.Errorf("loading include %s: %w", inc, err)
+		}
+	}
 
-	// Load all CSS files and combine
+	// Load all CSS and SCSS files and combine them in file name order
 	cssFiles, err := r.store.ListComponentFiles(fsPath, ".css")
 	if err != nil {
 		return nil, fmt.Errorf("listing CSS files: %w", err)
 	}
+	scssFiles, err := r.store.ListComponentFiles(fsPath, ".scss")
+	if err != nil {
+		return nil, fmt.Errorf("listing SCSS files: %w", err)
+	}
+	styleFiles := append(cssFiles, scssFiles...)
+	sort.Strings(styleFiles)
+
 	var cssContent bytes.Buffer
-	for _, file := range cssFiles {
+	for _, file := range styleFiles {
+		// SCSS partials are only compiled through the files importing them
+		if filepath.Ext(file) == ".scss" && strings.HasPrefix(filepath.Base(file), "_") {
+			continue
+		}
 		content, err := r.store.ReadComponent(fsPath, file)
 		if err != nil {
 			return nil, fmt.Errorf("reading CSS %s: %w", file, err)
 		}
+		if filepath.Ext(file) == ".scss" {
+			if content, err = r.compileSCSS(fsPath, file, content); err != nil {
+				return nil, err
+			}
+		}
+		if content, comp.Shared, err = r.resolveImports(content, comp.Shared, nil); err != nil {
+			return nil, fmt.Errorf("resolving imports of %s: %w", file, err)
+		}
 		cssContent.Write(content)
 		cssContent.WriteByte('\n')
+		comp.StyleFiles = append(comp.StyleFiles, StyleFile{Name: filepath.ToSlash(filepath.Join(fsPath, file)), CSS: content})
 	}
 	comp.Styles = cssContent.Bytes()
 
@@ -86,10 +189,138 @@ func (r *Registry) Load(path string) (*Component, error) {
 		comp.Scripts[file] = content
 	}
 
+	// Load all image files
+	for _, ext := range ImageExts {
+		imageFiles, err := r.store.ListComponentFiles(fsPath, ext)
+		if err != nil {
+			return nil, fmt.Errorf("listing %s files: %w", ext, err)
+		}
+		for _, file := range imageFiles {
+			content, err := r.store.ReadComponent(fsPath, file)
+			if err != nil {
+				return nil, fmt.Errorf("reading image %s: %w", file, err)
+			}
+			comp.Images[filepath.ToSlash(file)] = content
+		}
+	}
+
 	r.loaded[path] = comp
 	return comp, nil
 }
 
+// resolveImports removes a stylesheet's imports of shared stylesheets, adding each to shared after the
+// shared stylesheets it imports itself, unless it is already there
+// The lines of removed imports are kept empty, so line numbers still match the file. Importing is the
+// chain of shared stylesheets that led to this one, for cycle detection.
+func (r *Registry) resolveImports(css []byte, shared []StyleFile, importing []string) ([]byte, []StyleFile, error) {
+	for _, match := range sharedImport.FindAllSubmatch(css, -1) {
+		name := string(match[1])
+		if slices.ContainsFunc(shared, func(f StyleFile) bool { return f.Name == name }) {
+			continue
+		}
+		if slices.Contains(importing, name) {
+			return nil, nil, fmt.Errorf("import cycle detected at shared stylesheet %s", name)
+		}
+		content, err := r.readShared(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if content, shared, err = r.resolveImports(content, shared, append(importing, name)); err != nil {
+			return nil, nil, err
+		}
+		shared = append(shared, StyleFile{Name: name, CSS: content})
+	}
+	return sharedImport.ReplaceAll(css, nil), shared, nil
+}
+
+// readShared reads a shared stylesheet by its slash path relative to the source root, once per registry
+func (r *Registry) readShared(name string) ([]byte, error) {
+	if content, exists := r.shared[name]; exists {
+		return content, nil
+	}
+	if !fs.ValidPath(name) {
+		return nil, fmt.Errorf("shared stylesheet %s must be a path inside %s/", name, SharedDir)
+	}
+	content, err := r.store.ReadData(name)
+	if err != nil {
+		return nil, fmt.Errorf("reading shared stylesheet %s: %w", name, err)
+	}
+	r.shared[name] = content
+	return content, nil
+}
+
+// compileSCSS compiles one SCSS file of a component with the configured compiler
+func (r *Registry) compileSCSS(fsPath, file string, source []byte) ([]byte, error) {
+	if r.opts.SCSS == nil {
+		return nil, fmt.Errorf("SCSS file %s needs an SCSS compiler, none is configured", file)
+	}
+	css, err := r.opts.SCSS(filepath.Join(r.store.ComponentDir(fsPath), file), source)
+	if err != nil {
+		return nil, fmt.Errorf("compiling SCSS %s: %w", file, err)
+	}
+	return css, nil
+}
+
+// NotFoundError reports a component missing from the source, with the closest existing one when there is one
+type NotFoundError struct {
+	Path       string // Dot-separated component path
+	Searched   string // Directory that was looked for
+	Suggestion string // Closest existing component path, empty if none is close
+}
+
+func (e *NotFoundError) Error() string {
+	msg := fmt.Sprintf("component %s not found (searched %s)", e.Path, e.Searched)
+	if e.Suggestion != "" {
+		msg += fmt.Sprintf(", did you mean %s?", e.Suggestion)
+	}
+	return msg
+}
+
+// notFound builds the error for a missing component, suggesting the nearest known component by edit distance
+func (r *Registry) notFound(path, fsPath string) error {
+	err := &NotFoundError{
+		Path:     path,
+		Searched: r.store.ComponentDir(fsPath),
+	}
+
+	known, listErr := r.store.ListComponents()
+	if listErr != nil {
+		return err
+	}
+
+	// Only suggest names that are a plausible typo
+	best := max(len(path)/3, 2) + 1
+	for _, name := range known {
+		if d := editDistance(path, name); d < best {
+			best = d
+			err.Suggestion = name
+		}
+	}
+	return err
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
 // Get returns a loaded component
 func (r *Registry) Get(path string) *Component {
 	return r.loaded[path]
@@ -0,0 +1,78 @@
+package component
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+
+	"webfactory/src/internal/storage"
+)
+
+// newRegistry returns a registry with opts over files given by their path below the source root
+func newRegistry(files map[string]string, opts Options) *Registry {
+	source := make(map[string][]byte, len(files))
+	for name, content := range files {
+		source[name] = []byte(content)
+	}
+	return New(storage.NewMemory(source), opts)
+}
+
+func TestNotFoundSuggestion(t *testing.T) {
+	r := newRegistry(map[string]string{
+		"components/ui/button/button.html":   "<button></button>",
+		"components/ui/badge/badge.html":     "<span></span>",
+		"components/site/footer/footer.html": "<footer></footer>",
+	}, Options{})
+
+	tests := []struct {
+		path       string
+		suggestion string
+	}{
+		{"ui.buton", "ui.button"},
+		{"ui.bagde", "ui.badge"},
+		{"site.foter", "site.footer"},
+		{"gallery", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			_, err := r.Load(tt.path)
+			var notFound *NotFoundError
+			if !errors.As(err, &notFound) {
+				t.Fatalf("got error %v, want NotFoundError", err)
+			}
+			if notFound.Suggestion != tt.suggestion {
+				t.Errorf("suggested %q, want %q", notFound.Suggestion, tt.suggestion)
+			}
+		})
+	}
+}
+
+func TestNotFoundMessage(t *testing.T) {
+	err := &NotFoundError{Path: "ui.buton", Searched: "components/ui/buton", Suggestion: "ui.button"}
+	want := "component ui.buton not found (searched components/ui/buton), did you mean ui.button?"
+	if err.Error() != want {
+		t.Errorf("got %q, want %q", err.Error(), want)
+	}
+}
+
+func TestLoadImages(t *testing.T) {
+	comp, err := newRegistry(map[string]string{
+		"components/card/card.html":      "<img src=\"logo.svg\">",
+		"components/card/logo.svg":       "<svg/>",
+		"components/card/photo.jpg":      "jpeg",
+		"components/card/icons/menu.png": "png",
+		"components/card/notes.txt":      "not an image",
+	}, Options{}).Load("card")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := slices.Sorted(maps.Keys(comp.Images))
+	if want := []string{"icons/menu.png", "logo.svg", "photo.jpg"}; !slices.Equal(got, want) {
+		t.Errorf("images %q, want %q", got, want)
+	}
+	if string(comp.Images["logo.svg"]) != "<svg/>" {
+		t.Errorf("logo.svg content %q", comp.Images["logo.svg"])
+	}
+}
\ No newline at end of file
@@ -0,0 +1,70 @@
+package component
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSCSSCompiler(t *testing.T) {
+	var compiled []string
+	upper := func(path string, source []byte) ([]byte, error) {
+		compiled = append(compiled, path)
+		return bytes.ToUpper(source), nil
+	}
+	r := newRegistry(map[string]string{
+		"components/ui/card/card.html":       "<div></div>",
+		"components/ui/card/a.scss":          ".a { color: $red; }",
+		"components/ui/card/b.css":           ".b { color: red; }",
+		"components/ui/card/_vars.scss":      "$red: red;",
+		"components/ui/card/theme/dark.scss": ".dark { color: $red; }",
+	}, Options{SCSS: upper})
+
+	comp, err := r.Load("ui.card")
+	if err != nil {
+		t.Fatalf("loading: %v", err)
+	}
+	if want := ".A { COLOR: $RED; }\n.b { color: red; }\n.DARK { COLOR: $RED; }\n"; string(comp.Styles) != want {
+		t.Errorf("styles %q, want %q", comp.Styles, want)
+	}
+	if want := "components/ui/card/a.scss components/ui/card/theme/dark.scss"; strings.Join(compiled, " ") != want {
+		t.Errorf("compiled %q, want %q without the partial", compiled, want)
+	}
+	var names []string
+	for _, f := range comp.StyleFiles {
+		names = append(names, f.Name)
+	}
+	if want := "ui/card/a.scss ui/card/b.css ui/card/theme/dark.scss"; strings.Join(names, " ") != want {
+		t.Errorf("style files %q, want %q", names, want)
+	}
+}
+
+func TestSCSSCompilerError(t *testing.T) {
+	errSyntax := errors.New("line 1: expected '}'")
+	r := newRegistry(map[string]string{
+		"components/ui/card/card.html": "<div></div>",
+		"components/ui/card/card.scss": ".card {",
+	}, Options{SCSS: func(path string, source []byte) ([]byte, error) {
+		return nil, errSyntax
+	}})
+
+	_, err := r.Load("ui.card")
+	if !errors.Is(err, errSyntax) {
+		t.Fatalf("got error %v, want the compiler's error", err)
+	}
+	if !strings.Contains(err.Error(), "compiling SCSS card.scss: line 1: expected '}'") {
+		t.Errorf("error %q does not name the file", err)
+	}
+}
+
+func TestSCSSWithoutCompiler(t *testing.T) {
+	r := newRegistry(map[string]string{
+		"components/ui/card/card.html": "<div></div>",
+		"components/ui/card/card.scss": ".card {}",
+	}, Options{})
+
+	if _, err := r.Load("ui.card"); err == nil || !strings.Contains(err.Error(), "SCSS file card.scss needs an SCSS compiler, none is configured") {
+		t.Errorf("got error %v, want a missing compiler", err)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,78 @@
+package component
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSharedImports(t *testing.T) {
+	r := newRegistry(map[string]string{
+		"components/a/a.html": "<a></a>",
+		"components/a/a.css":  "@import \"shared/vars.css\";\n.a { color: var(--c); }",
+		"components/b/b.html": "<b></b>",
+		"components/b/b.css":  "@import url('shared/base.css');\n  @import \"shared/vars.css\";\n.b { margin: 0; }",
+		"shared/vars.css":     "@import \"shared/base.css\";\n:root { --c: red; }",
+		"shared/base.css":     "* { box-sizing: border-box; }",
+	}, Options{})
+
+	a, err := r.Load("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sharedNames(a); got != "shared/base.css,shared/vars.css" {
+		t.Errorf("a imports %s, want base before vars, which imports it", got)
+	}
+	if want := "\n.a { color: var(--c); }\n"; string(a.Styles) != want {
+		t.Errorf("a styles %q, want %q, the import line kept empty", a.Styles, want)
+	}
+	if css := string(a.Shared[1].CSS); css != "\n:root { --c: red; }" {
+		t.Errorf("vars.css resolved to %q, without its import", css)
+	}
+
+	b, err := r.Load("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sharedNames(b); got != "shared/base.css,shared/vars.css" {
+		t.Errorf("b imports %s, want base once", got)
+	}
+}
+
+// sharedNames lists the shared stylesheets of a component, comma separated
+func sharedNames(comp *Component) string {
+	names := make([]string, len(comp.Shared))
+	for i, f := range comp.Shared {
+		names[i] = f.Name
+	}
+	return strings.Join(names, ",")
+}
+
+func TestSharedImportErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name:  "missing",
+			files: map[string]string{"shared/other.css": ""},
+			want:  "reading shared stylesheet shared/vars.css: ",
+		},
+		{
+			name:  "cycle",
+			files: map[string]string{"shared/vars.css": "@import \"shared/base.css\";", "shared/base.css": "@import \"shared/vars.css\";"},
+			want:  "import cycle detected at shared stylesheet shared/vars.css",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.files["components/a/a.html"] = "<a></a>"
+			tt.files["components/a/a.css"] = "@import \"shared/vars.css\";"
+			_, err := newRegistry(tt.files, Options{}).Load("a")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
\ No newline at end of file
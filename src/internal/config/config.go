@@ -0,0 +1,106 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// FileName is the optional config file looked up at the source root
+const FileName = "webfactory.json"
+
+// File holds the build options set by a config file, nil fields were not present in the file
+type File struct {
+	Target             *string   `json:"target"`
+	Log                *string   `json:"log"`
+	Components         *string   `json:"components"`
+	Serve              *bool     `json:"serve"`
+	Watch              *bool     `json:"watch"`
+	Port               *int      `json:"port"`
+	Generator          *bool     `json:"generator"`
+	Head               *bool     `json:"head"`
+	DedupeCSS          *bool     `json:"dedupe-css"`
+	CSSMap             *bool     `json:"css-map"`
+	Inline             *int      `json:"inline"`
+	SRI                *bool     `json:"sri"`
+	Preload            *bool     `json:"preload"`
+	RedirectDuplicates *bool     `json:"redirect-duplicates"`
+	Gzip               *bool     `json:"gzip"`
+	Brotli             *bool     `json:"brotli"`
+	CompressMin        *int      `json:"compress-min"`
+	FileMode           *string   `json:"file-mode"`
+	DirMode            *string   `json:"dir-mode"`
+	Env                *[]string `json:"env"`
+	Base               *string   `json:"base"`
+	BaseURL            *string   `json:"base-url"`
+	ErrorPage          *string   `json:"error-page"`
+	FailOnWarning      *bool     `json:"fail-on-warning"`
+	StrictVars         *bool     `json:"strict-vars"`
+	PageCSS            *bool     `json:"page-css"`
+	FollowSymlinks     *bool     `json:"follow-symlinks"`
+	Clean              *bool     `json:"clean"`
+	Feed               *Feed     `json:"feed"`
+}
+
+// Feed configures the RSS feed of a page collection
+type Feed struct {
+	Collection  string `json:"collection"`
+	Path        string `json:"path"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	URL         string `json:"url"`
+}
+
+// Load reads the config file from dir, returning an empty File if there is none
+// Unknown keys do not fail the load and are returned as warnings
+func Load(dir string) (*File, []string, error) {
+	path := filepath.Join(dir, FileName)
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &File{}, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading config: %w", err)
+	}
+
+	return Parse(content)
+}
+
+// Parse decodes config file content, returning warnings for unknown keys
+func Parse(content []byte) (*File, []string, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return nil, nil, fmt.Errorf("parsing config: %w", err)
+	}
+
+	known := knownKeys()
+	var warnings []string
+	for key := range raw {
+		if !known[key] {
+			warnings = append(warnings, fmt.Sprintf("unknown config key %q ignored", key))
+		}
+	}
+	sort.Strings(warnings)
+
+	file := &File{}
+	if err := json.Unmarshal(content, file); err != nil {
+		return nil, nil, fmt.Errorf("parsing config: %w", err)
+	}
+
+	return file, warnings, nil
+}
+
+// knownKeys returns the JSON keys of all File fields
+func knownKeys() map[string]bool {
+	keys := make(map[string]bool)
+	t := reflect.TypeOf(File{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		keys[name] = true
+	}
+	return keys
+}
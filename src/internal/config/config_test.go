@@ -0,0 +1,70 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	file, warnings, err := Parse([]byte(`{"port": 9000, "gzip": true, "feed": {"collection": "blog"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Port == nil || *file.Port != 9000 || file.Gzip == nil || !*file.Gzip {
+		t.Errorf("values not decoded: port %v, gzip %v", file.Port, file.Gzip)
+	}
+	if file.Target != nil || file.Brotli != nil {
+		t.Errorf("absent keys are set: target %v, brotli %v", file.Target, file.Brotli)
+	}
+	if file.Feed == nil || file.Feed.Collection != "blog" {
+		t.Errorf("feed not decoded: %+v", file.Feed)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("unexpected warnings %q", warnings)
+	}
+}
+
+func TestParseUnknownKey(t *testing.T) {
+	file, warnings, err := Parse([]byte(`{"port": 9000, "prot": 9001, "gzpi": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{`unknown config key "gzpi" ignored`, `unknown config key "prot" ignored`}
+	if !slices.Equal(warnings, want) {
+		t.Errorf("got warnings %q, want %q", warnings, want)
+	}
+	if file.Port == nil || *file.Port != 9000 {
+		t.Errorf("known key lost next to unknown ones: %v", file.Port)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, content := range []string{`{"port": `, `{"port": "9000"}`} {
+		if _, _, err := Parse([]byte(content)); err == nil {
+			t.Errorf("Parse(%s) succeeded, want an error", content)
+		}
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	file, warnings, err := Load(t.TempDir())
+	if err != nil || warnings != nil || *file != (File{}) {
+		t.Errorf("got %+v, %q, %v, want an empty File", file, warnings, err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(`{"target": "out"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file, _, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Target == nil || *file.Target != "out" {
+		t.Errorf("target = %v, want out", file.Target)
+	}
+}
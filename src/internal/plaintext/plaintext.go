@@ -0,0 +1,207 @@
+package plaintext
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// blockTags start and end on their own line
+var blockTags = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "dd": true, "div": true,
+	"dl": true, "dt": true, "fieldset": true, "figcaption": true, "figure": true, "footer": true,
+	"form": true, "header": true, "li": true, "main": true, "nav": true, "ol": true, "section": true,
+	"table": true, "tr": true, "ul": true,
+}
+
+// spacedTags are also separated from the surrounding text by a blank line
+var spacedTags = map[string]bool{
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "p": true, "pre": true,
+}
+
+// skipTags have content that is never readable text
+var skipTags = map[string]bool{
+	"head": true, "script": true, "style": true, "template": true, "noscript": true, "svg": true,
+}
+
+var hrefAttr = regexp.MustCompile(`(?is)\bhref\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+
+// link is an anchor being rendered, its text collected until the closing tag
+type link struct {
+	href  string
+	start int // length of the output when the anchor opened
+}
+
+// Render converts an HTML page to readable plain text
+// Block elements start new lines, headings and paragraphs are separated by blank lines, list items
+// are prefixed with "- ", and links are written as "text (url)". Scripts, styles, and the head are dropped.
+func Render(page []byte) string {
+	r := renderer{}
+	src := string(page)
+
+	for len(src) > 0 {
+		lt := strings.IndexByte(src, '<')
+		if lt == -1 {
+			r.text(src)
+			break
+		}
+		r.text(src[:lt])
+		src = src[lt:]
+
+		if strings.HasPrefix(src, "<!--") {
+			end := strings.Index(src, "-->")
+			if end == -1 {
+				break
+			}
+			src = src[end+3:]
+			continue
+		}
+
+		gt := strings.IndexByte(src, '>')
+		if gt == -1 {
+			r.text(src)
+			break
+		}
+		tag := src[1:gt]
+		src = src[gt+1:]
+
+		closing := strings.HasPrefix(tag, "/")
+		name := strings.ToLower(strings.TrimLeft(tag, "/"))
+		if i := strings.IndexAny(name, " \t\r\n/"); i != -1 {
+			name = name[:i]
+		}
+
+		if skipTags[name] && !closing && !strings.HasSuffix(tag, "/") {
+			// Drop everything up to the matching close tag
+			end := strings.Index(strings.ToLower(src), "</"+name)
+			if end == -1 {
+				break
+			}
+			src = src[end:]
+			continue
+		}
+
+		r.tag(name, tag, closing)
+	}
+
+	return r.String()
+}
+
+type renderer struct {
+	out   strings.Builder
+	pre   int // depth of open pre elements, whose whitespace is kept
+	links []link
+}
+
+// text writes character data, collapsing whitespace outside pre elements
+func (r *renderer) text(s string) {
+	s = html.UnescapeString(s)
+	if r.pre > 0 {
+		r.out.WriteString(s)
+		return
+	}
+
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		if s != "" {
+			r.space()
+		}
+		return
+	}
+	if isSpace(s[0]) {
+		r.space()
+	}
+	r.out.WriteString(strings.Join(fields, " "))
+	if isSpace(s[len(s)-1]) {
+		r.space()
+	}
+}
+
+// tag applies the line structure of an element
+func (r *renderer) tag(name, raw string, closing bool) {
+	switch {
+	case name == "br":
+		r.newline()
+	case name == "hr":
+		r.paragraph()
+	case name == "pre":
+		r.paragraph()
+		if closing {
+			r.pre = max(r.pre-1, 0)
+		} else {
+			r.pre++
+		}
+	case spacedTags[name]:
+		r.paragraph()
+	case blockTags[name]:
+		r.newline()
+		if name == "li" && !closing {
+			r.out.WriteString("- ")
+		}
+	case name == "td" || name == "th":
+		if !closing {
+			r.space()
+		}
+	case name == "a" && !closing:
+		r.links = append(r.links, link{href: href(raw), start: r.out.Len()})
+	case name == "a" && len(r.links) > 0:
+		l := r.links[len(r.links)-1]
+		r.links = r.links[:len(r.links)-1]
+		out := r.out.String()
+		text := strings.TrimSpace(out[min(l.start, len(out)):])
+		if l.href != "" && l.href != text && !strings.HasPrefix(l.href, "#") {
+			r.out.WriteString(" (" + l.href + ")")
+		}
+	}
+}
+
+// space separates words unless the output already ends in whitespace
+func (r *renderer) space() {
+	if s := r.out.String(); s != "" && !isSpace(s[len(s)-1]) {
+		r.out.WriteByte(' ')
+	}
+}
+
+// newline ends the current line unless it is already empty
+func (r *renderer) newline() {
+	s := strings.TrimRight(r.out.String(), " \t")
+	r.out.Reset()
+	r.out.WriteString(s)
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		r.out.WriteByte('\n')
+	}
+}
+
+// paragraph ends the current line and leaves one blank line
+func (r *renderer) paragraph() {
+	r.newline()
+	if s := r.out.String(); s != "" && !strings.HasSuffix(s, "\n\n") {
+		r.out.WriteByte('\n')
+	}
+}
+
+// String returns the text with trailing spaces trimmed from every line and a single final newline
+func (r *renderer) String() string {
+	lines := strings.Split(r.out.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	text := strings.Trim(strings.Join(lines, "\n"), "\n")
+	if text == "" {
+		return ""
+	}
+	return text + "\n"
+}
+
+// href returns the unescaped href attribute of a raw tag
+func href(tag string) string {
+	m := hrefAttr.FindStringSubmatch(tag)
+	if m == nil {
+		return ""
+	}
+	return html.UnescapeString(m[1] + m[2] + m[3])
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
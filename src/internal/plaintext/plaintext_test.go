@@ -0,0 +1,50 @@
+package plaintext
+
+import "testing"
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name string
+		page string
+		want string
+	}{
+		{
+			name: "headings become lines",
+			page: "<h1>Weekly  news</h1><h2>Releases</h2><p>Two\n   releases.</p>",
+			want: "Weekly news\n\nReleases\n\nTwo releases.\n",
+		},
+		{
+			name: "links followed by their url",
+			page: `<p>Read <a href="https://example.com/post?a=1&amp;b=2">the post</a> or <a href="#top">go up</a>.</p>`,
+			want: "Read the post (https://example.com/post?a=1&b=2) or go up.\n",
+		},
+		{
+			name: "link showing its url",
+			page: `<p><a href="https://example.com">https://example.com</a></p>`,
+			want: "https://example.com\n",
+		},
+		{
+			name: "lists",
+			page: "<ul>\n  <li>one</li>\n  <li>two</li>\n</ul>",
+			want: "- one\n- two\n",
+		},
+		{
+			name: "dropped elements",
+			page: "<head><title>T</title><style>p{}</style></head><body><script>x()</script><!-- note --><p>Body</p></body>",
+			want: "Body\n",
+		},
+		{
+			name: "preformatted",
+			page: "<p>Code:</p><pre>a  = 1\n  b</pre><p>done</p>",
+			want: "Code:\n\na  = 1\n  b\n\ndone\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render([]byte(tt.page)); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package scaffold
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Component creates a starter component with template, stylesheet, and script under the source's
+// components directory, returning the created files
+// The path may use dots or slashes; an existing component is never overwritten.
+func Component(source, path string) ([]string, error) {
+	parts := splitPath(path)
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("invalid component path %q", path)
+	}
+	dotted := strings.Join(parts, ".")
+	name := parts[len(parts)-1]
+
+	dir := filepath.Join(append([]string{source, "components"}, parts...)...)
+	if _, err := os.Stat(dir); err == nil {
+		return nil, fmt.Errorf("component %s already exists at %s", dotted, dir)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating component directory: %w", err)
+	}
+
+	files := []struct {
+		name    string
+		content string
+	}{
+		{name + ".html", fmt.Sprintf("<div class=\"%s\">\n    {{.content}}\n</div>\n", name)},
+		{name + ".css", fmt.Sprintf("/* Styles for %s, merged into the page stylesheet */\n.%s {\n}\n", dotted, name)},
+		{name + ".js", fmt.Sprintf("// Script for %s, linked after the page content\n", dotted)},
+	}
+
+	created := make([]string, 0, len(files))
+	for _, f := range files {
+		path := filepath.Join(dir, f.name)
+		if err := os.WriteFile(path, []byte(f.content), 0644); err != nil {
+			return created, fmt.Errorf("writing %s: %w", f.name, err)
+		}
+		created = append(created, path)
+	}
+
+	return created, nil
+}
+
+// Blueprint creates a starter blueprint with front matter under the source's blueprints directory,
+// returning the created file
+// The name may include subdirectories; an existing blueprint is never overwritten.
+func Blueprint(source, name string) (string, error) {
+	name = strings.TrimSuffix(filepath.ToSlash(name), ".blueprint")
+	if name == "" || strings.HasSuffix(name, "/") || !filepath.IsLocal(filepath.FromSlash(name)) {
+		return "", fmt.Errorf("invalid blueprint name %q", name)
+	}
+
+	path := filepath.Join(source, "blueprints", filepath.FromSlash(name)+".blueprint")
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("blueprint %s already exists at %s", name, path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("creating blueprint directory: %w", err)
+	}
+
+	content := fmt.Sprintf("---\ntitle = %s\n---\n", filepath.Base(name))
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return "", fmt.Errorf("writing blueprint: %w", err)
+	}
+
+	return path, nil
+}
+
+// splitPath splits a dotted or slash-separated component path into its segments
+func splitPath(path string) []string {
+	return strings.FieldsFunc(path, func(r rune) bool {
+		return r == '.' || r == '/' || r == '\\'
+	})
+}
@@ -0,0 +1,75 @@
+package scaffold
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"webfactory/src/internal/blueprint"
+	"webfactory/src/internal/component"
+	"webfactory/src/internal/storage"
+)
+
+func TestComponentLoads(t *testing.T) {
+	source := t.TempDir()
+	created, err := Component(source, "ui.cards/promo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 3 {
+		t.Errorf("created %v, want template, stylesheet, and script", created)
+	}
+
+	registry := component.New(storage.NewDisk([]string{source}, "", storage.DiskOptions{}), component.Options{})
+	comp, err := registry.Load("ui.cards.promo")
+	if err != nil {
+		t.Fatalf("scaffolded component does not load: %v", err)
+	}
+	if len(comp.Template) == 0 || len(comp.Styles) == 0 || len(comp.Scripts["promo.js"]) == 0 {
+		t.Errorf("scaffolded component is missing files: template %q, styles %q, scripts %v", comp.Template, comp.Styles, comp.Scripts)
+	}
+}
+
+func TestComponentExists(t *testing.T) {
+	source := t.TempDir()
+	if _, err := Component(source, "ui.btn"); err != nil {
+		t.Fatal(err)
+	}
+	html := filepath.Join(source, "components", "ui", "btn", "btn.html")
+	if err := os.WriteFile(html, []byte("<button>mine</button>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Component(source, "ui/btn"); err == nil {
+		t.Error("existing component scaffolded again")
+	}
+	if content, _ := os.ReadFile(html); string(content) != "<button>mine</button>" {
+		t.Errorf("existing template overwritten: %q", content)
+	}
+}
+
+func TestBlueprintParses(t *testing.T) {
+	source := t.TempDir()
+	path, err := Blueprint(source, "docs/intro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(source, "blueprints", "docs", "intro.blueprint"); path != want {
+		t.Errorf("created %s, want %s", path, want)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := blueprint.New(string(content))
+	if err != nil || tree.Meta["title"] != "intro" {
+		t.Errorf("scaffolded blueprint parses to %v (error %v), want title intro", tree, err)
+	}
+
+	if _, err := Blueprint(source, "docs/intro.blueprint"); err == nil {
+		t.Error("existing blueprint scaffolded again")
+	}
+	if _, err := Blueprint(source, "../outside"); err == nil {
+		t.Error("blueprint created outside the blueprints directory")
+	}
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// reloadPath is the server-sent events endpoint the live reload script listens on
+const reloadPath = "/__webfactory/reload"
+
+const reloadScript = `<script>new EventSource("` + reloadPath + `").onmessage = function () { location.reload(); };</script>`
+
+// Server serves the generated site and tells connected browsers to reload after each build
+type Server struct {
+	root     string
+	base     string // URL path the site is served under, without trailing slash
+	addr     string
+	mu       sync.Mutex
+	clients  map[chan struct{}]struct{}
+	buildErr error
+}
+
+// New creates a Server for the target directory listening on the given port
+// With a base path such as /docs/, the site is served under it and other paths are not found.
+func New(root string, port int, base string) *Server {
+	if base = strings.Trim(base, "/"); base != "" {
+		base = "/" + base
+	}
+
+	return &Server{
+		root:    root,
+		base:    base,
+		addr:    fmt.Sprintf(":%d", port),
+		clients: make(map[chan struct{}]struct{}),
+	}
+}
+
+// Base returns the URL path the site is served under, "/" without a base path
+func (s *Server) Base() string {
+	return s.base + "/"
+}
+
+// Addr returns the address the server listens on
+func (s *Server) Addr() string {
+	return s.addr
+}
+
+// ListenAndServe serves the site until the server fails
+func (s *Server) ListenAndServe() error {
+	return http.ListenAndServe(s.addr, s)
+}
+
+// Reload records the result of the latest build and notifies all connected browsers
+func (s *Server) Reload(buildErr error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.buildErr = buildErr
+	for ch := range s.clients {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// ServeHTTP serves the reload endpoint, HTML pages with the reload script injected, and other files as-is
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == reloadPath {
+		s.serveEvents(w, r)
+		return
+	}
+
+	if s.base != "" {
+		rest, ok := strings.CutPrefix(r.URL.Path, s.base)
+		if !ok || rest != "" && !strings.HasPrefix(rest, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		r.URL.Path = "/" + strings.TrimPrefix(rest, "/")
+	}
+
+	urlPath := path.Clean("/" + r.URL.Path)
+	fsPath := filepath.Join(s.root, filepath.FromSlash(urlPath))
+	if info, err := os.Stat(fsPath); err == nil && info.IsDir() {
+		fsPath = filepath.Join(fsPath, "index.html")
+	}
+
+	if filepath.Ext(fsPath) != ".html" {
+		http.FileServer(http.Dir(s.root)).ServeHTTP(w, r)
+		return
+	}
+
+	content, err := os.ReadFile(fsPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// Keep the reload script on missing pages so they recover after a fixing rebuild
+		w.WriteHeader(http.StatusNotFound)
+		content = []byte("<h1>404 page not found</h1>")
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(s.inject(content))
+}
+
+// inject adds the reload script and, after a failed build, an error banner to an HTML page
+func (s *Server) inject(page []byte) []byte {
+	s.mu.Lock()
+	buildErr := s.buildErr
+	s.mu.Unlock()
+
+	var extra bytes.Buffer
+	if buildErr != nil {
+		extra.WriteString(`<pre style="position:fixed;top:0;left:0;right:0;margin:0;padding:1em;` +
+			`background:#b00020;color:#fff;white-space:pre-wrap;z-index:2147483647">`)
+		extra.WriteString(html.EscapeString("Build failed: " + buildErr.Error()))
+		extra.WriteString("</pre>")
+	}
+	extra.WriteString(reloadScript)
+
+	if i := bytes.LastIndex(page, []byte("</body>")); i != -1 {
+		var buf bytes.Buffer
+		buf.Write(page[:i])
+		buf.Write(extra.Bytes())
+		buf.Write(page[i:])
+		return buf.Bytes()
+	}
+	return append(page, extra.Bytes()...)
+}
+
+// serveEvents streams a reload event to the browser after every build
+func (s *Server) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher.Flush()
+
+	ch := make(chan struct{}, 1)
+	s.mu.Lock()
+	s.clients[ch] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, ch)
+		s.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ch:
+			if _, err := fmt.Fprint(w, "data: reload\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSite creates a Server for a target directory holding files, given by slash-separated path
+func newSite(t *testing.T, base string, files map[string]string) *Server {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return New(root, 0, base)
+}
+
+// get serves a request for target, returning the status code and body
+func get(s *Server, target string) (int, string) {
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	return rec.Code, rec.Body.String()
+}
+
+func TestReloadScriptInjected(t *testing.T) {
+	s := newSite(t, "", map[string]string{
+		"index.html":    "<html><body><p>home</p></body></html>",
+		"bare.html":     "<p>no body</p>",
+		"css/site.css":  "p { margin: 0; }",
+		"docs/api.html": "<body>api</body>",
+	})
+
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"/", "<html><body><p>home</p>" + reloadScript + "</body></html>"},
+		{"/bare.html", "<p>no body</p>" + reloadScript},
+		{"/docs/api.html", "<body>api" + reloadScript + "</body>"},
+		{"/css/site.css", "p { margin: 0; }"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			if code, body := get(s, tt.target); code != http.StatusOK || body != tt.want {
+				t.Errorf("got %d %q, want 200 %q", code, body, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildErrorBanner(t *testing.T) {
+	s := newSite(t, "", map[string]string{"index.html": "<body>home</body>"})
+
+	s.Reload(errors.New("component <card> not found"))
+	_, body := get(s, "/")
+	banner := strings.Index(body, "Build failed: component &lt;card&gt; not found</pre>")
+	script := strings.Index(body, reloadScript)
+	if banner == -1 || script < banner || !strings.HasSuffix(body, "</body>") {
+		t.Errorf("escaped banner before the reload script missing: %q", body)
+	}
+
+	// A fixing build clears the banner
+	s.Reload(nil)
+	if _, body := get(s, "/"); strings.Contains(body, "Build failed") {
+		t.Errorf("banner kept after a successful build: %q", body)
+	}
+}
+
+func TestNotFoundKeepsReloadScript(t *testing.T) {
+	s := newSite(t, "", map[string]string{"index.html": "<body>home</body>"})
+
+	code, body := get(s, "/missing.html")
+	if code != http.StatusNotFound || !strings.Contains(body, "404 page not found") || !strings.HasSuffix(body, reloadScript) {
+		t.Errorf("got %d %q, want a 404 page with the reload script", code, body)
+	}
+}
+
+func TestReloadEvents(t *testing.T) {
+	s := newSite(t, "", nil)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + reloadPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("content type %q, want text/event-stream", got)
+	}
+
+	// The headers are flushed before the client is registered, so wait for it to be
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		s.mu.Lock()
+		n := len(s.clients)
+		s.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("event stream client never registered")
+		}
+	}
+
+	s.Reload(nil)
+	event := make([]byte, len("data: reload\n\n"))
+	if _, err := io.ReadFull(resp.Body, event); err != nil || string(event) != "data: reload\n\n" {
+		t.Errorf("got event %q (error %v), want a reload", event, err)
+	}
+}
+
+func TestBasePath(t *testing.T) {
+	s := newSite(t, "/docs/", map[string]string{
+		"index.html":     "<body>home</body>",
+		"api/index.html": "<body>api</body>",
+		"css/site.css":   "p { margin: 0; }",
+	})
+	if got := s.Base(); got != "/docs/" {
+		t.Errorf("Base() = %q, want /docs/", got)
+	}
+
+	tests := []struct {
+		target string
+		code   int
+		want   string
+	}{
+		{"/docs/", http.StatusOK, "home"},
+		{"/docs", http.StatusOK, "home"},
+		{"/docs/api/", http.StatusOK, "api"},
+		{"/docs/css/site.css", http.StatusOK, "p { margin: 0; }"},
+		{"/", http.StatusNotFound, ""},
+		{"/index.html", http.StatusNotFound, ""},
+		{"/docsx/index.html", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			code, body := get(s, tt.target)
+			if code != tt.code || !strings.Contains(body, tt.want) {
+				t.Errorf("got %d %q, want %d with %q", code, body, tt.code, tt.want)
+			}
+		})
+	}
+}
\ No newline at end of file
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/andybalholm/brotli"
+)
+
+// compressible lists the text output types worth pre-compressing
+var compressible = map[string]bool{
+	".html": true,
+	".css":  true,
+	".js":   true,
+	".svg":  true,
+	".json": true,
+	".xml":  true,
+	".txt":  true,
+}
+
+// writeCompressed writes the enabled pre-compressed copies of a file next to it
+// A copy that would not be smaller than the original is not written, and a stale one is removed
+// so the host never serves content from an earlier build.
+func (s *Storage) writeCompressed(fullPath string, content []byte) error {
+	if !compressible[filepath.Ext(fullPath)] {
+		return nil
+	}
+	small := len(content) < s.opts.CompressMin
+
+	if s.opts.Gzip {
+		if err := s.writeCopy(fullPath+".gz", content, small, gzipBytes); err != nil {
+			return err
+		}
+	}
+	if s.opts.Brotli {
+		if err := s.writeCopy(fullPath+".br", content, small, brotliBytes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeCopy compresses content into path, removing path instead when compression is skipped or doesn't help
+func (s *Storage) writeCopy(path string, content []byte, skip bool, compress func([]byte) ([]byte, error)) error {
+	if !skip {
+		compressed, err := compress(content)
+		if err != nil {
+			return fmt.Errorf("compressing %s: %w", path, err)
+		}
+		if len(compressed) < len(content) {
+			return s.writeFile(path, compressed)
+		}
+	}
+
+	if s.opts.DryRun {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func gzipBytes(content []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(content); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func brotliBytes(content []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := brotli.NewWriterLevel(&buf, brotli.BestCompression)
+	if _, err := w.Write(content); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,222 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Disk is a Source reading from source root directories on disk
+type Disk struct {
+	sources []string // source roots in priority order, earlier roots override later ones
+	library string   // standalone component directory searched after the sources' components directories
+	opts    DiskOptions
+}
+
+// DiskOptions controls how a Disk source reads its directories
+type DiskOptions struct {
+	// FollowSymlinks lists the files and directories symbolic links point to, such as a component
+	// directory shared across projects, instead of skipping them. Links may not lead out of their source
+	// root or the library, and a directory linking back to one of its parents is an error.
+	FollowSymlinks bool
+}
+
+// NewDisk creates a Disk source reading from the given source roots in priority order
+// and the optional component library
+func NewDisk(sources []string, library string, opts DiskOptions) *Disk {
+	return &Disk{sources: sources, library: library, opts: opts}
+}
+
+// ListBlueprints lists the page blueprints of all source roots and their output paths
+// A root without a blueprints directory is skipped, but at least one root must have one.
+func (d *Disk) ListBlueprints(ctx context.Context) (map[string]string, error) {
+	blueprints := make(map[string]string)
+	found := false
+
+	for _, root := range d.sources {
+		blueprintsDir := filepath.Join(root, "blueprints")
+		if info, err := os.Stat(blueprintsDir); err != nil || !info.IsDir() {
+			continue
+		}
+		found = true
+
+		err := d.walk(blueprintsDir, func(path string, info os.FileInfo, err error) error {
+			if err == nil {
+				err = ctx.Err()
+			}
+			if err != nil || info.IsDir() || !strings.HasSuffix(path, ".blueprint") {
+				return err
+			}
+
+			// Partials are only used through @include, not built as pages
+			if strings.HasPrefix(info.Name(), "_") {
+				return nil
+			}
+
+			rel, err := filepath.Rel(blueprintsDir, path)
+			if err != nil {
+				return err
+			}
+
+			// ReadBlueprint resolves the same path to the earliest root
+			blueprints[rel] = pagePath(rel)
+			return nil
+		})
+
+		if err != nil {
+			return nil, fmt.Errorf("scanning blueprints: %w", err)
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("scanning blueprints: no blueprints directory in %s", strings.Join(d.sources, ", "))
+	}
+
+	return blueprints, nil
+}
+
+// pagePath maps a blueprint path relative to blueprints/ to its page output path without extension
+// Pages mirror the blueprint's location, so index blueprints become directory index pages that static
+// hosts serve for the directory URL: blueprints/index.blueprint for / and blueprints/blog/index.blueprint
+// for /blog/. Any other blueprint keeps its name, blueprints/blog/post.blueprint becoming blog/post.html.
+func pagePath(rel string) string {
+	return strings.TrimSuffix(rel, ".blueprint")
+}
+
+// ReadBlueprint reads a blueprint file from the first source root that has it
+func (d *Disk) ReadBlueprint(path string) ([]byte, error) {
+	return os.ReadFile(d.sourceFile(filepath.Join("blueprints", path)))
+}
+
+// ReadGlobals reads the site-wide globals file from the first source root that has one
+func (d *Disk) ReadGlobals() ([]byte, error) {
+	return os.ReadFile(d.sourceFile("globals.vars"))
+}
+
+// ReadData reads a data file from the first source root that has it
+func (d *Disk) ReadData(name string) ([]byte, error) {
+	return os.ReadFile(d.sourceFile(filepath.FromSlash(name)))
+}
+
+// ReadComponent reads a component file (template, css, js) from disk
+func (d *Disk) ReadComponent(componentPath, filename string) ([]byte, error) {
+	fullPath := filepath.Join(d.ComponentDir(componentPath), filename)
+	return os.ReadFile(fullPath)
+}
+
+// sourceFile returns the path of rel in the first source root that has it, or in the first root if none does
+func (d *Disk) sourceFile(rel string) string {
+	for _, root := range d.sources {
+		path := filepath.Join(root, rel)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(d.sources[0], rel)
+}
+
+// ListComponentFiles lists all files in a component directory, optionally filtered by extension
+func (d *Disk) ListComponentFiles(componentPath string, ext string) ([]string, error) {
+	dir := d.ComponentDir(componentPath)
+	var files []string
+
+	err := d.walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf("walking component files: %w", err)
+		}
+		if !info.IsDir() {
+			if ext != "" && filepath.Ext(path) != ext {
+				return nil
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, rel)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("listing component files: %w", err)
+	}
+
+	return files, nil
+}
+
+// ComponentDir returns the directory a component is read from
+// A component comes whole from the first component root that has it, its files are never mixed across roots.
+func (d *Disk) ComponentDir(componentPath string) string {
+	roots := d.componentRoots()
+	for _, root := range roots {
+		dir := filepath.Join(root, componentPath)
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+	}
+	return filepath.Join(roots[0], componentPath)
+}
+
+// componentRoots lists the directories components are searched in: each source's components directory,
+// then the library
+func (d *Disk) componentRoots() []string {
+	roots := make([]string, 0, len(d.sources)+1)
+	for _, source := range d.sources {
+		roots = append(roots, filepath.Join(source, "components"))
+	}
+	if d.library != "" {
+		roots = append(roots, d.library)
+	}
+	return roots
+}
+
+// HasComponent reports whether a component directory exists
+func (d *Disk) HasComponent(componentPath string) bool {
+	info, err := os.Stat(d.ComponentDir(componentPath))
+	return err == nil && info.IsDir()
+}
+
+// ListComponents lists the dot-separated paths of all components, directories directly holding a template,
+// across all component roots
+func (d *Disk) ListComponents() ([]string, error) {
+	seen := make(map[string]bool)
+	var components []string
+
+	for _, root := range d.componentRoots() {
+		err := d.walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			if ext := filepath.Ext(path); ext != ".html" && ext != ".md" {
+				return nil
+			}
+
+			rel, err := filepath.Rel(root, filepath.Dir(path))
+			if err != nil || rel == "." {
+				return err
+			}
+			name := strings.ReplaceAll(rel, string(filepath.Separator), ".")
+			if !seen[name] {
+				seen[name] = true
+				components = append(components, name)
+			}
+			return nil
+		})
+
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("listing components: %w", err)
+		}
+	}
+
+	return components, nil
+}
+
+// FindTemplateFile finds the single HTML or Markdown template file in component directory
+func (d *Disk) FindTemplateFile(componentPath string) (string, error) {
+	return findTemplateFile(d, componentPath)
+}
@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// writeTree creates files, given by slash-separated path below root
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// listBlueprints lists the blueprints of a Disk with slash-separated paths
+func listBlueprints(t *testing.T, d *Disk) map[string]string {
+	t.Helper()
+	blueprints, err := d.ListBlueprints(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	slashed := make(map[string]string, len(blueprints))
+	for path, output := range blueprints {
+		slashed[filepath.ToSlash(path)] = filepath.ToSlash(output)
+	}
+	return slashed
+}
+
+func TestListNestedBlueprints(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"blueprints/about.blueprint":            "1 page",
+		"blueprints/docs/api.blueprint":         "1 page",
+		"blueprints/docs/api/v2.blueprint":      "1 page",
+		"blueprints/docs/api/v2/auth.blueprint": "1 page",
+		"blueprints/docs/_nav.blueprint":        "1 nav",
+		"blueprints/docs/notes.txt":             "not a blueprint",
+	})
+
+	want := map[string]string{
+		"about.blueprint":            "about",
+		"docs/api.blueprint":         "docs/api",
+		"docs/api/v2.blueprint":      "docs/api/v2",
+		"docs/api/v2/auth.blueprint": "docs/api/v2/auth",
+	}
+	if got := listBlueprints(t, NewDisk([]string{root}, "", DiskOptions{})); !maps.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestListIndexBlueprints(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"blueprints/index.blueprint":      "1 page",
+		"blueprints/about.blueprint":      "1 page",
+		"blueprints/blog/index.blueprint": "1 page",
+		"blueprints/blog/first.blueprint": "1 page",
+		"blueprints/blog.blueprint":       "1 page",
+	})
+
+	want := map[string]string{
+		"index.blueprint":      "index",
+		"about.blueprint":      "about",
+		"blog/index.blueprint": "blog/index",
+		"blog/first.blueprint": "blog/first",
+		"blog.blueprint":       "blog",
+	}
+	if got := listBlueprints(t, NewDisk([]string{root}, "", DiskOptions{})); !maps.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestLayeredSources(t *testing.T) {
+	site, shared := t.TempDir(), t.TempDir()
+	writeTree(t, site, map[string]string{
+		"blueprints/index.blueprint": "1 ui.btn",
+		"components/ui/btn/btn.html": "<button>site</button>",
+		"globals.vars":               ".site = Site",
+	})
+	writeTree(t, shared, map[string]string{
+		"blueprints/index.blueprint":   "1 other",
+		"blueprints/legal.blueprint":   "1 ui.btn",
+		"components/ui/btn/btn.html":   "<button>shared</button>",
+		"components/ui/btn/btn.css":    "button {}",
+		"components/ui/card/card.html": "<div></div>",
+		"globals.vars":                 ".site = Shared",
+	})
+	d := NewDisk([]string{site, shared}, "", DiskOptions{})
+
+	want := map[string]string{"index.blueprint": "index", "legal.blueprint": "legal"}
+	if got := listBlueprints(t, d); !maps.Equal(got, want) {
+		t.Errorf("blueprints %v, want the union %v", got, want)
+	}
+	if got, err := d.ReadBlueprint("index.blueprint"); err != nil || string(got) != "1 ui.btn" {
+		t.Errorf("index.blueprint = %q (error %v), want the earlier source's", got, err)
+	}
+	if got, err := d.ReadGlobals(); err != nil || string(got) != ".site = Site" {
+		t.Errorf("globals = %q (error %v), want the earlier source's", got, err)
+	}
+
+	// The overriding component comes whole from the earlier source, without the later one's stylesheet
+	if got, err := d.ReadComponent(filepath.Join("ui", "btn"), "btn.html"); err != nil || string(got) != "<button>site</button>" {
+		t.Errorf("ui.btn template = %q (error %v), want the earlier source's", got, err)
+	}
+	if files, err := d.ListComponentFiles(filepath.Join("ui", "btn"), ".css"); err != nil || len(files) != 0 {
+		t.Errorf("ui.btn stylesheets %v (error %v), want none", files, err)
+	}
+	if !d.HasComponent(filepath.Join("ui", "card")) {
+		t.Error("component only in the later source not found")
+	}
+}
+
+func TestComponentLibrary(t *testing.T) {
+	site, library := t.TempDir(), t.TempDir()
+	writeTree(t, site, map[string]string{
+		"blueprints/index.blueprint": "1 ui.btn",
+		"components/ui/btn/btn.html": "<button>local</button>",
+	})
+	writeTree(t, library, map[string]string{
+		"ui/btn/btn.html":   "<button>library</button>",
+		"ui/btn/btn.js":     "library();",
+		"ui/card/card.html": "<div>library</div>",
+	})
+	d := NewDisk([]string{site}, library, DiskOptions{})
+
+	tests := []struct {
+		component string
+		want      string
+		dir       string
+	}{
+		{"ui/btn", "<button>local</button>", filepath.Join(site, "components", "ui", "btn")},
+		{"ui/card", "<div>library</div>", filepath.Join(library, "ui", "card")},
+	}
+	for _, tt := range tests {
+		path := filepath.FromSlash(tt.component)
+		file, err := d.FindTemplateFile(path)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.component, err)
+		}
+		if got, err := d.ReadComponent(path, file); err != nil || string(got) != tt.want {
+			t.Errorf("%s template = %q (error %v), want %q", tt.component, got, err, tt.want)
+		}
+		if got := d.ComponentDir(path); got != tt.dir {
+			t.Errorf("%s read from %s, want %s", tt.component, got, tt.dir)
+		}
+	}
+
+	if scripts, err := d.ListComponentFiles(filepath.Join("ui", "btn"), ".js"); err != nil || len(scripts) != 0 {
+		t.Errorf("local ui.btn picked up library scripts %v (error %v)", scripts, err)
+	}
+	components, err := d.ListComponents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"ui.btn", "ui.card"}; !slices.Equal(slices.Sorted(slices.Values(components)), want) {
+		t.Errorf("components %v, want %v", components, want)
+	}
+}
+
+func TestListBlueprintsCancelled(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"blueprints/index.blueprint": "1 page\n"})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, opts := range []DiskOptions{{}, {FollowSymlinks: true}} {
+		if _, err := NewDisk([]string{root}, "", opts).ListBlueprints(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("with %+v: got %v, want context.Canceled", opts, err)
+		}
+	}
+}
\ No newline at end of file
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FS is a Source reading a source root from any fs.FS, such as an embed.FS
+// The file system is laid out like a source root on disk, with blueprints/, components/, and globals.vars
+// at its top level; use fs.Sub to build from a subdirectory of it.
+type FS struct {
+	fsys fs.FS
+}
+
+// NewFS creates a Source reading from fsys
+func NewFS(fsys fs.FS) *FS {
+	return &FS{fsys: fsys}
+}
+
+// ListBlueprints lists the page blueprints and their output paths
+func (f *FS) ListBlueprints(ctx context.Context) (map[string]string, error) {
+	blueprints := make(map[string]string)
+
+	err := fs.WalkDir(f.fsys, "blueprints", func(name string, d fs.DirEntry, err error) error {
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err != nil || d.IsDir() || !strings.HasSuffix(name, ".blueprint") {
+			return err
+		}
+
+		// Partials are only used through @include, not built as pages
+		if strings.HasPrefix(d.Name(), "_") {
+			return nil
+		}
+
+		rel := filepath.FromSlash(strings.TrimPrefix(name, "blueprints/"))
+		blueprints[rel] = pagePath(rel)
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("scanning blueprints: %w", err)
+	}
+
+	return blueprints, nil
+}
+
+// ReadBlueprint reads a blueprint file
+func (f *FS) ReadBlueprint(name string) ([]byte, error) {
+	return fs.ReadFile(f.fsys, path.Join("blueprints", filepath.ToSlash(name)))
+}
+
+// ReadGlobals reads the site-wide globals file
+func (f *FS) ReadGlobals() ([]byte, error) {
+	return fs.ReadFile(f.fsys, "globals.vars")
+}
+
+// ReadData reads a data file
+func (f *FS) ReadData(name string) ([]byte, error) {
+	return fs.ReadFile(f.fsys, name)
+}
+
+// ReadComponent reads a component file
+func (f *FS) ReadComponent(componentPath, filename string) ([]byte, error) {
+	return fs.ReadFile(f.fsys, path.Join(f.ComponentDir(componentPath), filepath.ToSlash(filename)))
+}
+
+// ListComponentFiles lists all files in a component directory, optionally filtered by extension
+func (f *FS) ListComponentFiles(componentPath string, ext string) ([]string, error) {
+	dir := f.ComponentDir(componentPath)
+	var files []string
+
+	err := fs.WalkDir(f.fsys, dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("walking component files: %w", err)
+		}
+		if d.IsDir() || ext != "" && path.Ext(name) != ext {
+			return nil
+		}
+		files = append(files, filepath.FromSlash(strings.TrimPrefix(name, dir+"/")))
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("listing component files: %w", err)
+	}
+
+	return files, nil
+}
+
+// FindTemplateFile finds the single HTML or Markdown template file in component directory
+func (f *FS) FindTemplateFile(componentPath string) (string, error) {
+	return findTemplateFile(f, componentPath)
+}
+
+// HasComponent reports whether a component directory exists
+func (f *FS) HasComponent(componentPath string) bool {
+	info, err := fs.Stat(f.fsys, f.ComponentDir(componentPath))
+	return err == nil && info.IsDir()
+}
+
+// ComponentDir returns the slash-separated directory a component is read from
+func (f *FS) ComponentDir(componentPath string) string {
+	return path.Join("components", filepath.ToSlash(componentPath))
+}
+
+// ListComponents lists the dot-separated paths of all components, directories directly holding a template
+func (f *FS) ListComponents() ([]string, error) {
+	seen := make(map[string]bool)
+	var components []string
+
+	err := fs.WalkDir(f.fsys, "components", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if ext := path.Ext(name); ext != ".html" && ext != ".md" {
+			return nil
+		}
+
+		dir := path.Dir(name)
+		if dir == "components" {
+			return nil
+		}
+		component := strings.ReplaceAll(strings.TrimPrefix(dir, "components/"), "/", ".")
+		if !seen[component] {
+			seen[component] = true
+			components = append(components, component)
+		}
+		return nil
+	})
+
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("listing components: %w", err)
+	}
+
+	return components, nil
+}
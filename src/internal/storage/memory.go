@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Memory is a Source holding a site in memory, for tests and for embedding webfactory
+// Files are keyed by slash-separated paths laid out like a source root on disk, such as
+// "globals.vars", "blueprints/index.blueprint", and "components/ui/button/button.html".
+type Memory struct {
+	files map[string][]byte
+}
+
+// NewMemory creates a Memory source from files keyed by their path within the source root
+func NewMemory(files map[string][]byte) *Memory {
+	m := &Memory{files: make(map[string][]byte, len(files))}
+	for name, content := range files {
+		m.files[path.Clean(filepath.ToSlash(name))] = content
+	}
+	return m
+}
+
+// ListBlueprints lists the page blueprints and their output paths
+func (m *Memory) ListBlueprints(ctx context.Context) (map[string]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	blueprints := make(map[string]string)
+	for name := range m.files {
+		rel, ok := strings.CutPrefix(name, "blueprints/")
+		if !ok || !strings.HasSuffix(rel, ".blueprint") || strings.HasPrefix(path.Base(rel), "_") {
+			continue
+		}
+		rel = filepath.FromSlash(rel)
+		blueprints[rel] = pagePath(rel)
+	}
+	return blueprints, nil
+}
+
+// ReadBlueprint reads a blueprint file
+func (m *Memory) ReadBlueprint(name string) ([]byte, error) {
+	return m.read(path.Join("blueprints", filepath.ToSlash(name)))
+}
+
+// ReadGlobals reads the site-wide globals file
+func (m *Memory) ReadGlobals() ([]byte, error) {
+	return m.read("globals.vars")
+}
+
+// ReadData reads a data file
+func (m *Memory) ReadData(name string) ([]byte, error) {
+	return m.read(path.Clean(name))
+}
+
+// ReadComponent reads a component file
+func (m *Memory) ReadComponent(componentPath, filename string) ([]byte, error) {
+	return m.read(path.Join(m.ComponentDir(componentPath), filepath.ToSlash(filename)))
+}
+
+// ListComponentFiles lists all files of a component, optionally filtered by extension
+func (m *Memory) ListComponentFiles(componentPath string, ext string) ([]string, error) {
+	prefix := m.ComponentDir(componentPath) + "/"
+	var files []string
+	for name := range m.files {
+		rel, ok := strings.CutPrefix(name, prefix)
+		if !ok || ext != "" && path.Ext(rel) != ext {
+			continue
+		}
+		files = append(files, filepath.FromSlash(rel))
+	}
+	if files == nil && !m.HasComponent(componentPath) {
+		return nil, fmt.Errorf("listing component files: %w", &fs.PathError{Op: "open", Path: prefix, Err: fs.ErrNotExist})
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// FindTemplateFile finds the single HTML or Markdown template file of a component
+func (m *Memory) FindTemplateFile(componentPath string) (string, error) {
+	return findTemplateFile(m, componentPath)
+}
+
+// HasComponent reports whether any file lies in the component's directory
+func (m *Memory) HasComponent(componentPath string) bool {
+	prefix := m.ComponentDir(componentPath) + "/"
+	for name := range m.files {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ComponentDir returns the slash-separated directory a component is read from
+func (m *Memory) ComponentDir(componentPath string) string {
+	return path.Join("components", filepath.ToSlash(componentPath))
+}
+
+// ListComponents lists the dot-separated paths of all components, directories directly holding a template
+func (m *Memory) ListComponents() ([]string, error) {
+	seen := make(map[string]bool)
+	var components []string
+	for name := range m.files {
+		rel, ok := strings.CutPrefix(name, "components/")
+		if !ok {
+			continue
+		}
+		if ext := path.Ext(rel); ext != ".html" && ext != ".md" {
+			continue
+		}
+		dir := path.Dir(rel)
+		if dir == "." {
+			continue
+		}
+		component := strings.ReplaceAll(dir, "/", ".")
+		if !seen[component] {
+			seen[component] = true
+			components = append(components, component)
+		}
+	}
+	sort.Strings(components)
+	return components, nil
+}
+
+// read returns a file's content, or a not-exist error like reading a missing file from disk
+func (m *Memory) read(name string) ([]byte, error) {
+	content, ok := m.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return content, nil
+}
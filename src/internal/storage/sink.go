@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// Sink receives the files of a generated site
+// Paths are relative to the site root and use the host separator. A file may be written more than once
+// in a build, as assets shared by several pages are, and the last write wins.
+type Sink interface {
+	Write(path string, content []byte) error
+}
+
+// MemorySink is a Sink collecting the generated site in memory, for tests and for embedding webfactory
+type MemorySink struct {
+	files map[string][]byte
+}
+
+// NewMemorySink creates an empty MemorySink
+func NewMemorySink() *MemorySink {
+	return &MemorySink{files: make(map[string][]byte)}
+}
+
+// Write stores a copy of content under its slash-separated path
+func (m *MemorySink) Write(name string, content []byte) error {
+	m.files[path.Clean(filepath.ToSlash(name))] = append([]byte(nil), content...)
+	return nil
+}
+
+// Files returns the written files keyed by slash-separated path
+func (m *MemorySink) Files() map[string][]byte {
+	return m.files
+}
+
+// Paths returns the paths of the written files, sorted
+func (m *MemorySink) Paths() []string {
+	paths := make([]string, 0, len(m.files))
+	for name := range m.files {
+		paths = append(paths, name)
+	}
+	sort.Strings(paths)
+	return paths
+}
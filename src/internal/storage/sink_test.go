@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestMemorySink(t *testing.T) {
+	sink := NewMemorySink()
+	content := []byte("first")
+	for _, w := range []struct{ path, content string }{
+		{"index.html", "<p>home</p>"},
+		{filepath.Join("css", "styles.css"), "a {}"},
+		{"./docs/../about.html", "<p>about</p>"},
+		{"index.html", "<p>home again</p>"},
+	} {
+		if err := sink.Write(w.path, []byte(w.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Write("copy.txt", content); err != nil {
+		t.Fatal(err)
+	}
+	content[0] = 'F'
+
+	if got, want := sink.Paths(), []string{"about.html", "copy.txt", "css/styles.css", "index.html"}; !slices.Equal(got, want) {
+		t.Errorf("Paths() = %q, want %q", got, want)
+	}
+	files := sink.Files()
+	if got := string(files["index.html"]); got != "<p>home again</p>" {
+		t.Errorf("index.html = %q, want the last write", got)
+	}
+	if got := string(files["copy.txt"]); got != "first" {
+		t.Errorf("copy.txt = %q, changed with the caller's slice", got)
+	}
+}
+
+func TestStorageSink(t *testing.T) {
+	dir := t.TempDir()
+	var sink Sink = New(dir, Options{})
+	if err := sink.Write(filepath.Join("css", "styles.css"), []byte("a {}")); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(readFile(t, filepath.Join(dir, "css", "styles.css"))); got != "a {}" {
+		t.Errorf("css/styles.css = %q", got)
+	}
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+)
+
+// Source provides the blueprints, globals, and components a site is built from
+// Paths use the host separator and are relative to the blueprints directory or, for components,
+// to the component's directory.
+type Source interface {
+	// ListBlueprints lists the page blueprints and their output paths without extension,
+	// stopping with the context's error when it is cancelled
+	ListBlueprints(ctx context.Context) (map[string]string, error)
+	// ReadBlueprint reads a blueprint or blueprint partial
+	ReadBlueprint(path string) ([]byte, error)
+	// ReadGlobals reads the site-wide globals file, an error satisfying os.IsNotExist when there is none
+	ReadGlobals() ([]byte, error)
+	// ReadData reads a data file by its slash-separated path relative to the source root
+	ReadData(path string) ([]byte, error)
+	// ReadComponent reads a file of a component
+	ReadComponent(componentPath, filename string) ([]byte, error)
+	// ListComponentFiles lists all files of a component, optionally filtered by extension
+	ListComponentFiles(componentPath string, ext string) ([]string, error)
+	// FindTemplateFile finds the single HTML or Markdown template of a component
+	FindTemplateFile(componentPath string) (string, error)
+	// HasComponent reports whether a component exists
+	HasComponent(componentPath string) bool
+	// ComponentDir describes where a component is read from, for error messages
+	ComponentDir(componentPath string) string
+	// ListComponents lists the dot-separated paths of all components
+	ListComponents() ([]string, error)
+}
+
+// findTemplateFile finds the single HTML or Markdown template among a component's files
+func findTemplateFile(src Source, componentPath string) (string, error) {
+	files, err := src.ListComponentFiles(componentPath, ".html")
+	if err != nil {
+		return "", fmt.Errorf("listing HTML files: %w", err)
+	}
+
+	mdFiles, err := src.ListComponentFiles(componentPath, ".md")
+	if err != nil {
+		return "", fmt.Errorf("listing Markdown files: %w", err)
+	}
+	files = append(files, mdFiles...)
+
+	if len(files) == 0 {
+		return "", fmt.Errorf("no HTML or Markdown template found in component %s", componentPath)
+	}
+	if len(files) > 1 {
+		return "", fmt.Errorf("multiple templates found in component %s", componentPath)
+	}
+
+	return files[0], nil
+}
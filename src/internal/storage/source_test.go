@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"maps"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+// siteFiles is a small source root, by slash-separated path
+var siteFiles = map[string]string{
+	"globals.vars":                   "site=Demo\n",
+	"data/posts.json":                "[]",
+	"blueprints/index.blueprint":     "1 ui.card\n",
+	"blueprints/blog/post.blueprint": "@include _footer.blueprint\n",
+	"blueprints/_footer.blueprint":   "1 footer\n",
+	"components/ui/card/card.html":   "<div>{{.title}}</div>",
+	"components/ui/card/card.css":    ".card {}",
+	"components/ui/card/img/a.png":   "png",
+	"components/footer/footer.md":    "# Footer",
+}
+
+// checkSource checks that a Source serves siteFiles, components addressed by their file system path
+func checkSource(t *testing.T, src Source) {
+	t.Helper()
+
+	blueprints, err := src.ListBlueprints(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	slashed := make(map[string]string, len(blueprints))
+	for path, output := range blueprints {
+		slashed[filepath.ToSlash(path)] = filepath.ToSlash(output)
+	}
+	if want := map[string]string{"index.blueprint": "index", "blog/post.blueprint": "blog/post"}; !maps.Equal(slashed, want) {
+		t.Errorf("ListBlueprints = %v, want %v", slashed, want)
+	}
+
+	card := filepath.Join("ui", "card")
+	reads := []struct {
+		name string
+		read func() ([]byte, error)
+		want string
+	}{
+		{"blueprint", func() ([]byte, error) { return src.ReadBlueprint(filepath.Join("blog", "post.blueprint")) }, "@include _footer.blueprint\n"},
+		{"partial", func() ([]byte, error) { return src.ReadBlueprint("_footer.blueprint") }, "1 footer\n"},
+		{"globals", src.ReadGlobals, "site=Demo\n"},
+		{"data", func() ([]byte, error) { return src.ReadData("data/posts.json") }, "[]"},
+		{"component", func() ([]byte, error) { return src.ReadComponent(card, "card.css") }, ".card {}"},
+	}
+	for _, r := range reads {
+		if got, err := r.read(); err != nil || string(got) != r.want {
+			t.Errorf("reading %s = %q, %v, want %q", r.name, got, err, r.want)
+		}
+	}
+	if _, err := src.ReadData("data/missing.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("reading a missing file: got %v, want a not-exist error", err)
+	}
+
+	if files, err := src.ListComponentFiles(card, ""); err != nil || !slices.Equal(files, []string{"card.css", "card.html", filepath.Join("img", "a.png")}) {
+		t.Errorf("ListComponentFiles = %q, %v", files, err)
+	}
+	if files, err := src.ListComponentFiles(card, ".css"); err != nil || !slices.Equal(files, []string{"card.css"}) {
+		t.Errorf("ListComponentFiles .css = %q, %v", files, err)
+	}
+	if _, err := src.ListComponentFiles(filepath.Join("ui", "nope"), ""); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("listing a missing component: got %v, want a not-exist error", err)
+	}
+
+	for path, want := range map[string]string{card: "card.html", "footer": "footer.md"} {
+		if got, err := src.FindTemplateFile(path); err != nil || got != want {
+			t.Errorf("FindTemplateFile(%s) = %q, %v, want %q", path, got, err, want)
+		}
+	}
+	if !src.HasComponent(card) || src.HasComponent(filepath.Join("ui", "nope")) {
+		t.Error("HasComponent does not match the component directories")
+	}
+	if components, err := src.ListComponents(); err != nil || !slices.Equal(components, []string{"footer", "ui.card"}) {
+		t.Errorf("ListComponents = %q, %v", components, err)
+	}
+}
+
+func TestMemorySource(t *testing.T) {
+	files := make(map[string][]byte, len(siteFiles))
+	for name, content := range siteFiles {
+		files[name] = []byte(content)
+	}
+	checkSource(t, NewMemory(files))
+}
+
+func TestFSSource(t *testing.T) {
+	fsys := make(fstest.MapFS, len(siteFiles))
+	for name, content := range siteFiles {
+		fsys[name] = &fstest.MapFile{Data: []byte(content)}
+	}
+	checkSource(t, NewFS(fsys))
+}
+
+func TestDiskSource(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, siteFiles)
+	checkSource(t, NewDisk([]string{root}, "", DiskOptions{}))
+}
+
+func TestMemorySourceCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewMemory(nil).ListBlueprints(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
\ No newline at end of file
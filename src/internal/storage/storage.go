@@ -1,138 +1,245 @@
 package storage
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
-	"strings"
+	"sort"
+	"syscall"
 )
 
-// Storage handles all file system operations for the application
+// Default permissions of written output
+const (
+	DefaultFileMode os.FileMode = 0644
+	DefaultDirMode  os.FileMode = 0755
+)
+
+// Options controls how output files are written
+type Options struct {
+	Gzip        bool        // Also write a .gz copy of text outputs
+	Brotli      bool        // Also write a .br copy of text outputs
+	CompressMin int         // Skip pre-compressing files smaller than this many bytes
+	FileMode    os.FileMode // Permissions of output files, DefaultFileMode when zero
+	DirMode     os.FileMode // Permissions of created output directories, DefaultDirMode when zero
+	DryRun      bool        // Record the files that would be written instead of touching disk
+}
+
+// Validate rejects output modes that are not plain permissions or would lock the owner out
+func (o Options) Validate() error {
+	if o.FileMode&^os.ModePerm != 0 || o.FileMode != 0 && o.FileMode&0600 != 0600 {
+		return fmt.Errorf("file mode %04o must be a permission mode readable and writable by the owner", uint32(o.FileMode))
+	}
+	if o.DirMode&^os.ModePerm != 0 || o.DirMode != 0 && o.DirMode&0700 != 0700 {
+		return fmt.Errorf("directory mode %04o must be a permission mode fully accessible to the owner", uint32(o.DirMode))
+	}
+	return nil
+}
+
+// Storage is the Sink writing the generated site to a target directory on disk
 type Storage struct {
-	sourcePath string
 	targetPath string
+	opts       Options
+	planned    map[string]PlannedWrite // dry-run writes by path
+	skipped    int                     // writes left out since the file already held the content
+	written    map[string]bool         // paths written, planned, or left unchanged since the last Reset
 }
 
-// New creates a Storage instance with the given root path
-func New(sourcePath, targetPath string) *Storage {
+// PlannedWrite is a file a dry run would have written
+type PlannedWrite struct {
+	Path      string
+	Bytes     int
+	Overwrite bool // the file already exists on disk
+}
+
+// New creates a Storage instance writing to targetPath
+func New(targetPath string, opts Options) *Storage {
+	if opts.FileMode == 0 {
+		opts.FileMode = DefaultFileMode
+	}
+	if opts.DirMode == 0 {
+		opts.DirMode = DefaultDirMode
+	}
+
 	return &Storage{
-		sourcePath: sourcePath,
 		targetPath: targetPath,
+		opts:       opts,
+		planned:    make(map[string]PlannedWrite),
+		written:    make(map[string]bool),
 	}
 }
 
-// ListBlueprints reads a blueprint file from disk
-func (s *Storage) ListBlueprints() (map[string]string, error) {
-	blueprints := make(map[string]string)
-	blueprintsDir := filepath.Join(s.sourcePath, "blueprints")
+// Write writes a generated file to its path relative to the target directory, with any compressed copies
+func (s *Storage) Write(path string, content []byte) error {
+	fullPath := filepath.Join(s.targetPath, path)
 
-	err := filepath.Walk(blueprintsDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".blueprint") {
-			return err
-		}
-
-		rel, err := filepath.Rel(blueprintsDir, path)
-		if err != nil {
+	// Ensure directory exists
+	if !s.opts.DryRun {
+		if err := s.mkdirAll(filepath.Dir(fullPath)); err != nil {
 			return err
 		}
+	}
 
-		// Get the parent directory as prefix
-		dir := filepath.Dir(path)
-		dirRel, err := filepath.Rel(s.sourcePath, dir)
-		if err != nil {
-			return err
-		}
-		prefix := strings.Split(dirRel, string(filepath.Separator))[0]
+	if err := s.writeFile(fullPath, content); err != nil {
+		return err
+	}
 
-		outputPath := filepath.Base(path[:len(path)-len(".blueprint")])
-		outputPath = filepath.Join(prefix, outputPath)
+	return s.writeCompressed(fullPath, content)
+}
 
-		blueprints[rel] = outputPath
+// writeFile writes content with the configured file mode
+// The mode is applied explicitly since the umask and existing files would otherwise keep other permissions.
+// The content goes to a temporary file next to path that is then renamed over it, so a killed build
+// never leaves a truncated file to be served. In a dry run the write is only recorded.
+func (s *Storage) writeFile(path string, content []byte) error {
+	s.written[path] = true
+	if s.opts.DryRun {
+		// Assets shared by several pages are planned once per page, the first decides whether it exists
+		w, planned := s.planned[path]
+		if !planned {
+			_, err := os.Stat(path)
+			w = PlannedWrite{Path: path, Overwrite: err == nil}
+		}
+		w.Bytes = len(content)
+		s.planned[path] = w
 		return nil
-	})
-
-	if err != nil {
-		return nil, fmt.Errorf("scanning blueprints: %w", err)
 	}
 
-	return blueprints, nil
-}
+	// An unchanged file keeps its modification time, so incremental syncs leave it alone
+	if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() && info.Size() == int64(len(content)) {
+		if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, content) {
+			s.skipped++
+			if info.Mode().Perm() == s.opts.FileMode {
+				return nil
+			}
+			return os.Chmod(path, s.opts.FileMode)
+		}
+	}
 
-// ReadBlueprint reads a blueprint file from disk
-func (s *Storage) ReadBlueprint(path string) ([]byte, error) {
-	return os.ReadFile(filepath.Join(s.sourcePath, "blueprints", path))
+	return s.replaceFile(path, content)
 }
 
-// ReadComponent reads a component file (template, css, js) from disk
-func (s *Storage) ReadComponent(componentPath, filename string) ([]byte, error) {
-	fullPath := filepath.Join(s.sourcePath, "components", componentPath, filename)
-	return os.ReadFile(fullPath)
-}
+// replaceFile atomically replaces path with content through a temporary file in the same directory
+// The temporary file is removed on any error, leaving an existing file at path untouched. Should the
+// rename still cross devices, such as onto a bind-mounted file, the content is written in place instead.
+func (s *Storage) replaceFile(path string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
 
-// ListComponentFiles lists all files in a component directory, optionally filtered by extension
-func (s *Storage) ListComponentFiles(componentPath string, ext string) ([]string, error) {
-	dir := filepath.Join(s.sourcePath, "components", componentPath)
-	var files []string
+	_, err = tmp.Write(content)
+	if err == nil {
+		err = tmp.Chmod(s.opts.FileMode)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err == nil {
+		return nil
+	}
+	os.Remove(tmpPath)
 
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return fmt.Errorf("walking component files: %w", err)
+	if errors.Is(err, syscall.EXDEV) {
+		if err := os.WriteFile(path, content, s.opts.FileMode); err != nil {
+			return err
 		}
+		return os.Chmod(path, s.opts.FileMode)
+	}
+	return err
+}
+
+// mkdirAll creates dir and any missing parents with the configured directory mode
+// Directories that already exist keep their permissions.
+func (s *Storage) mkdirAll(dir string) error {
+	if info, err := os.Stat(dir); err == nil {
 		if !info.IsDir() {
-			if ext != "" && filepath.Ext(path) != ext {
-				return nil
-			}
-			rel, err := filepath.Rel(dir, path)
-			if err != nil {
-				return err
-			}
-			files = append(files, rel)
+			return fmt.Errorf("%s exists and is not a directory", dir)
 		}
 		return nil
-	})
-
-	if err != nil {
-		return nil, fmt.Errorf("listing component files: %w", err)
 	}
 
-	return files, nil
-}
-
-// FindTemplateFile finds the single HTML template file in component directory
-func (s *Storage) FindTemplateFile(componentPath string) (string, error) {
-	files, err := s.ListComponentFiles(componentPath, ".html")
-	if err != nil {
-		return "", fmt.Errorf("listing HTML files: %w", err)
+	if parent := filepath.Dir(dir); parent != dir {
+		if err := s.mkdirAll(parent); err != nil {
+			return err
+		}
 	}
 
-	if len(files) == 0 {
-		return "", fmt.Errorf("no HTML template found in component %s", componentPath)
-	}
-	if len(files) > 1 {
-		return "", fmt.Errorf("multiple HTML templates found in component %s", componentPath)
+	if err := os.Mkdir(dir, s.opts.DirMode); err != nil {
+		if os.IsExist(err) {
+			return nil
+		}
+		return err
 	}
+	return os.Chmod(dir, s.opts.DirMode)
+}
 
-	return files[0], nil
+// Planned returns the writes recorded in dry-run mode, sorted by path
+func (s *Storage) Planned() []PlannedWrite {
+	writes := make([]PlannedWrite, 0, len(s.planned))
+	for _, w := range s.planned {
+		writes = append(writes, w)
+	}
+	sort.Slice(writes, func(i, j int) bool { return writes[i].Path < writes[j].Path })
+	return writes
 }
 
-// GetTargetPath returns the absolute path to target directory
-func (s *Storage) GetTargetPath() string {
-	return s.targetPath
+// Skipped returns the number of files, compressed copies included, not rewritten since the last Reset
+// because they already held the same content
+func (s *Storage) Skipped() int {
+	return s.skipped
 }
 
-// WriteOutput writes the generated site files
-func (s *Storage) WriteOutput(outputPath string, files map[string][]byte) error {
-	for path, content := range files {
-		fullPath := filepath.Join(outputPath, path)
+// Reset clears the recorded dry-run writes, the skipped count, and the written paths before a new build
+func (s *Storage) Reset() {
+	s.planned = make(map[string]PlannedWrite)
+	s.skipped = 0
+	s.written = make(map[string]bool)
+}
 
-		// Ensure directory exists
-		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+// Clean removes the files below the target directory that were not written since the last Reset, then the
+// directories left empty, returning the removed files sorted by path
+// In a dry run nothing is removed, and the files that would be are returned.
+func (s *Storage) Clean() ([]string, error) {
+	var stale, dirs []string
+	err := filepath.WalkDir(s.targetPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == s.targetPath && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
 			return err
 		}
+		if d.IsDir() {
+			if path != s.targetPath {
+				dirs = append(dirs, path)
+			}
+		} else if !s.written[path] {
+			stale = append(stale, path)
+		}
+		return nil
+	})
+	if err != nil || s.opts.DryRun {
+		return stale, err
+	}
 
-		if err := os.WriteFile(fullPath, content, 0644); err != nil {
-			return err
+	for _, path := range stale {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
 		}
 	}
-	return nil
+	// Directories come before their contents, so the deepest are tried first
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if entries, err := os.ReadDir(dirs[i]); err == nil && len(entries) == 0 {
+			if err := os.Remove(dirs[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return stale, nil
 }
\ No newline at end of file
@@ -0,0 +1,352 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+// readFile returns the content of a file, failing the test when it can't be read
+func readFile(t *testing.T, path string) []byte {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func TestCompressedCopies(t *testing.T) {
+	dir := t.TempDir()
+	s := New(dir, Options{Gzip: true, Brotli: true, CompressMin: 64})
+	page := []byte("<html><body>" + strings.Repeat("<p>compressible text</p>\n", 50) + "</body></html>")
+	if err := s.Write("index.html", page); err != nil {
+		t.Fatal(err)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(readFile(t, filepath.Join(dir, "index.html.gz"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := io.ReadAll(gz); err != nil || !bytes.Equal(got, page) {
+		t.Errorf("index.html.gz decompresses to %d bytes (error %v), want the %d original bytes", len(got), err, len(page))
+	}
+
+	br := brotli.NewReader(bytes.NewReader(readFile(t, filepath.Join(dir, "index.html.br"))))
+	if got, err := io.ReadAll(br); err != nil || !bytes.Equal(got, page) {
+		t.Errorf("index.html.br decompresses to %d bytes (error %v), want the %d original bytes", len(got), err, len(page))
+	}
+}
+
+func TestCompressedCopiesSkipped(t *testing.T) {
+	dir := t.TempDir()
+	s := New(dir, Options{Gzip: true, CompressMin: 64})
+	files := map[string][]byte{
+		"small.html": []byte("<p>hi</p>"),              // under CompressMin
+		"logo.png":   bytes.Repeat([]byte("png"), 100), // not a text type
+	}
+	for name, content := range files {
+		if err := s.Write(name, content); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, name+".gz")); !os.IsNotExist(err) {
+			t.Errorf("%s.gz written", name)
+		}
+	}
+}
+
+func TestCompressedCopyRemovedWhenStale(t *testing.T) {
+	dir := t.TempDir()
+	s := New(dir, Options{Gzip: true})
+	if err := s.Write("a.css", bytes.Repeat([]byte("a { color: red; }\n"), 20)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write("a.css", []byte("a{}")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.css.gz")); !os.IsNotExist(err) {
+		t.Error("a.css.gz of the earlier content kept although compressing the new one doesn't help")
+	}
+}
+
+// mode returns the permissions of a file or directory
+func mode(t *testing.T, path string) os.FileMode {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Mode().Perm()
+}
+
+func TestModes(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     Options
+		fileMode os.FileMode
+		dirMode  os.FileMode
+	}{
+		{"defaults", Options{}, DefaultFileMode, DefaultDirMode},
+		{"configured", Options{FileMode: 0640, DirMode: 0750}, 0640, 0750},
+		{"wider than the umask", Options{FileMode: 0666, DirMode: 0777}, 0666, 0777},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := New(dir, tt.opts).Write(filepath.Join("docs", "guide", "index.html"), []byte("<p>hi</p>")); err != nil {
+				t.Fatal(err)
+			}
+			if got := mode(t, filepath.Join(dir, "docs", "guide", "index.html")); got != tt.fileMode {
+				t.Errorf("file mode %04o, want %04o", got, tt.fileMode)
+			}
+			for _, sub := range []string{"docs", filepath.Join("docs", "guide")} {
+				if got := mode(t, filepath.Join(dir, sub)); got != tt.dirMode {
+					t.Errorf("directory %s mode %04o, want %04o", sub, got, tt.dirMode)
+				}
+			}
+		})
+	}
+}
+
+func TestModeOfExistingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.html")
+	if err := os.WriteFile(path, []byte("same"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := New(dir, Options{FileMode: 0644}).Write("a.html", []byte("same")); err != nil {
+		t.Fatal(err)
+	}
+	if got := mode(t, path); got != 0644 {
+		t.Errorf("unchanged file kept mode %04o, want 0644", got)
+	}
+}
+
+func TestValidateModes(t *testing.T) {
+	for _, opts := range []Options{{FileMode: 0444}, {DirMode: 0644}, {FileMode: os.ModeDir | 0644}} {
+		if err := opts.Validate(); err == nil {
+			t.Errorf("Validate accepted file mode %v, directory mode %v", opts.FileMode, opts.DirMode)
+		}
+	}
+	if err := (Options{FileMode: 0600, DirMode: 0700}).Validate(); err != nil {
+		t.Errorf("Validate rejected owner-only modes: %v", err)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"index.html": "old"})
+
+	s := New(dir, Options{DryRun: true, Gzip: true})
+	page := strings.Repeat("<p>compressible text</p>\n", 50)
+	for name, content := range map[string]string{"index.html": page, "blog/post.html": "<p>new</p>"} {
+		if err := s.Write(filepath.FromSlash(name), []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var written []string
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && path != dir {
+			written = append(written, path)
+		}
+		return err
+	})
+	if len(written) != 1 || string(readFile(t, filepath.Join(dir, "index.html"))) != "old" {
+		t.Errorf("dry run touched the target, it holds %v", written)
+	}
+
+	planned := s.Planned()
+	if len(planned) != 3 {
+		t.Fatalf("planned %v, want the two pages and a gzip copy", planned)
+	}
+	want := []PlannedWrite{
+		{Path: filepath.Join(dir, "blog", "post.html"), Bytes: len("<p>new</p>")},
+		{Path: filepath.Join(dir, "index.html"), Bytes: len(page), Overwrite: true},
+	}
+	for i, w := range want {
+		if planned[i] != w {
+			t.Errorf("planned write %d = %+v, want %+v", i, planned[i], w)
+		}
+	}
+	if gz := planned[2]; gz.Path != filepath.Join(dir, "index.html.gz") || gz.Overwrite || gz.Bytes >= len(page) {
+		t.Errorf("planned gzip copy %+v", gz)
+	}
+}
+
+// modTime returns the modification time of a file, failing the test when it can't be read
+func modTime(t *testing.T, path string) time.Time {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.ModTime()
+}
+
+func TestUnchangedFileKeepsModTime(t *testing.T) {
+	dir := t.TempDir()
+	s := New(dir, Options{})
+	for name, content := range map[string]string{"same.html": "<p>same</p>", "changed.html": "<p>old</p>"} {
+		if err := s.Write(name, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Date the first build back, so a rewrite shows whatever the file system's time resolution
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for _, name := range []string{"same.html", "changed.html"} {
+		if err := os.Chtimes(filepath.Join(dir, name), past, past); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s.Reset()
+	if err := s.Write("same.html", []byte("<p>same</p>")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write("changed.html", []byte("<p>new</p>")); err != nil {
+		t.Fatal(err)
+	}
+
+	if modified := modTime(t, filepath.Join(dir, "same.html")); !modified.Equal(past) {
+		t.Errorf("unchanged file rewritten: modified %v, want %v", modified, past)
+	}
+	if modTime(t, filepath.Join(dir, "changed.html")).Equal(past) {
+		t.Error("changed file kept its old modification time")
+	}
+	if got := string(readFile(t, filepath.Join(dir, "changed.html"))); got != "<p>new</p>" {
+		t.Errorf("changed.html = %q, want the new content", got)
+	}
+	if s.Skipped() != 1 {
+		t.Errorf("skipped %d files, want 1", s.Skipped())
+	}
+}
+
+func TestUnchangedFileGetsMode(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "index.html")
+	if err := os.WriteFile(path, []byte("<p>x</p>"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s := New(dir, Options{FileMode: 0o644})
+	if err := s.Write("index.html", []byte("<p>x</p>")); err != nil {
+		t.Fatal(err)
+	}
+	if got := mode(t, path); got != 0o644 || s.Skipped() != 1 {
+		t.Errorf("unchanged file has mode %v after %d skips, want 0644 without a rewrite", got, s.Skipped())
+	}
+}
+
+// tempFiles lists the leftover temporary files of writes in dir
+func tempFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, ".*.tmp-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return matches
+}
+
+func TestFailedWriteOverDirectory(t *testing.T) {
+	dir := t.TempDir()
+	// A directory where the file goes makes the final rename fail, after the content is written
+	writeTree(t, dir, map[string]string{"index.html/keep.txt": "kept"})
+
+	if err := New(dir, Options{}).Write("index.html", []byte("<p>new</p>")); err == nil {
+		t.Fatal("write over a directory succeeded")
+	}
+	if got := string(readFile(t, filepath.Join(dir, "index.html", "keep.txt"))); got != "kept" {
+		t.Errorf("directory content changed to %q", got)
+	}
+	if leftover := tempFiles(t, dir); len(leftover) > 0 {
+		t.Errorf("temporary files left behind: %q", leftover)
+	}
+}
+
+func TestFailedWriteKeepsFile(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("a read-only directory does not stop root from writing")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "index.html")
+	if err := os.WriteFile(path, []byte("<p>old</p>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(dir, 0o555); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(dir, 0o755) })
+
+	if err := New(dir, Options{}).Write("index.html", []byte("<p>new</p>")); err == nil {
+		t.Fatal("write into a read-only directory succeeded")
+	}
+	if got := string(readFile(t, path)); got != "<p>old</p>" {
+		t.Errorf("index.html = %q after a failed write, want the original", got)
+	}
+}
+
+func TestReplaceLeavesNoTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	s := New(dir, Options{})
+	for _, content := range []string{"<p>one</p>", "<p>two</p>"} {
+		if err := s.Write("index.html", []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		if got := string(readFile(t, filepath.Join(dir, "index.html"))); got != content {
+			t.Errorf("index.html = %q, want %q", got, content)
+		}
+	}
+	if leftover := tempFiles(t, dir); len(leftover) > 0 {
+		t.Errorf("temporary files left behind: %q", leftover)
+	}
+}
+
+func TestClean(t *testing.T) {
+	for _, dryRun := range []bool{false, true} {
+		dir := t.TempDir()
+		writeTree(t, dir, map[string]string{
+			"index.html":         "<p>same</p>",
+			"old.html":           "<p>deleted blueprint</p>",
+			"blog/gone.html":     "<p>deleted blueprint</p>",
+			"css/styles.css":     "p { margin: 0; }",
+			"css/styles.css.map": "{}",
+		})
+
+		s := New(dir, Options{DryRun: dryRun})
+		for name, content := range map[string]string{"index.html": "<p>same</p>", "css/styles.css": "p { margin: 1px; }"} {
+			if err := s.Write(filepath.FromSlash(name), []byte(content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		removed, err := s.Clean()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := []string{filepath.Join(dir, "blog", "gone.html"), filepath.Join(dir, "css", "styles.css.map"), filepath.Join(dir, "old.html")}
+		if !slices.Equal(removed, want) {
+			t.Errorf("dry run %v: removed %q, want %q", dryRun, removed, want)
+		}
+		for _, path := range append(want, filepath.Join(dir, "blog")) {
+			if _, err := os.Stat(path); os.IsNotExist(err) != !dryRun {
+				t.Errorf("dry run %v: %s exists: %v", dryRun, path, err == nil)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(dir, "index.html")); err != nil {
+			t.Errorf("dry run %v: unchanged file removed: %v", dryRun, err)
+		}
+	}
+
+	if removed, err := New(filepath.Join(t.TempDir(), "missing"), Options{}).Clean(); err != nil || len(removed) > 0 {
+		t.Errorf("cleaning a missing target removed %q (error %v)", removed, err)
+	}
+}
\ No newline at end of file
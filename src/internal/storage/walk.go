@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// walk walks the tree at path like filepath.Walk, following symbolic links with FollowSymlinks
+func (d *Disk) walk(path string, fn filepath.WalkFunc) error {
+	if !d.opts.FollowSymlinks {
+		return filepath.Walk(path, fn)
+	}
+
+	root := d.rootOf(path)
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return fn(path, nil, err)
+	}
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return fn(path, nil, err)
+	}
+	if !within(realRoot, real) {
+		return fn(path, nil, fmt.Errorf("%s links outside %s", path, root))
+	}
+
+	err = followWalk(path, real, realRoot, root, make(map[string]bool), fn)
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+// followWalk calls fn for path, whose target is real, and the tree below it, resolving symbolic links
+// A link whose target is outside realRoot, or a directory that is one of its own ancestors, is an error;
+// a link whose target is missing is skipped. Open holds the resolved directories being walked.
+func followWalk(path, real, realRoot, root string, open map[string]bool, fn filepath.WalkFunc) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fn(path, nil, err)
+	}
+	if !info.IsDir() {
+		return fn(path, info, nil)
+	}
+	if open[real] {
+		return fn(path, info, fmt.Errorf("symlink loop at %s, it leads back to %s", path, real))
+	}
+	open[real] = true
+	defer delete(open, real)
+
+	if err := fn(path, info, nil); err == filepath.SkipDir {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return fn(path, info, err)
+	}
+
+	for _, entry := range entries {
+		child := filepath.Join(path, entry.Name())
+		childReal := filepath.Join(real, entry.Name())
+		if entry.Type()&fs.ModeSymlink != 0 {
+			if childReal, err = filepath.EvalSymlinks(child); err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					continue
+				}
+				return fn(child, nil, err)
+			}
+			if !within(realRoot, childReal) {
+				return fn(child, nil, fmt.Errorf("%s links outside %s", child, root))
+			}
+		}
+
+		if err := followWalk(child, childReal, realRoot, root, open, fn); err == filepath.SkipDir {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rootOf returns the source root or library that path is in, which links may not lead out of
+func (d *Disk) rootOf(path string) string {
+	for _, root := range slices.Concat(d.sources, []string{d.library}) {
+		if root != "" && within(root, path) {
+			return root
+		}
+	}
+	return path
+}
+
+// within reports whether path is dir or below it
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
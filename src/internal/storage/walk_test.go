@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// symlink creates a symbolic link at the slash-separated path below root, pointing at target
+func symlink(t *testing.T, root, path, target string) {
+	t.Helper()
+	if err := os.Symlink(filepath.FromSlash(target), filepath.Join(root, filepath.FromSlash(path))); err != nil {
+		t.Skipf("cannot create symbolic links: %v", err)
+	}
+}
+
+func TestFollowSymlinks(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"blueprints/index.blueprint": "1 ui.card\n",
+		"pages/about.blueprint":      "1 ui.card\n",
+		"vendor/card/card.html":      "<div></div>",
+		"vendor/card/card.css":       ".card { margin: 0; }",
+		"vendor/card/img/logo.png":   "png",
+		"components/ui/.keep":        "",
+	})
+	symlink(t, root, "components/ui/card", "../../vendor/card")
+	symlink(t, root, "blueprints/more", "../pages")
+	symlink(t, root, "blueprints/gone", "../missing")
+	d := NewDisk([]string{root}, "", DiskOptions{FollowSymlinks: true})
+
+	files, err := d.ListComponentFiles(filepath.Join("ui", "card"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"card.css", "card.html", filepath.Join("img", "logo.png")}; !slices.Equal(files, want) {
+		t.Errorf("linked component files %q, want %q", files, want)
+	}
+	if got, err := d.ReadComponent(filepath.Join("ui", "card"), "card.html"); err != nil || string(got) != "<div></div>" {
+		t.Errorf("linked template = %q (error %v)", got, err)
+	}
+
+	// The dangling link is skipped
+	blueprints := listBlueprints(t, d)
+	if want := map[string]string{"index.blueprint": "index", "more/about.blueprint": "more/about"}; !maps.Equal(blueprints, want) {
+		t.Errorf("blueprints %v, want %v", blueprints, want)
+	}
+
+	// Without following, the linked directory is skipped
+	blueprints = listBlueprints(t, NewDisk([]string{root}, "", DiskOptions{}))
+	if want := map[string]string{"index.blueprint": "index"}; !maps.Equal(blueprints, want) {
+		t.Errorf("without following links, blueprints %v, want %v", blueprints, want)
+	}
+}
+
+func TestSymlinkLoop(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"blueprints/docs/index.blueprint": "1 page\n"})
+	symlink(t, root, "blueprints/docs/again", "..")
+
+	_, err := NewDisk([]string{root}, "", DiskOptions{FollowSymlinks: true}).ListBlueprints(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "symlink loop at "+filepath.Join(root, "blueprints", "docs", "again")) {
+		t.Errorf("got error %v, want the loop reported", err)
+	}
+}
+
+func TestSymlinkOutsideRoot(t *testing.T) {
+	root, outside := t.TempDir(), t.TempDir()
+	writeTree(t, root, map[string]string{"blueprints/index.blueprint": "1 page\n"})
+	writeTree(t, outside, map[string]string{"secret.blueprint": "1 page\n"})
+	symlink(t, root, "blueprints/escape", outside)
+
+	_, err := NewDisk([]string{root}, "", DiskOptions{FollowSymlinks: true}).ListBlueprints(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "links outside "+root) {
+		t.Errorf("got error %v, want the link out of the source root refused", err)
+	}
+}
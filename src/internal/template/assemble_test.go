@@ -0,0 +1,148 @@
+package template
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestAssemble(t *testing.T) {
+	components := map[string]string{
+		"page/page.html": "<head>{{styles}}</head><body>{{component}}{{script}}</body>",
+		"bare/bare.html": "<main>{{component}}</main>",
+		"card/card.html": "<div>{{.title}}</div>",
+		"card/card.css":  ".card { margin: 0; }",
+		"card/card.js":   "console.log(1)",
+	}
+	tests := []struct {
+		name      string
+		blueprint string
+		want      string
+	}{
+		{
+			name:      "markers",
+			blueprint: "1 page\n1.1 card\n  .title=A\n",
+			want:      `<head><link rel="stylesheet" href="css/styles.css"></head><body><div>A</div><script src="js/card-card.js"></script></body>`,
+		},
+		{
+			name:      "no markers",
+			blueprint: "1 bare\n1.1 card\n  .title=A\n",
+			want:      `<link rel="stylesheet" href="css/styles.css"><main><div>A</div></main><script src="js/card-card.js"></script>`,
+		},
+		{
+			name:      "marker-like text",
+			blueprint: "1 bare\n1.1 card\n  .title=<!--wf:other--> <!--wf:script:x-->\n",
+			want:      `<link rel="stylesheet" href="css/styles.css"><main><div><!--wf:other--> <!--wf:script:x--></div></main><script src="js/card-card.js"></script>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mustRender(t, components, tt.blueprint); got != tt.want {
+				t.Errorf("got  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func BenchmarkAssembleLargePage(b *testing.B) {
+	var blueprint strings.Builder
+	blueprint.WriteString("1 page\n")
+	for i := range 2000 {
+		fmt.Fprintf(&blueprint, "1.%d card\n  .title=Item %d\n", i+1, i)
+	}
+	p, tree := newProcessor(b, map[string]string{
+		"page/page.html": "<head>{{styles}}</head><body>{{component}}{{script}}</body>",
+		"card/card.html": "<div class=\"card\">{{.title}}<p>" + strings.Repeat("Lorem ipsum dolor sit amet. ", 10) + "</p></div>",
+		"card/card.css":  ".card { margin: 0; }",
+		"card/card.js":   "console.log(1)",
+	}, blueprint.String(), Options{})
+	registry := p.registry
+	tokens := NewTokens()
+
+	b.ResetTimer()
+	for range b.N {
+		result, err := New(registry, Options{Tokens: tokens}).Assembler(tree)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.SetBytes(int64(len(result.HTML)))
+	}
+}
+
+func TestScriptPlaceholders(t *testing.T) {
+	components := map[string]string{
+		"a/a.html": "<a></a>",
+		"a/a.js":   "console.log('a')",
+		"b/b.html": "<b></b>",
+		"b/b.js":   "console.log('b')",
+	}
+	tags := map[string]string{"a": `<script src="js/a-a.js"></script>`, "b": `<script src="js/b-b.js"></script>`}
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"after each component", "{{component 0}}{{script}}|{{component 1}}{{script}}", "<a></a>" + tags["a"] + "|<b></b>" + tags["b"]},
+		{"both before", "{{script}}|{{script}}|{{component}}", "|" + tags["a"] + "\n" + tags["b"] + "|<a></a><b></b>"},
+		{"first after all", "{{component}}{{script}}|{{script}}", "<a></a><b></b>" + tags["a"] + "\n" + tags["b"] + "|"},
+		{"last before the second component", "{{component 0}}{{script}}|{{script}}{{component 1}}", "<a></a>" + tags["a"] + "|" + tags["b"] + "<b></b>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			components["page/page.html"] = tt.template
+			if got := mustRender(t, components, "1 page\n1.1 a\n1.2 b\n"); got != tt.want {
+				t.Errorf("got  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindMarkers(t *testing.T) {
+	html := "a" + stylesMarker + "b<!--wf:nope-->c" + fmt.Sprintf(scriptMarkerF, 2) + "<!--wf:"
+	markers := findMarkers([]byte(html))
+	if len(markers) != 2 {
+		t.Fatalf("got %d markers, want the styles and the script marker: %+v", len(markers), markers)
+	}
+	if m := markers[0]; m.script || html[m.start:m.end] != stylesMarker {
+		t.Errorf("first marker %+v, want the styles marker", m)
+	}
+	if m := markers[1]; !m.script || m.mark != 2 || html[m.end:] != "<!--wf:" {
+		t.Errorf("second marker %+v, want the script marker with mark 2", m)
+	}
+}
+
+func TestStylesPlaceholders(t *testing.T) {
+	link := `<link rel="stylesheet" href="css/styles.css">`
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"zero", "<head></head><p>x</p>", link + "<head></head><p>x</p>"},
+		{"one", "<head>{{styles}}</head><p>x</p>", "<head>" + link + "</head><p>x</p>"},
+		{"two", "<head>{{styles}}</head><p>{{styles}}x</p>", "<head>" + link + "</head><p>x</p>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html := mustRender(t, map[string]string{"page/page.html": tt.template, "page/page.css": "p { margin: 0; }"}, "1 page\n")
+			if html != tt.want {
+				t.Errorf("got  %q\nwant %q", html, tt.want)
+			}
+		})
+	}
+}
+
+func TestStylesPlaceholderInChild(t *testing.T) {
+	// The first {{styles}} rendered wins, even in a component nested below the one with a later {{styles}}
+	html := mustRender(t, map[string]string{
+		"page/page.html": "{{component}}<footer>{{styles}}</footer>",
+		"head/head.html": "<head>{{styles}}</head>",
+		"head/head.css":  "head { display: none; }",
+	}, "1 page\n1.1 head\n")
+	if want := `<head><link rel="stylesheet" href="css/styles.css"></head><footer></footer>`; html != want {
+		t.Errorf("got  %q\nwant %q", html, want)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,131 @@
+package template
+
+import (
+	"bytes"
+	"html"
+)
+
+// addRootClass adds a class to the first element of rendered HTML, merging with an existing class attribute
+// It reports false when the HTML has no element to add the class to.
+func addRootClass(content []byte, class string) ([]byte, bool) {
+	return setRootAttr(content, "class", class, true)
+}
+
+// setRootID sets the id of the first element of rendered HTML, replacing an existing id
+// It reports false when the HTML has no element to set the id of.
+func setRootID(content []byte, id string) ([]byte, bool) {
+	return setRootAttr(content, "id", id, false)
+}
+
+// setRootAttr sets an attribute of the first element of rendered HTML
+// With merge, the value is appended to an existing value after a space instead of replacing it.
+func setRootAttr(content []byte, name, value string, merge bool) ([]byte, bool) {
+	start, end, ok := findRootTag(content)
+	if !ok {
+		return content, false
+	}
+	tag := content[start:end]
+	value = html.EscapeString(value)
+
+	var rewritten []byte
+	if valStart, valEnd, found := findAttr(tag, name); found {
+		rewritten = make([]byte, 0, len(tag)+len(value)+1)
+		if merge {
+			rewritten = append(rewritten, tag[:valEnd]...)
+			if valEnd > valStart {
+				rewritten = append(rewritten, ' ')
+			}
+		} else {
+			rewritten = append(rewritten, tag[:valStart]...)
+		}
+		rewritten = append(rewritten, value...)
+		rewritten = append(rewritten, tag[valEnd:]...)
+	} else {
+		// Insert before the closing > or />
+		insert := len(tag) - 1
+		if insert > 0 && tag[insert-1] == '/' {
+			insert--
+		}
+		rewritten = make([]byte, 0, len(tag)+len(name)+len(value)+4)
+		rewritten = append(rewritten, tag[:insert]...)
+		rewritten = append(rewritten, ` `+name+`="`+value+`"`...)
+		rewritten = append(rewritten, tag[insert:]...)
+	}
+
+	out := make([]byte, 0, len(content)+len(rewritten)-len(tag))
+	out = append(out, content[:start]...)
+	out = append(out, rewritten...)
+	return append(out, content[end:]...), true
+}
+
+// findRootTag locates the first start tag, skipping comments, doctypes and closing tags
+func findRootTag(content []byte) (int, int, bool) {
+	for i := 0; i < len(content); i++ {
+		if content[i] != '<' || i+1 >= len(content) {
+			continue
+		}
+
+		next := content[i+1]
+		if bytes.HasPrefix(content[i:], []byte("<!--")) {
+			end := bytes.Index(content[i:], []byte("-->"))
+			if end == -1 {
+				return 0, 0, false
+			}
+			i += end + 2
+			continue
+		}
+		if !(next >= 'a' && next <= 'z' || next >= 'A' && next <= 'Z') {
+			continue
+		}
+
+		// Find the end of the tag, ignoring > inside quoted attribute values
+		var quote byte
+		for j := i + 1; j < len(content); j++ {
+			switch c := content[j]; {
+			case quote != 0:
+				if c == quote {
+					quote = 0
+				}
+			case c == '"' || c == '\'':
+				quote = c
+			case c == '>':
+				return i, j + 1, true
+			}
+		}
+		return 0, 0, false
+	}
+	return 0, 0, false
+}
+
+// findAttr locates the value of a quoted attribute in a start tag
+func findAttr(tag []byte, name string) (int, int, bool) {
+	lower := bytes.ToLower(tag)
+	needle := []byte(name + "=")
+
+	for from := 0; ; {
+		i := bytes.Index(lower[from:], needle)
+		if i == -1 {
+			return 0, 0, false
+		}
+		i += from
+		from = i + len(needle)
+
+		// Must be a whole attribute name
+		if i == 0 || !isAttrSpace(tag[i-1]) {
+			continue
+		}
+		valStart := i + len(needle)
+		if valStart >= len(tag) || (tag[valStart] != '"' && tag[valStart] != '\'') {
+			continue
+		}
+		end := bytes.IndexByte(tag[valStart+1:], tag[valStart])
+		if end == -1 {
+			return 0, 0, false
+		}
+		return valStart + 1, valStart + 1 + end, true
+	}
+}
+
+func isAttrSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
@@ -0,0 +1,67 @@
+package template
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSetRootAttr(t *testing.T) {
+	tests := []struct {
+		name    string
+		html    string
+		attr    string
+		value   string
+		merge   bool
+		want    string
+		wantErr bool
+	}{
+		{"id added", `<section><p>x</p></section>`, "id", "hero", false, `<section id="hero"><p>x</p></section>`, false},
+		{"id replaced", `<section id="old" class="a">`, "id", "hero", false, `<section id="hero" class="a">`, false},
+		{"class merged", `<div class="card big">`, "class", "dark", true, `<div class="card big dark">`, false},
+		{"class single quoted", `<div class='card'>`, "class", "dark", true, `<div class='card dark'>`, false},
+		{"class empty", `<div class="">`, "class", "dark", true, `<div class="dark">`, false},
+		{"class added", `<div data-class="x">`, "class", "dark", true, `<div data-class="x" class="dark">`, false},
+		{"self-closing", `<img src="a.png"/>`, "id", "logo", false, `<img src="a.png" id="logo"/>`, false},
+		{"after comment and doctype", "<!DOCTYPE html>\n<!-- <b> -->\n<html>", "class", "dark", true, "<!DOCTYPE html>\n<!-- <b> -->\n<html class=\"dark\">", false},
+		{"quoted bracket", `<a title="a > b" href="/">x</a>`, "id", "home", false, `<a title="a > b" href="/" id="home">x</a>`, false},
+		{"escaped value", `<div>`, "class", `a"b`, true, `<div class="a&#34;b">`, false},
+		{"text only", `just text`, "id", "x", false, `just text`, true},
+		{"unclosed tag", `<div class="a"`, "id", "x", false, `<div class="a"`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := setRootAttr([]byte(tt.html), tt.attr, tt.value, tt.merge)
+			if string(got) != tt.want || ok == tt.wantErr {
+				t.Errorf("got %q (ok %v), want %q", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlockAttrs(t *testing.T) {
+	components := map[string]string{
+		"hero/hero.html": "\n<section class=\"hero\"><h1>{{.title}}</h1></section>",
+		"text/text.html": "plain text",
+	}
+
+	html := mustRender(t, components, "1 hero\n  .id=top\n  .class=dark wide\n  .title=Hi\n2 hero\n  .title=Plain\n")
+	want := "\n<section class=\"hero dark wide\" id=\"top\"><h1>Hi</h1></section>\n<section class=\"hero\"><h1>Plain</h1></section>"
+	if html != want {
+		t.Errorf("got %q, want %q", html, want)
+	}
+
+	_, err := renderPage(t, components, "1 text\n  .id=top\n  .class=dark\n", Options{})
+	var errs ProcessErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("got error %v, want the id and class without a root element", err)
+	}
+	for i, want := range []ProcessError{
+		{Component: "text", Directive: "id", Msg: "no root element to set the block's id on"},
+		{Component: "text", Directive: "class", Msg: "no root element to add the block's class to"},
+	} {
+		if errs[i] != want {
+			t.Errorf("error %d = %+v, want %+v", i, errs[i], want)
+		}
+	}
+}
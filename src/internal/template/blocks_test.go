@@ -0,0 +1,85 @@
+package template
+
+import (
+	"strings"
+	"testing"
+
+	"webfactory/src/internal/blueprint"
+)
+
+func TestWhenGuard(t *testing.T) {
+	components := map[string]string{
+		"page/page.html":   "<main>{{component}}</main>",
+		"promo/promo.html": "<aside>{{component}}</aside>",
+		"promo/promo.css":  ".promo { color: gold; }",
+		"note/note.html":   "<p>note</p>",
+	}
+	tests := []struct {
+		name      string
+		blueprint string
+		globals   map[string][]string
+		want      string
+	}{
+		{"set", "1 page\n1.1 promo\n  .premium=yes\n  .when=.premium\n1.1.1 note\n", nil, "<main><aside><p>note</p></aside></main>"},
+		{"unset", "1 page\n1.1 promo\n  .when=.premium\n1.1.1 note\n", nil, "<main></main>"},
+		{"false", "1 page\n1.1 promo\n  .premium=false\n  .when=premium\n1.1.1 note\n", nil, "<main></main>"},
+		{"zero", "1 page\n1.1 promo\n  .premium=0\n  .when=.premium\n", nil, "<main></main>"},
+		{"inherited", "1 page\n  .premium=true\n1.1 promo\n  .when=.premium\n", nil, "<main><aside></aside></main>"},
+		{"global", "1 page\n1.1 promo\n  .when=.premium\n", map[string][]string{"premium": {"1"}}, "<main><aside></aside></main>"},
+		{"block overrides global", "1 page\n1.1 promo\n  .premium=\n  .when=.premium\n", map[string][]string{"premium": {"1"}}, "<main></main>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := renderPage(t, components, tt.blueprint, Options{Globals: tt.globals})
+			if err != nil {
+				t.Fatalf("rendering: %v", err)
+			}
+			// Without a {{styles}} directive the stylesheet is linked ahead of the page
+			html := strings.TrimPrefix(string(result.HTML), `<link rel="stylesheet" href="css/styles.css">`)
+			if html != tt.want {
+				t.Errorf("got %q, want %q", html, tt.want)
+			}
+			// A skipped block contributes no styles either
+			rendered := strings.Contains(tt.want, "<aside>")
+			if styled := strings.Contains(string(result.Files["styles.css"]), ".promo"); styled != rendered {
+				t.Errorf("promo styles included: %v, block rendered: %v", styled, rendered)
+			}
+		})
+	}
+}
+
+func TestRepeat(t *testing.T) {
+	components := map[string]string{
+		"grid/grid.html": "<ul>{{component}}</ul>",
+		"card/card.html": "<li>{{.repeat}}:{{.label}}{{component}}</li>",
+		"tag/tag.html":   "<b>{{.repeat}}</b>",
+	}
+	tests := []struct {
+		name      string
+		blueprint string
+		want      string
+	}{
+		{"leaf", "1 grid\n1.1 card\n  .repeat=3\n  .label=x\n", "<ul><li>0:x</li><li>1:x</li><li>2:x</li></ul>"},
+		{"once", "1 grid\n1.1 card\n  .repeat=1\n", "<ul><li>0:</li></ul>"},
+		{"with children", "1 grid\n1.1 card\n  .repeat=2\n1.1.1 tag\n1.1.2 tag\n", "<ul><li>0:<b>0</b><b>0</b></li><li>1:<b>1</b><b>1</b></li></ul>"},
+		{"nested repeats", "1 grid\n1.1 card\n  .repeat=2\n1.1.1 tag\n  .repeat=2\n", "<ul><li>0:<b>0</b><b>1</b></li><li>1:<b>0</b><b>1</b></li></ul>"},
+		{"siblings unaffected", "1 grid\n1.1 card\n  .repeat=2\n1.2 tag\n", "<ul><li>0:</li><li>1:</li><b></b></ul>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if html := mustRender(t, components, tt.blueprint); html != tt.want {
+				t.Errorf("got %q, want %q", html, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepeatInvalid(t *testing.T) {
+	for _, value := range []string{"0", "-2", "many", ""} {
+		if _, err := blueprint.New("1 card\n  .repeat=" + value + "\n"); err == nil || !strings.Contains(err.Error(), "is not a positive number") {
+			t.Errorf(".repeat=%s: got error %v, want a positive number required", value, err)
+		}
+	}
+}
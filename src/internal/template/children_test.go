@@ -0,0 +1,117 @@
+package template
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestChildCount(t *testing.T) {
+	tests := []struct {
+		name      string
+		blueprint string
+		want      string
+	}{
+		{"zero", "1 list\n", "<p>0 items</p>"},
+		{"one", "1 list\n1.1 item\n", "<p>1 items</p><i></i>"},
+		{"many", "1 list\n1.1 item\n1.2 item\n1.3 item\n", "<p>3 items</p><i></i><i></i><i></i>"},
+		{"grandchildren not counted", "1 list\n1.1 item\n1.1.1 item\n", "<p>1 items</p><i></i>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html := mustRender(t, map[string]string{
+				"list/list.html": "<p>{{children.count}} items</p>{{component}}",
+				"item/item.html": "<i></i>",
+			}, tt.blueprint)
+			if html != tt.want {
+				t.Errorf("got %q, want %q", html, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndexedComponent(t *testing.T) {
+	components := map[string]string{
+		"layout/layout.html": "<header>{{component 0}}</header><aside>{{component 1}}</aside><main>{{component}}</main>",
+		"item/item.html":     "<i>{{.name}}</i>",
+	}
+	tests := []struct {
+		name      string
+		blueprint string
+		want      string
+	}{
+		{"two slots", "1 layout\n1.1 item\n  .name=a\n1.2 item\n  .name=b\n", "<header><i>a</i></header><aside><i>b</i></aside><main></main>"},
+		{"rest in plain component", "1 layout\n1.1 item\n  .name=a\n1.2 item\n  .name=b\n1.3 item\n  .name=c\n1.4 item\n  .name=d\n",
+			"<header><i>a</i></header><aside><i>b</i></aside><main><i>c</i><i>d</i></main>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if html := mustRender(t, components, tt.blueprint); html != tt.want {
+				t.Errorf("got %q, want %q", html, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndexedComponentErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		err      ProcessError
+	}{
+		{"out of range", "<p>{{component 1}}</p>", ProcessError{Component: "layout", Line: 1, Column: 4, Directive: "component 1", Msg: "child index 1 out of range, block has 1 children"}},
+		{"negative", "{{component -1}}", ProcessError{Component: "layout", Line: 1, Column: 1, Directive: "component -1", Msg: "child index -1 out of range, block has 1 children"}},
+		{"not a number", "{{component first}}", ProcessError{Component: "layout", Line: 1, Column: 1, Directive: "component first", Msg: `invalid child index "first"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := renderPage(t, map[string]string{
+				"layout/layout.html": tt.template,
+				"item/item.html":     "<i></i>",
+			}, "1 layout\n1.1 item\n", Options{})
+
+			var errs ProcessErrors
+			if !errors.As(err, &errs) || len(errs) != 1 || errs[0] != tt.err {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestNamedSlots(t *testing.T) {
+	components := map[string]string{
+		"layout/layout.html": "<header>{{slot header}}</header><aside>{{slot sidebar}}</aside><main>{{component}}</main>",
+		"item/item.html":     "<i>{{.name}}</i>",
+	}
+	tests := []struct {
+		name      string
+		blueprint string
+		want      string
+	}{
+		{
+			name:      "two named and a default",
+			blueprint: "1 layout\n1.1 item\n  .name=body\n1.2 item\n  .slot=sidebar\n  .name=nav\n1.3 item\n  .slot = header\n  .name=logo\n1.4 item\n  .slot=sidebar\n  .name=ads\n",
+			want:      "<header><i>logo</i></header><aside><i>nav</i><i>ads</i></aside><main><i>body</i></main>",
+		},
+		{
+			name:      "empty slots",
+			blueprint: "1 layout\n1.1 item\n  .name=body\n",
+			want:      "<header></header><aside></aside><main><i>body</i></main>",
+		},
+		{
+			name:      "unknown slot not rendered",
+			blueprint: "1 layout\n1.1 item\n  .slot=footer\n  .name=gone\n",
+			want:      "<header></header><aside></aside><main></main>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if html := mustRender(t, components, tt.blueprint); html != tt.want {
+				t.Errorf("got %q, want %q", html, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package template
+
+import (
+	"cmp"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"webfactory/src/internal/component"
+)
+
+// condition evaluates the condition of an if directive
+// {{if .var}} holds when the variable's value is truthy and {{if .var exists}} when the variable is set
+// at all, even to an empty value. {{if .var > 3}} compares the value with a literal, as numbers when
+// the literal is a number and as strings otherwise. A leading "not" negates any of them. Testing an
+// undefined variable other than with exists is reported like rendering it, since it is most likely a typo.
+func (p *Processor) condition(comp *component.Component, token Token, vars map[string][]string, frames []*rangeFrame) bool {
+	directive := "if " + token.Content
+	fields, quoted, ok := conditionFields(token.Content)
+	negate := ok && len(fields) > 0 && fields[0] == "not" && !quoted[0]
+	if negate {
+		fields, quoted = fields[1:], quoted[1:]
+	}
+
+	if !ok || len(fields) == 0 || len(fields) > 3 || quoted[0] || !strings.HasPrefix(fields[0], ".") || len(fields[0]) < 2 {
+		p.addTokenError(comp, token, directive, "invalid condition, expected .var, .var exists, or .var <op> value")
+		return false
+	}
+	name := fields[0][1:]
+	if !(len(fields) == 2 && fields[1] == "exists") && !defined(name, vars, frames) {
+		p.undefinedVar(comp, token, directive, fmt.Sprintf("undefined variable .%s tests as empty", name))
+	}
+
+	var result bool
+	switch {
+	case len(fields) == 1:
+		result = truthyValue(lookupVar(name, vars, frames))
+	case len(fields) == 2 && fields[1] == "exists":
+		result = defined(name, vars, frames)
+	case len(fields) == 3:
+		var err error
+		if result, err = compare(lookupVar(name, vars, frames), fields[1], fields[2], quoted[2]); err != nil {
+			p.addTokenError(comp, token, directive, err.Error())
+			return false
+		}
+	default:
+		p.addTokenError(comp, token, directive, fmt.Sprintf("unknown condition test %q", fields[1]))
+		return false
+	}
+	return result != negate
+}
+
+// conditionFields splits a condition into space-separated fields, unquoting double-quoted ones
+// It reports which fields were quoted, and false for an unclosed or malformed quote.
+func conditionFields(condition string) ([]string, []bool, bool) {
+	parts, ok := splitUnquoted(condition, ' ')
+	if !ok {
+		return nil, nil, false
+	}
+
+	var fields []string
+	var quoted []bool
+	for _, part := range parts {
+		if part == "" {
+			continue
+		}
+		isQuoted := strings.HasPrefix(part, `"`)
+		if isQuoted {
+			unquoted, err := strconv.Unquote(part)
+			if err != nil {
+				return nil, nil, false
+			}
+			part = unquoted
+		}
+		fields = append(fields, part)
+		quoted = append(quoted, isQuoted)
+	}
+	return fields, quoted, true
+}
+
+// compare compares a variable's value with a literal
+// An unquoted numeric literal compares numerically, failing when the value is not a number; any other
+// literal compares as a string.
+func compare(value, op, literal string, quoted bool) (bool, error) {
+	var c int
+	if n, err := strconv.ParseFloat(literal, 64); err == nil && !quoted {
+		v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return false, fmt.Errorf("value %q is not a number to compare with %s", value, literal)
+		}
+		c = cmp.Compare(v, n)
+	} else {
+		c = strings.Compare(value, literal)
+	}
+
+	switch op {
+	case "==":
+		return c == 0, nil
+	case "!=":
+		return c != 0, nil
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	case ">=":
+		return c >= 0, nil
+	}
+	return false, fmt.Errorf("unknown comparison operator %q", op)
+}
+
+// truthyValue reports whether a value counts as true: neither empty, "false", nor "0"
+func truthyValue(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "false", "0":
+		return false
+	}
+	return true
+}
+
+// matchIfs pairs if directives with their else and end, recording errors for stray and unclosed ones
+// and for ifs and ranges that cross instead of nesting. Unclosed ifs are closed at the end of the
+// template. The returned map leads from an if to its else, or its end when it has none, and from an
+// else to its end.
+func (p *Processor) matchIfs(comp *component.Component, tokens []Token) ([]Token, map[int]int) {
+	branches := make(map[int]int)
+	var open []int   // index of the innermost if, or of its else once seen
+	var starts []int // index of the innermost if, even once its else is seen
+	var ranges []int // index of the innermost open range start
+
+	// crossed reports a range opened after the innermost if started and still open at its else or end
+	crossed := func(token Token, directive string) {
+		if len(ranges) > 0 && ranges[len(ranges)-1] > starts[len(starts)-1] {
+			start := tokens[ranges[len(ranges)-1]]
+			p.addTokenError(comp, token, directive,
+				fmt.Sprintf("%s crosses the range started at line %d:%d, close the range first", directive, start.Line, start.Column))
+		}
+	}
+
+	for i, token := range tokens {
+		switch token.Type {
+		case RangeStartToken:
+			ranges = append(ranges, i)
+		case RangeEndToken:
+			if len(ranges) == 0 {
+				continue
+			}
+			if len(starts) > 0 && starts[len(starts)-1] > ranges[len(ranges)-1] {
+				start := tokens[starts[len(starts)-1]]
+				p.addTokenError(comp, token, "range end",
+					fmt.Sprintf("range end crosses the if started at line %d:%d, close the if first", start.Line, start.Column))
+			}
+			ranges = ranges[:len(ranges)-1]
+		case IfToken:
+			open = append(open, i)
+			starts = append(starts, i)
+		case ElseToken:
+			if len(open) == 0 || tokens[open[len(open)-1]].Type == ElseToken {
+				p.addTokenError(comp, token, "else", "else without matching if")
+				continue
+			}
+			crossed(token, "else")
+			branches[open[len(open)-1]] = i
+			open[len(open)-1] = i
+		case IfEndToken:
+			if len(open) == 0 {
+				p.addTokenError(comp, token, "if end", "if end without matching if")
+				continue
+			}
+			crossed(token, "if end")
+			branches[open[len(open)-1]] = i
+			open = open[:len(open)-1]
+			starts = starts[:len(starts)-1]
+		}
+	}
+
+	// Close innermost unclosed ifs first
+	for j := len(open) - 1; j >= 0; j-- {
+		start := tokens[open[j]]
+		p.addTokenError(comp, start, "if", fmt.Sprintf("unclosed if started at line %d", start.Line))
+		branches[open[j]] = len(tokens)
+		tokens = append(tokens, Token{Type: IfEndToken, Line: start.Line, Column: start.Column})
+	}
+
+	return tokens, branches
+}
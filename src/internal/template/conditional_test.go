@@ -0,0 +1,137 @@
+package template
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestIfExists(t *testing.T) {
+	template := "{{if .x}}truthy{{else}}falsy{{if end}} {{if .x exists}}set{{else}}unset{{if end}}"
+	tests := []struct {
+		name      string
+		blueprint string
+		want      string
+	}{
+		{"present", "1 page\n  .x=yes\n", "truthy set"},
+		{"present but empty", "1 page\n  .x=\n", "falsy set"},
+		{"absent", "1 page\n", "falsy unset"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := renderPage(t, map[string]string{"page/page.html": template}, tt.blueprint, Options{})
+			if err != nil {
+				t.Fatalf("rendering: %v", err)
+			}
+			if got := string(result.HTML); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIfCrossingRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     ProcessError
+	}{
+		{
+			name:     "if end inside range",
+			template: "{{if .x}}{{range .a}}{{if end}}{{.a}}{{range end}}",
+			want:     ProcessError{Line: 1, Column: 22, Directive: "if end", Msg: "if end crosses the range started at line 1:10, close the range first"},
+		},
+		{
+			name:     "else inside range",
+			template: "{{if .x}}\n  {{range .a}}{{else}}{{range end}}{{if end}}",
+			want:     ProcessError{Line: 2, Column: 15, Directive: "else", Msg: "else crosses the range started at line 2:3, close the range first"},
+		},
+		{
+			name:     "range end inside if",
+			template: "{{range .a}}{{if .x}}{{range end}}{{if end}}",
+			want:     ProcessError{Line: 1, Column: 22, Directive: "range end", Msg: "range end crosses the if started at line 1:13, close the if first"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := renderPage(t, map[string]string{"page/page.html": tt.template}, "1 page\n  .x=1\n  .a=a\n", Options{})
+			var errs ProcessErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("got error %v, want ProcessErrors", err)
+			}
+			tt.want.Component = "page"
+			if len(errs) != 1 || errs[0] != tt.want {
+				t.Errorf("got errors %v, want only %v", errs, tt.want)
+			}
+		})
+	}
+}
+
+func TestIfNestedInRange(t *testing.T) {
+	html := mustRender(t, map[string]string{
+		"page/page.html": "{{range .a}}{{if .a == b}}[{{.a}}]{{else}}{{.a}}{{if end}}{{range end}}",
+	}, "1 page\n  .a=a\n  .a=b\n  .a=c\n")
+	if want := "a[b]c"; html != want {
+		t.Errorf("got %q, want %q", html, want)
+	}
+}
+
+func TestIfCompare(t *testing.T) {
+	tests := []struct {
+		condition string
+		want      bool
+	}{
+		{".count > 3", true},
+		{".count >= 10", true},
+		{".count < 10", false},
+		{".count <= 10", true},
+		{".count == 10.0", true},
+		{".count != 10", false},
+		{".count > 9.5", true},
+		{".count > -1", true},
+		{"not .count > 3", false},
+		{`.mode == "dark"`, true},
+		{".mode == dark", true},
+		{".mode != light", true},
+		{".mode < light", true},
+		{`.count == "10"`, true},
+		{`.count < "9"`, true},
+		{`.title == "Hello world"`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.condition, func(t *testing.T) {
+			html := mustRender(t, map[string]string{"page/page.html": "{{if " + tt.condition + "}}yes{{else}}no{{if end}}"},
+				"1 page\n  .count=10\n  .mode=dark\n  .title=Hello world\n")
+			if want := map[bool]string{true: "yes", false: "no"}[tt.want]; html != want {
+				t.Errorf("got %q, want %q", html, want)
+			}
+		})
+	}
+}
+
+func TestIfCompareErrors(t *testing.T) {
+	tests := []struct {
+		condition string
+		msg       string
+	}{
+		{".mode > 3", `value "dark" is not a number to compare with 3`},
+		{".count ~= 3", `unknown comparison operator "~="`},
+		{".count is", `unknown condition test "is"`},
+		{".count > 3 4", "invalid condition, expected .var, .var exists, or .var <op> value"},
+		{"count > 3", "invalid condition, expected .var, .var exists, or .var <op> value"},
+		{`.mode == "dark`, "invalid condition, expected .var, .var exists, or .var <op> value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.condition, func(t *testing.T) {
+			_, err := renderPage(t, map[string]string{"page/page.html": "<p>{{if " + tt.condition + "}}yes{{if end}}</p>"},
+				"1 page\n  .count=10\n  .mode=dark\n", Options{})
+			var errs ProcessErrors
+			if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Msg != tt.msg || errs[0].Line != 1 || errs[0].Column != 4 {
+				t.Errorf("got error %v, want %q at 1:4", err, tt.msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package template
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUnknownDirective(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     ProcessError
+	}{
+		{
+			name:     "misspelled",
+			template: "<p>\n  {{rang .items}}\n</p>",
+			want:     ProcessError{Line: 2, Column: 3, Directive: "rang .items", Msg: "unknown directive {{rang .items}}"},
+		},
+		{
+			name:     "plural",
+			template: "<body>{{scripts}}</body>",
+			want:     ProcessError{Line: 1, Column: 7, Directive: "scripts", Msg: "unknown directive {{scripts}}"},
+		},
+		{
+			name:     "empty",
+			template: "<p>{{}}</p>",
+			want:     ProcessError{Line: 1, Column: 4, Directive: "", Msg: "empty directive"},
+		},
+		{
+			name:     "blank",
+			template: "<p>{{   }}</p>",
+			want:     ProcessError{Line: 1, Column: 4, Directive: "", Msg: "empty directive"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := renderPage(t, map[string]string{"page/page.html": tt.template}, "1 page\n  .items=a\n", Options{})
+			var errs ProcessErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("got error %v, want ProcessErrors", err)
+			}
+			tt.want.Component = "page"
+			if len(errs) != 1 || errs[0] != tt.want {
+				t.Errorf("got errors %v, want only %v", errs, tt.want)
+			}
+		})
+	}
+}
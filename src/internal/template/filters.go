@@ -0,0 +1,207 @@
+package template
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Filter is a transform applied to a value in a directive, like upper in {{.title | upper}}
+type Filter struct {
+	Name string
+	Args []string
+}
+
+// FilterFunc transforms a value, configured by the arguments given to the filter in the template
+type FilterFunc func(value string, args ...string) (string, error)
+
+// dateLayouts are the accepted input formats of the date filter
+var dateLayouts = []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"}
+
+// builtinFilters are available in every template
+var builtinFilters = map[string]FilterFunc{
+	"upper": func(value string, args ...string) (string, error) {
+		return strings.ToUpper(value), nil
+	},
+	"lower": func(value string, args ...string) (string, error) {
+		return strings.ToLower(value), nil
+	},
+	"title": func(value string, args ...string) (string, error) {
+		prev := ' '
+		return strings.Map(func(r rune) rune {
+			if unicode.IsSpace(prev) || prev == '-' {
+				r = unicode.ToUpper(r)
+			}
+			prev = r
+			return r
+		}, value), nil
+	},
+	"trim": func(value string, args ...string) (string, error) {
+		return strings.TrimSpace(value), nil
+	},
+	"truncate": func(value string, args ...string) (string, error) {
+		if len(args) != 1 {
+			return "", fmt.Errorf("truncate takes a length, as in truncate:80")
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 0 {
+			return "", fmt.Errorf("truncate length %q is not a non-negative number", args[0])
+		}
+		if utf8.RuneCountInString(value) <= n {
+			return value, nil
+		}
+		return strings.TrimRightFunc(string([]rune(value)[:n]), unicode.IsSpace) + "…", nil
+	},
+	"default": func(value string, args ...string) (string, error) {
+		if len(args) != 1 {
+			return "", fmt.Errorf(`default takes a value, as in default:"none"`)
+		}
+		if value == "" {
+			return args[0], nil
+		}
+		return value, nil
+	},
+	"date": func(value string, args ...string) (string, error) {
+		if len(args) != 1 {
+			return "", fmt.Errorf(`date takes a Go time layout, as in date "2006-01-02"`)
+		}
+		if value == "" {
+			return "", nil
+		}
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, value); err == nil {
+				return t.Format(args[0]), nil
+			}
+		}
+		return "", fmt.Errorf("invalid date %q, expected YYYY-MM-DD or RFC 3339", value)
+	},
+}
+
+// RegisterFilter adds a custom filter, which must not reuse the name of a built-in or registered one
+func (p *Processor) RegisterFilter(name string, fn FilterFunc) error {
+	if err := checkFilter(name, fn); err != nil {
+		return err
+	}
+	if _, exists := p.filters[name]; exists {
+		return fmt.Errorf("filter %s is already registered", name)
+	}
+	p.filters[name] = fn
+	return nil
+}
+
+// CheckFilters validates custom filters before they are passed in Options
+func CheckFilters(filters map[string]FilterFunc) error {
+	for name, fn := range filters {
+		if err := checkFilter(name, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkFilter rejects custom filters that can't be called from a template or would shadow a built-in
+func checkFilter(name string, fn FilterFunc) error {
+	if name == "" || strings.ContainsAny(name, " \t\n|:\"{}") {
+		return fmt.Errorf("invalid filter name %q", name)
+	}
+	if fn == nil {
+		return fmt.Errorf("filter %s has no function", name)
+	}
+	if _, builtin := builtinFilters[name]; builtin {
+		return fmt.Errorf("filter %s collides with a built-in filter", name)
+	}
+	return nil
+}
+
+// parseFilters splits a directive into the value it names and the filters piped after it
+// A filter takes arguments after a colon, truncate:80, or separated by spaces, date "Jan 2, 2006";
+// arguments may be double-quoted. It reports false for a malformed pipeline.
+func parseFilters(directive string) (string, []Filter, bool) {
+	parts, ok := splitUnquoted(directive, '|')
+	if !ok {
+		return "", nil, false
+	}
+
+	var filters []Filter
+	for _, part := range parts[1:] {
+		part = strings.TrimSpace(part)
+
+		var fields []string
+		if name, arg, found := strings.Cut(part, ":"); found && !strings.ContainsAny(name, " \t\"") {
+			fields = []string{name, strings.TrimSpace(arg)}
+		} else if fields, ok = splitUnquoted(part, ' '); !ok {
+			return "", nil, false
+		}
+
+		filter := Filter{}
+		for _, field := range fields {
+			if field == "" {
+				continue
+			}
+			if strings.HasPrefix(field, `"`) {
+				unquoted, err := strconv.Unquote(field)
+				if err != nil {
+					return "", nil, false
+				}
+				field = unquoted
+			}
+			if filter.Name == "" {
+				filter.Name = field
+			} else {
+				filter.Args = append(filter.Args, field)
+			}
+		}
+		if filter.Name == "" {
+			return "", nil, false
+		}
+		filters = append(filters, filter)
+	}
+
+	return strings.TrimSpace(parts[0]), filters, true
+}
+
+// splitUnquoted splits s at every sep outside double quotes, reporting false for an unclosed quote
+func splitUnquoted(s string, sep byte) ([]string, bool) {
+	var parts []string
+	quoted, start := false, 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:]), !quoted
+}
+
+// applyFilters runs value through filters in order
+func (p *Processor) applyFilters(value string, filters []Filter) (string, error) {
+	for _, f := range filters {
+		fn, ok := p.filters[f.Name]
+		if !ok {
+			return "", fmt.Errorf("unknown filter %s", f.Name)
+		}
+		var err error
+		if value, err = fn(value, f.Args...); err != nil {
+			return "", fmt.Errorf("filter %s: %w", f.Name, err)
+		}
+	}
+	return value, nil
+}
+
+// hasFilter reports whether filters include the named one
+func hasFilter(filters []Filter, name string) bool {
+	for _, f := range filters {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
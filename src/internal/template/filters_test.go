@@ -0,0 +1,126 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+func TestFilters(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"upper", "{{.title | upper}}", "A LONG TITLE-CASE NAME"},
+		{"chained", "{{.title | title | truncate:12}}", "A Long Title…"},
+		{"without spaces", "{{.title|truncate:6|upper}}", "A LONG…"},
+		{"default then upper", `{{.missing | default:"none" | upper}}`, "NONE"},
+		{"upper then default", `{{.missing | upper | default:"none"}}`, "none"},
+		{"default kept value", `{{.date | default:"none"}}`, "2024-03-05"},
+		{"date with spaced argument", `{{.date | date "Jan 2, 2006"}}`, "Mar 5, 2024"},
+		{"quoted pipe argument", `{{.missing | default:"a | b"}}`, "a | b"},
+		{"meta", "{{meta.title | lower}}", "home"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html := mustRender(t, map[string]string{"page/page.html": tt.template},
+				"---\ntitle = HOME\n---\n1 page\n  .title=a long title-case name\n  .date=2024-03-05\n")
+			if html != tt.want {
+				t.Errorf("got %q, want %q", html, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		err      ProcessError
+	}{
+		{"unknown", "<p>{{.title | upper | shout}}</p>", ProcessError{Component: "page", Line: 1, Column: 4, Directive: ".title", Msg: "unknown filter shout"}},
+		{"bad argument", "{{.title | truncate:many}}", ProcessError{Component: "page", Line: 1, Column: 1, Directive: ".title", Msg: `filter truncate: truncate length "many" is not a non-negative number`}},
+		{"missing argument", "{{.title | date}}", ProcessError{Component: "page", Line: 1, Column: 1, Directive: ".title", Msg: `filter date: date takes a Go time layout, as in date "2006-01-02"`}},
+		{"malformed pipeline", "{{.title | }}", ProcessError{Component: "page", Line: 1, Column: 1, Directive: ".title |", Msg: "unknown directive {{.title |}}"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := renderPage(t, map[string]string{"page/page.html": tt.template}, "1 page\n  .title=Hi\n", Options{})
+			var errs ProcessErrors
+			if !errors.As(err, &errs) || len(errs) != 1 || errs[0] != tt.err {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+// currency formats a number of cents as euros, failing on anything else
+func currency(value string, args ...string) (string, error) {
+	cents, err := strconv.Atoi(value)
+	if err != nil {
+		return "", fmt.Errorf("%q is not a number of cents", value)
+	}
+	symbol := "€"
+	if len(args) > 0 {
+		symbol = args[0]
+	}
+	return fmt.Sprintf("%s%d.%02d", symbol, cents/100, cents%100), nil
+}
+
+func TestCustomFilter(t *testing.T) {
+	components := map[string]string{"page/page.html": `<p>{{.price | currency}} {{.price | currency "$" | lower}}</p>`}
+	opts := Options{Filters: map[string]FilterFunc{"currency": currency}}
+
+	result, err := renderPage(t, components, "1 page\n  .price=1250\n", opts)
+	if err != nil {
+		t.Fatalf("rendering: %v", err)
+	}
+	if html, want := string(result.HTML), "<p>€12.50 $12.50</p>"; html != want {
+		t.Errorf("got %q, want %q", html, want)
+	}
+
+	_, err = renderPage(t, components, "1 page\n  .price=cheap\n", opts)
+	var errs ProcessErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("got error %v, want the failing filter at both uses", err)
+	}
+	want := ProcessError{Component: "page", Line: 1, Column: 4, Directive: ".price", Msg: `filter currency: "cheap" is not a number of cents`}
+	if errs[0] != want {
+		t.Errorf("got %+v, want %+v", errs[0], want)
+	}
+}
+
+func TestRegisterFilter(t *testing.T) {
+	p := New(nil, Options{})
+	if err := p.RegisterFilter("currency", currency); err != nil {
+		t.Fatalf("registering: %v", err)
+	}
+	if got, err := p.applyFilters("995", []Filter{{Name: "currency"}, {Name: "upper"}}); err != nil || got != "€9.95" {
+		t.Errorf("applying the registered filter = %q, %v", got, err)
+	}
+
+	tests := []struct {
+		name string
+		fn   FilterFunc
+		err  string
+	}{
+		{"currency", currency, "filter currency is already registered"},
+		{"upper", currency, "filter upper collides with a built-in filter"},
+		{"to money", currency, `invalid filter name "to money"`},
+		{"a|b", currency, `invalid filter name "a|b"`},
+		{"", currency, `invalid filter name ""`},
+		{"noop", nil, "filter noop has no function"},
+	}
+	for _, tt := range tests {
+		if err := p.RegisterFilter(tt.name, tt.fn); err == nil || err.Error() != tt.err {
+			t.Errorf("RegisterFilter(%q) = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+	if err := CheckFilters(map[string]FilterFunc{"date": currency}); err == nil {
+		t.Error("CheckFilters accepted a filter shadowing a built-in")
+	}
+}
@@ -0,0 +1,35 @@
+package template
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+)
+
+// headTags builds HTML-escaped title and meta tags from front matter
+// title becomes <title>, description a named meta tag, and og:* keys Open Graph property tags.
+func headTags(meta map[string]string) string {
+	var b strings.Builder
+
+	if title, ok := meta["title"]; ok {
+		fmt.Fprintf(&b, "<title>%s</title>\n", html.EscapeString(title))
+	}
+	if desc, ok := meta["description"]; ok {
+		fmt.Fprintf(&b, "<meta name=\"description\" content=\"%s\">\n", html.EscapeString(desc))
+	}
+
+	var ogKeys []string
+	for key := range meta {
+		if strings.HasPrefix(key, "og:") {
+			ogKeys = append(ogKeys, key)
+		}
+	}
+	sort.Strings(ogKeys)
+	for _, key := range ogKeys {
+		fmt.Fprintf(&b, "<meta property=\"%s\" content=\"%s\">\n",
+			html.EscapeString(key), html.EscapeString(meta[key]))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,58 @@
+package template
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHeadTags(t *testing.T) {
+	got := headTags(map[string]string{
+		"title":       `Tom & "Jerry" <3`,
+		"description": "Cats & mice",
+		"og:title":    "Tom",
+		"og:image":    "/img/a.png?x=1&y=2",
+		"author":      "not a head tag",
+	})
+	want := "<title>Tom &amp; &#34;Jerry&#34; &lt;3</title>\n" +
+		"<meta name=\"description\" content=\"Cats &amp; mice\">\n" +
+		"<meta property=\"og:image\" content=\"/img/a.png?x=1&amp;y=2\">\n" +
+		"<meta property=\"og:title\" content=\"Tom\">\n"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestHeadPlacement(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"head directive", "<head>{{head}}</head><body></body>", "<head><title>Hi</title>\n</head><body></body>"},
+		{"no head directive", "<p>x</p>", "<title>Hi</title>\n<p>x</p>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := renderPage(t, map[string]string{"page/page.html": tt.template},
+				"---\ntitle = Hi\n---\n1 page\n", Options{HeadTags: true})
+			if err != nil {
+				t.Fatalf("rendering: %v", err)
+			}
+			if got := string(result.HTML); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeadTagsOff(t *testing.T) {
+	result, err := renderPage(t, map[string]string{"page/page.html": "<head>{{head}}</head>"},
+		"---\ntitle = Hi\n---\n1 page\n", Options{})
+	if err != nil {
+		t.Fatalf("rendering: %v", err)
+	}
+	if html := string(result.HTML); strings.Contains(html, "<title>") {
+		t.Errorf("head tags generated without HeadTags: %q", html)
+	}
+}
@@ -0,0 +1,50 @@
+package template
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"webfactory/src/internal/assets"
+	"webfactory/src/internal/component"
+)
+
+// imageAttr matches a src, href, or srcset attribute with a quoted value
+var imageAttr = regexp.MustCompile(`(?i)(\s(?:src|href|srcset)\s*=\s*)("[^"]*"|'[^']*')`)
+
+// imageRefs points references to a component's own images, such as src="logo.svg", at their output URLs
+// A reference is a path relative to the component directory; other URLs are left alone.
+func imageRefs(output []byte, comp *component.Component, prefix string) []byte {
+	return imageAttr.ReplaceAllFunc(output, func(attr []byte) []byte {
+		m := imageAttr.FindSubmatch(attr)
+		name, quote, value := string(m[1]), string(m[2][:1]), string(m[2][1:len(m[2])-1])
+
+		if !strings.HasSuffix(strings.ToLower(strings.TrimSpace(name)), "srcset=") {
+			return []byte(name + quote + imageURL(value, comp, prefix) + quote)
+		}
+		candidates := strings.Split(value, ",")
+		for i, candidate := range candidates {
+			fields := strings.Fields(candidate)
+			if len(fields) == 0 {
+				continue
+			}
+			fields[0] = imageURL(fields[0], comp, prefix)
+			candidates[i] = strings.Join(fields, " ")
+		}
+		return []byte(name + quote + strings.Join(candidates, ", ") + quote)
+	})
+}
+
+// imageURL returns the output URL of a reference to one of the component's images, or the reference
+// unchanged when it names none of them
+func imageURL(ref string, comp *component.Component, prefix string) string {
+	file := strings.TrimSpace(ref)
+	if file == "" || strings.HasPrefix(file, "/") || strings.Contains(file, ":") {
+		return ref
+	}
+	file = path.Clean(file)
+	if _, ok := comp.Images[file]; !ok {
+		return ref
+	}
+	return assets.ImageURL(prefix, comp.Path, file)
+}
@@ -0,0 +1,35 @@
+package template
+
+import (
+	"testing"
+
+	"webfactory/src/internal/component"
+)
+
+func TestImageRefs(t *testing.T) {
+	comp := &component.Component{
+		Path:   "ui.card",
+		Images: map[string][]byte{"logo.svg": nil, "icons/menu.png": nil},
+	}
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"src", `<img src="logo.svg">`, `<img src="../img/ui-card-logo.svg">`},
+		{"subdirectory", `<img src='./icons/menu.png'>`, `<img src='../img/ui-card-icons-menu.png'>`},
+		{"href", `<a href="logo.svg">`, `<a href="../img/ui-card-logo.svg">`},
+		{"srcset", `<img srcset="logo.svg 1x, icons/menu.png 2x">`, `<img srcset="../img/ui-card-logo.svg 1x, ../img/ui-card-icons-menu.png 2x">`},
+		{"not an image of the component", `<img src="other.png">`, `<img src="other.png">`},
+		{"root-relative", `<img src="/logo.svg">`, `<img src="/logo.svg">`},
+		{"absolute", `<img src="https://example.com/logo.svg">`, `<img src="https://example.com/logo.svg">`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(imageRefs([]byte(tt.html), comp, "../")); got != tt.want {
+				t.Errorf("got  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
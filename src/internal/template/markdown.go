@@ -0,0 +1,48 @@
+package template
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// markdown converts Markdown templates to HTML, passing embedded HTML through unchanged
+var markdown = goldmark.New(
+	goldmark.WithRendererOptions(html.WithUnsafe()),
+)
+
+// splicedMarkerF stands in a Markdown template for the HTML of a child or include, as in <!--wf-html:0-->
+// Markdown passes the comment through, so the HTML is put back unchanged once the template is converted.
+const splicedMarkerF = "<!--wf-html:%d-->"
+
+// renderMarkdown converts substituted Markdown content to HTML, then puts the spliced HTML back at its markers
+func renderMarkdown(src []byte, spliced [][]byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := markdown.Convert(src, &buf); err != nil {
+		return nil, err
+	}
+	return unsplice(buf.Bytes(), spliced), nil
+}
+
+// splice keeps html aside and returns the marker standing in for it
+func splice(spliced *[][]byte, html []byte) []byte {
+	*spliced = append(*spliced, html)
+	return fmt.Appendf(nil, splicedMarkerF, len(*spliced)-1)
+}
+
+// unsplice replaces the markers in output with the HTML they stand for
+// A marker alone in a paragraph replaces the paragraph, as block markup may not sit inside one.
+func unsplice(output []byte, spliced [][]byte) []byte {
+	for i, html := range spliced {
+		marker := fmt.Appendf(nil, splicedMarkerF, i)
+		paragraph := append(append([]byte("<p>"), marker...), "</p>"...)
+		if bytes.Contains(output, paragraph) {
+			output = bytes.Replace(output, paragraph, html, 1)
+		} else {
+			output = bytes.Replace(output, marker, html, 1)
+		}
+	}
+	return output
+}
@@ -0,0 +1,110 @@
+package template
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUndefinedVarInRangeLine(t *testing.T) {
+	result, err := renderPage(t, map[string]string{
+		"list/list.html": "<ul>\n{{range .items}}\n  <li>{{.name}}</li>\n{{range end}}\n</ul>",
+	}, "1 list\n  .items=a\n  .items=b\n", Options{})
+	if err != nil {
+		t.Fatalf("rendering: %v", err)
+	}
+
+	want := ProcessError{Component: "list", Line: 3, Column: 7, Directive: ".name", Msg: "undefined variable .name renders empty"}
+	if len(result.Warnings) != 1 || result.Warnings[0] != want {
+		t.Errorf("got warnings %v, want only %v", result.Warnings, want)
+	}
+}
+
+func TestUnmatchedRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     ProcessError
+	}{
+		{
+			name:     "end without start",
+			template: "<ul>{{.items}}</ul>\n{{range end}}",
+			want:     ProcessError{Line: 2, Column: 1, Directive: "range end", Msg: "range end without matching range start"},
+		},
+		{
+			name:     "start without end",
+			template: "<ul>\n  {{range .items}}<li>{{.items}}</li>\n</ul>",
+			want:     ProcessError{Line: 2, Column: 3, Directive: "range .items", Msg: "unclosed range started at line 2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := renderPage(t, map[string]string{"list/list.html": tt.template}, "1 list\n  .items=a\n", Options{})
+			var errs ProcessErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("got error %v, want ProcessErrors", err)
+			}
+			tt.want.Component = "list"
+			if len(errs) != 1 || errs[0] != tt.want {
+				t.Errorf("got errors %v, want only %v", errs, tt.want)
+			}
+		})
+	}
+}
+
+func TestRangeParallelValues(t *testing.T) {
+	tests := []struct {
+		name      string
+		blueprint string
+		want      string
+	}{
+		{"equal length", "1 list\n  .names=tea\n  .names=cake\n  .prices=2\n  .prices=3\n", "tea=2;cake=3;"},
+		{"shorter sibling", "1 list\n  .names=tea\n  .names=cake\n  .names=jam\n  .prices=2\n  .prices=3\n", "tea=2;cake=3;jam=;"},
+		{"single value", "1 list\n  .names=tea\n  .names=cake\n  .prices=2\n", "tea=2;cake=2;"},
+		{"longer sibling", "1 list\n  .names=tea\n  .prices=2\n  .prices=3\n", "tea=2;"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html := mustRender(t, map[string]string{
+				"list/list.html": "{{range .names}}{{.names}}={{.prices}};{{range end}}",
+			}, tt.blueprint)
+			if html != tt.want {
+				t.Errorf("got %q, want %q", html, tt.want)
+			}
+		})
+	}
+}
+
+func TestRangeZip(t *testing.T) {
+	blueprint := "1 list\n  .a=1\n  .a=2\n  .a=3\n  .b=x\n  .b=y\n  .c=p\n  .c=q\n  .c=r\n  .c=s\n"
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"two, shortest", "{{range .a .b}}{{.a}}{{.b}};{{range end}}", "1x;2y;"},
+		{"two, padded", "{{range .a .b pad}}{{.a}}{{.b}};{{range end}}", "1x;2y;3;"},
+		{"three, shortest", "{{range .a .b .c}}{{.a}}{{.b}}{{.c}};{{range end}}", "1xp;2yq;"},
+		{"three, padded", "{{range .c .a .b pad}}{{.a}}{{.b}}{{.c}};{{range end}}", "1xp;2yq;3r;s;"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html := mustRender(t, map[string]string{"list/list.html": tt.template}, blueprint)
+			if html != tt.want {
+				t.Errorf("got %q, want %q", html, tt.want)
+			}
+		})
+	}
+}
+
+func TestRangeZipInvalidArgument(t *testing.T) {
+	_, err := renderPage(t, map[string]string{
+		"list/list.html": "{{range .a b}}{{.a}}{{range end}}",
+	}, "1 list\n  .a=1\n", Options{})
+	var errs ProcessErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Msg != `invalid range argument "b"` {
+		t.Errorf("got error %v, want an invalid range argument", err)
+	}
+}
\ No newline at end of file
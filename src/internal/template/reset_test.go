@@ -0,0 +1,57 @@
+package template
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"testing"
+)
+
+func TestResetMatchesFresh(t *testing.T) {
+	registry := newRegistry(map[string]string{
+		"page/page.html": "<head>{{head}}{{styles}}</head>{{component}}{{script}}",
+		"card/card.html": "<div>{{.title}}</div>",
+		"card/card.css":  ".card { margin: 0; }",
+		"card/card.js":   "console.log('card')",
+		"note/note.html": "<p>{{.missing}}</p>",
+		"note/note.css":  ".note { color: red; }",
+		"bad/bad.html":   "{{bogus}}",
+	})
+	pages := []struct {
+		blueprint string
+		opts      Options
+	}{
+		{"---\ntitle = Home\n---\n1 page\n1.1 card\n  .title=A\n", Options{HeadTags: true, Globals: map[string][]string{"site": {"Docs"}}}},
+		{"1 note\n2 bad\n", Options{AssetPrefix: "../"}},
+		{"1 note\n", Options{StrictVars: true}},
+		{"1 page\n1.1 note\n", Options{}},
+	}
+
+	reused := New(registry, Options{})
+	for i, page := range pages {
+		tree := loadTree(t, registry, page.blueprint)
+		want, wantErr := New(registry, page.opts).Assembler(tree)
+
+		reused.Reset(page.opts)
+		got, err := reused.Assembler(tree)
+
+		if fmt.Sprint(err) != fmt.Sprint(wantErr) {
+			t.Errorf("page %d: got error %v, fresh processor %v", i, err, wantErr)
+		}
+		if err != nil || wantErr != nil {
+			continue
+		}
+		if string(got.HTML) != string(want.HTML) {
+			t.Errorf("page %d: got\n%s\nfresh processor\n%s", i, got.HTML, want.HTML)
+		}
+		if !maps.EqualFunc(got.Files, want.Files, func(a, b []byte) bool { return string(a) == string(b) }) {
+			t.Errorf("page %d: got files %q, fresh processor %q", i, got.Files, want.Files)
+		}
+		if !reflect.DeepEqual(got.Components, want.Components) || !reflect.DeepEqual(got.Warnings, want.Warnings) {
+			t.Errorf("page %d: got components %v and warnings %v, fresh processor %v and %v", i, got.Components, got.Warnings, want.Components, want.Warnings)
+		}
+		if !maps.Equal(got.Meta, want.Meta) {
+			t.Errorf("page %d: got meta %v, fresh processor %v", i, got.Meta, want.Meta)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package template
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// countingWriter collects what is written to it, recording the size of each write
+type countingWriter struct {
+	buf    bytes.Buffer
+	writes []int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes = append(w.writes, len(p))
+	return w.buf.Write(p)
+}
+
+// failingWriter fails every write
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestProcessToMatchesProcess(t *testing.T) {
+	components := map[string]string{
+		"page/page.html": "<main>{{styles}}{{component}}{{script}}</main>",
+		"page/page.css":  "main { margin: 0; }",
+		"list/list.html": "<ul>{{range .items}}<li>{{.items | upper}}</li>{{range end}}</ul>",
+		"post/post.md":   "# {{.title}}\n\n{{component}}\n",
+		"card/card.html": "<div>{{.title}}</div>",
+		"card/card.js":   "console.log(1)",
+		"bad/bad.html":   "<p>{{bogus}}</p>",
+	}
+	tests := []struct {
+		name      string
+		blueprint string
+		failing   bool
+	}{
+		{"one block", "1 page\n1.1 card\n  .title=A\n", false},
+		{"several blocks", "1 list\n  .items=a\n  .items=b\n2 post\n  .title=Post\n2.1 card\n  .title=B\n3 card\n  .title=C\n", false},
+		{"with errors", "1 card\n  .title=A\n2 bad\n3 card\n  .title=B\n", true},
+		{"rendered aside", "1 page\n1.1 card\n  .id=top\n  .class=dark\n  .repeat=2\n1.2 post\n  .title=Post\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buffered, tree := newProcessor(t, components, tt.blueprint, Options{})
+			want, wantErr := buffered.Process(tree)
+
+			streaming, tree := newProcessor(t, components, tt.blueprint, Options{})
+			var w countingWriter
+			err := streaming.ProcessTo(&w, tree)
+
+			if !bytes.Equal(w.buf.Bytes(), want) {
+				t.Errorf("ProcessTo wrote\n%q\nProcess returned\n%q", w.buf.Bytes(), want)
+			}
+			if (wantErr != nil) != tt.failing || fmt.Sprint(err) != fmt.Sprint(wantErr) {
+				t.Errorf("ProcessTo returned %v, Process %v", err, wantErr)
+			}
+			// Template text and values are written as rendered, not a block at a time
+			if len(w.writes) <= len(tree.Children) {
+				t.Errorf("got %d writes for %d top-level blocks, want the output streamed", len(w.writes), len(tree.Children))
+			}
+		})
+	}
+}
+
+func TestProcessToWriteError(t *testing.T) {
+	p, tree := newProcessor(t, map[string]string{"card/card.html": "<div></div>"}, "1 card\n2 card\n", Options{})
+	if err := p.ProcessTo(failingWriter{}, tree); err == nil || err.Error() != "writing output: disk full" {
+		t.Errorf("got error %v, want the write error", err)
+	}
+}
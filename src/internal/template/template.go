@@ -3,6 +3,9 @@ package template
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"maps"
+	"strconv"
 	"strings"
 	"webfactory/src/internal/assets"
 	"webfactory/src/internal/blueprint"
@@ -14,105 +17,399 @@ type ProcessResult struct {
 	HTML       []byte
 	Files      map[string][]byte // Combined CSS and individual JS files from GetFiles()
 	Components map[string]string
+	Warnings   ProcessErrors     // Problems that did not stop processing, such as undefined variables
+	Meta       map[string]string // Front matter of the page
 }
 
 // Assembler wraps Process() to return all template outputs
+// Template problems are reported as a wrapped ProcessErrors, retrievable with errors.As
 func (p *Processor) Assembler(node *blueprint.Node) (*ProcessResult, error) {
 	html, err := p.Process(node)
 	if err != nil {
 		return nil, fmt.Errorf("processing template: %w", err)
 	}
+	return p.Assemble(html)
+}
 
-	stylesTag, scriptTags := p.assets.GetAssetTags("")
-	var finalBuf bytes.Buffer
-
-	if p.hasStyles {
-		html = bytes.ReplaceAll(html, []byte("{{styles}}"), []byte(stylesTag))
-	} else if stylesTag != "" {
-		finalBuf.WriteString(stylesTag)
+// Assemble returns all template outputs of a page rendered by ProcessTo, putting the asset tags into html
+// The result does not keep html, so its buffer may be reused for the next page.
+func (p *Processor) Assemble(html []byte) (*ProcessResult, error) {
+	if err := p.assets.Finalize(); err != nil {
+		return nil, fmt.Errorf("processing assets: %w", err)
 	}
 
-	finalBuf.Write(html)
+	stylesTag, _ := p.assets.GetAssetTags(p.opts.AssetPrefix)
 
-	if p.hasScripts {
-		finalBuf = *bytes.NewBuffer(bytes.ReplaceAll(finalBuf.Bytes(), []byte("{{script}}"), []byte(scriptTags)))
-	} else if scriptTags != "" {
-		finalBuf.WriteString(scriptTags)
+	// Without a {{head}} directive, generated head tags go before the stylesheet, followed by preload hints
+	var before string
+	if p.opts.HeadTags && !p.hasHead {
+		before = headTags(p.meta)
 	}
+	before += p.assets.GetPreloadTags(p.opts.AssetPrefix)
 
 	result := &ProcessResult{
-		HTML:       finalBuf.Bytes(),
+		HTML:       p.assemble(html, before, stylesTag),
 		Files:      p.assets.GetFiles(),
 		Components: p.GetUsedComponents(),
+		Warnings:   p.Warnings(),
+		Meta:       p.meta,
 	}
 
 	return result, nil
 }
 
+// Options controls optional processing behavior
+type Options struct {
+	Globals     map[string][]string // Site-wide variables with the lowest precedence
+	HeadTags    bool                // Generate title and meta tags from front matter
+	AssetPrefix string              // Path from the page to the site root, prepended to asset URLs
+	Assets      assets.Options
+	Filters     map[string]FilterFunc // Custom filters added to the built-in ones, checked with CheckFilters
+	Env         map[string]string     // Environment variables templates may read as {{env.NAME}}
+	Tokens      *Tokens               // Component tokens shared by the pages of a build, nil caches within the page only
+	StrictVars  bool                  // Report undefined variables as errors instead of warnings
+}
+
 type Processor struct {
-	registry   *component.Registry
-	assets     *assets.Manager
-	vars       map[string][]string
-	errLines   []processError
-	hasStyles  bool
-	hasScripts bool
+	registry  *component.Registry
+	used      map[string]bool // components rendered on this page; the registry may be shared across pages
+	assets    *assets.Manager
+	opts      Options
+	vars      map[string][]string
+	meta      map[string]string // Front matter of the page being processed
+	filters   map[string]FilterFunc
+	errLines  []ProcessError
+	warnings  []ProcessError
+	hasHead   bool
+	hasStyles bool // a {{styles}} was rendered, so later ones get nothing
+}
+
+// Markers the processor leaves in a page where asset tags go, as those are known once the page is rendered
+// A script marker holds the number of scripts the page had when it was rendered, as in <!--wf:script:2-->.
+const (
+	markerPrefix  = "<!--wf:"
+	stylesMarker  = markerPrefix + "styles-->"
+	scriptMarkerF = markerPrefix + "script:%d-->"
+)
+
+// assetMarker is an asset marker found in a rendered page
+type assetMarker struct {
+	start, end int
+	script     bool
+	mark       int // script count of a script marker
+}
+
+// findMarkers returns the asset markers of a rendered page in order
+func findMarkers(html []byte) []assetMarker {
+	var markers []assetMarker
+	for from := 0; ; {
+		i := bytes.Index(html[from:], []byte(markerPrefix))
+		if i == -1 {
+			return markers
+		}
+		start := from + i
+		end := bytes.Index(html[start:], []byte("-->"))
+		if end == -1 {
+			return markers
+		}
+		end += start + len("-->")
+		from = start + len(markerPrefix)
+
+		marker := assetMarker{start: start, end: end}
+		if string(html[start:end]) != stylesMarker {
+			if _, err := fmt.Sscanf(string(html[start:end]), scriptMarkerF, &marker.mark); err != nil {
+				continue
+			}
+			marker.script = true
+		}
+		markers = append(markers, marker)
+		from = end
+	}
+}
+
+// assemble copies a rendered page into its final form in one pass, putting the asset tags at the markers
+// The before tags and the stylesheet go at the first {{styles}}, later ones render nothing. Each {{script}}
+// gets the scripts of the components rendered before it that no earlier {{script}} got, and the last one
+// also those of components rendered after it. Without markers, the before tags and the stylesheet start
+// the page and the scripts end it.
+func (p *Processor) assemble(html []byte, before, stylesTag string) []byte {
+	markers := findMarkers(html)
+	styles, lastScript := false, -1
+	for i, marker := range markers {
+		if marker.script {
+			lastScript = i
+		} else {
+			styles = true
+		}
+	}
+
+	var out bytes.Buffer
+	out.Grow(len(html) + len(before) + len(stylesTag))
+	if !styles {
+		out.WriteString(before)
+		out.WriteString(stylesTag)
+	}
+
+	last, emitted := 0, 0
+	total := p.assets.ScriptCount()
+	for i, marker := range markers {
+		out.Write(html[last:marker.start])
+		last = marker.end
+		if !marker.script {
+			out.WriteString(before)
+			out.WriteString(stylesTag)
+			before, stylesTag = "", ""
+			continue
+		}
+		to := min(max(marker.mark, emitted), total)
+		if i == lastScript {
+			to = total
+		}
+		out.WriteString(p.assets.GetScriptTags(p.opts.AssetPrefix, emitted, to))
+		emitted = to
+	}
+	out.Write(html[last:])
+
+	if lastScript == -1 {
+		out.WriteString(p.assets.GetScriptTags(p.opts.AssetPrefix, 0, total))
+	}
+	return out.Bytes()
 }
 
-type processError struct {
-	line      int
-	directive string
-	msg       string
+// ProcessError is a single problem found while processing a template
+type ProcessError struct {
+	Component string // Component whose template contains the problem, empty for blueprint-level problems
+	Line      int    // 1-based template line, 0 when unknown
+	Column    int    // 1-based template column, 0 when unknown
+	Directive string
+	Msg       string
 }
 
-func New(registry *component.Registry) *Processor {
-	return &Processor{
+func (e ProcessError) Error() string {
+	loc := fmt.Sprintf("line %d", e.Line)
+	if e.Column > 0 {
+		loc += fmt.Sprintf(":%d", e.Column)
+	}
+	if e.Component != "" {
+		loc = e.Component + " " + loc
+	}
+	return fmt.Sprintf("%s [%s]: %s", loc, e.Directive, e.Msg)
+}
+
+// ProcessErrors collects all problems found while processing a page
+type ProcessErrors []ProcessError
+
+func (e ProcessErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("template processing errors: %s", strings.Join(msgs, "; "))
+}
+
+// New creates a Processor whose templates see opts.Globals as the lowest-precedence variables
+func New(registry *component.Registry, opts Options) *Processor {
+	p := &Processor{
 		registry: registry,
-		assets:   assets.New(),
-		vars:     make(map[string][]string),
-		errLines: make([]processError, 0),
+		used:     make(map[string]bool),
+		assets:   assets.New(opts.Assets),
+		filters:  make(map[string]FilterFunc),
+		errLines: make([]ProcessError, 0),
 	}
+	p.Reset(opts)
+	return p
+}
+
+// Reset prepares the processor for another page with opts, reusing its allocations
+// Nothing of the previous page is kept: variables, front matter, errors, warnings, placed directives,
+// and collected assets all start empty. The registry stays, so the pages must be of the same build.
+func (p *Processor) Reset(opts Options) {
+	globals := opts.Globals
+	if globals == nil {
+		globals = make(map[string][]string)
+	}
+
+	clear(p.filters)
+	maps.Copy(p.filters, builtinFilters)
+	maps.Copy(p.filters, opts.Filters)
+
+	if opts.Tokens == nil {
+		opts.Tokens = NewTokens()
+	}
+
+	clear(p.used)
+	p.assets.Reset(opts.Assets)
+	p.opts = opts
+	p.vars = globals
+	p.meta = nil
+	p.errLines = p.errLines[:0]
+	p.warnings = p.warnings[:0]
+	p.hasHead = false
+	p.hasStyles = false
 }
 
 // Process handles template processing from root node
 func (p *Processor) Process(node *blueprint.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	err := p.ProcessTo(&buf, node)
+	return buf.Bytes(), err
+}
+
+// ProcessTo renders the tree from its root node to w, writing template text and values as they are rendered
+// Only a component whose complete output is rewritten, by Markdown, image references, scoping, or its block's
+// id and class, is rendered aside first. Asset tags, and the markers {{styles}} and {{script}} leave for them,
+// are left for Assemble, which needs the complete page. Template problems are returned as ProcessErrors after
+// the whole tree is written.
+func (p *Processor) ProcessTo(w io.Writer, node *blueprint.Node) error {
 	if node == nil {
-		return nil, nil
+		return nil
+	}
+
+	out := &pageWriter{w: w}
+	p.process(out, node, p.vars)
+	if out.err != nil {
+		return fmt.Errorf("writing output: %w", out.err)
+	}
+
+	if len(p.errLines) > 0 {
+		errs := make(ProcessErrors, len(p.errLines))
+		copy(errs, p.errLines)
+		return errs
+	}
+
+	return nil
+}
+
+// pageWriter passes rendered output on to the writer of ProcessTo, keeping the first write error
+// Rendering goes on after a failed write so the template problems of the whole page are still reported.
+type pageWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (pw *pageWriter) Write(b []byte) (int, error) {
+	if pw.err == nil && len(b) > 0 {
+		_, pw.err = pw.w.Write(b)
 	}
+	return len(b), nil
+}
 
-	var output []byte
+func (pw *pageWriter) WriteString(s string) (int, error) {
+	if pw.err == nil && len(s) > 0 {
+		_, pw.err = io.WriteString(pw.w, s)
+	}
+	return len(s), nil
+}
 
+// process renders a node to w with the variable scope inherited from its ancestors
+func (p *Processor) process(w io.Writer, node *blueprint.Node, scope map[string][]string) {
 	// Process root's children as it's a virtual node
 	if node.Block.ID == -1 {
-		output = p.processChildren(node)
-	} else {
-		comp := p.registry.Get(node.Block.Path)
-		if comp == nil {
-			p.addError(0, node.Block.Path, fmt.Sprintf("component not found: %s", node.Block.Path))
-			return []byte(fmt.Sprintf("{{%s}}", node.Block.Path)), nil
-		}
+		p.meta = node.Meta
+		p.processChildren(w, node.Children, scope)
+		return
+	}
 
-		// Process html and assets
-		p.processAssets(comp, node.Block.Path)
-		output = p.processTemplate(comp.Template, node.Block.Vars, node.Children)
+	comp := p.registry.Get(node.Block.Path)
+	if comp == nil {
+		p.addError(ProcessError{
+			Directive: node.Block.Path,
+			Msg:       fmt.Sprintf("component not found: %s", node.Block.Path),
+		})
+		fmt.Fprintf(w, "{{%s}}", node.Block.Path)
+		return
 	}
 
-	if len(p.errLines) > 0 {
-		var msgs []string
-		for _, err := range p.errLines {
-			msgs = append(msgs, fmt.Sprintf("line %d [%s]: %s", err.line, err.directive, err.msg))
+	// A guarded block and its children are left out while the guard variable is unset or falsy
+	vars := inherit(scope, node.Block.Vars)
+	if node.Block.When != "" && !truthy(vars[node.Block.When]) {
+		return
+	}
+
+	// Process html and assets
+	p.used[comp.Path] = true
+	p.processAssets(comp, node.Block.Path)
+	if node.Block.Repeat == 0 {
+		p.renderBlock(w, comp, node.Block, vars, node.Children)
+		return
+	}
+
+	// A repeated block renders with its children once per repetition, each seeing the repetition index
+	vars = maps.Clone(vars)
+	for i := range node.Block.Repeat {
+		vars[blueprint.RepeatVar] = []string{strconv.Itoa(i)}
+		p.renderBlock(w, comp, node.Block, vars, node.Children)
+	}
+}
+
+// renderBlock renders a block's component to w, first rendering it aside when the block sets an id or class
+func (p *Processor) renderBlock(w io.Writer, comp *component.Component, block blueprint.Block, vars map[string][]string, children []*blueprint.Node) {
+	if block.HTMLID == "" && block.Class == "" {
+		p.renderComponent(w, comp, vars, children)
+		return
+	}
+
+	var buf bytes.Buffer
+	p.renderComponent(&buf, comp, vars, children)
+	w.Write(p.applyBlockAttrs(comp, block, buf.Bytes()))
+}
+
+// applyBlockAttrs gives a rendered block's root element the id and classes set in its blueprint
+func (p *Processor) applyBlockAttrs(comp *component.Component, block blueprint.Block, output []byte) []byte {
+	var ok bool
+	if block.HTMLID != "" {
+		if output, ok = setRootID(output, block.HTMLID); !ok {
+			p.addError(ProcessError{
+				Component: comp.Path,
+				Directive: blueprint.IDVar,
+				Msg:       "no root element to set the block's id on",
+			})
 		}
-		return output, fmt.Errorf("template processing errors: %s", strings.Join(msgs, "; "))
 	}
+	if block.Class != "" {
+		if output, ok = addRootClass(output, block.Class); !ok {
+			p.addError(ProcessError{
+				Component: comp.Path,
+				Directive: blueprint.ClassVar,
+				Msg:       "no root element to add the block's class to",
+			})
+		}
+	}
+	return output
+}
 
-	return output, nil
+// truthy reports whether a variable's values count as set for a block guard: present, and its first
+// value neither empty, "false", nor "0"
+func truthy(values []string) bool {
+	return len(values) > 0 && truthyValue(values[0])
+}
+
+// inherit returns the variables of a block over those of its ancestors, block values taking precedence
+func inherit(scope, vars map[string][]string) map[string][]string {
+	if len(scope) == 0 {
+		return vars
+	}
+
+	merged := make(map[string][]string, len(scope)+len(vars))
+	for name, values := range scope {
+		merged[name] = values
+	}
+	for name, values := range vars {
+		merged[name] = values
+	}
+	return merged
+}
+
+// Meta returns the front matter of the last processed page
+func (p *Processor) Meta() map[string]string {
+	return p.meta
 }
 
 func (p *Processor) GetUsedComponents() map[string]string {
 	paths := make(map[string]string)
-	p.registry.Each(func(comp *component.Component) {
-		fsPath := strings.ReplaceAll(comp.Path, ".", "/")
-		paths[comp.Path] = fsPath
-	})
+	for path := range p.used {
+		paths[path] = strings.ReplaceAll(path, ".", "/")
+	}
 	return paths
 }
 
@@ -123,116 +420,498 @@ func (p *Processor) GetUsedComponents() map[string]string {
 // 	p.errLines = nil
 // }
 
-func (p *Processor) addError(line int, directive string, msg string) {
-	// Check for duplicate
-	for _, err := range p.errLines {
-		if err.line == line && err.directive == directive {
-			return
+func (p *Processor) addError(e ProcessError) {
+	p.errLines = appendUnique(p.errLines, e)
+}
+
+// appendUnique adds e unless a problem with the same directive at the same position is already listed
+func appendUnique(list []ProcessError, e ProcessError) []ProcessError {
+	for _, err := range list {
+		if err.Component == e.Component && err.Line == e.Line && err.Column == e.Column && err.Directive == e.Directive {
+			return list
 		}
 	}
-	p.errLines = append(p.errLines, processError{
-		line:      line,
-		directive: directive,
-		msg:       msg,
+	return append(list, e)
+}
+
+// addTokenWarning records a problem that does not fail processing at a token's position
+func (p *Processor) addTokenWarning(comp *component.Component, token Token, directive string, msg string) {
+	p.warnings = appendUnique(p.warnings, ProcessError{
+		Component: comp.Path,
+		Line:      token.Line,
+		Column:    token.Column,
+		Directive: directive,
+		Msg:       msg,
 	})
 }
 
+// undefinedVar reports a directive using an undefined variable, as an error with StrictVars
+func (p *Processor) undefinedVar(comp *component.Component, token Token, directive string, msg string) {
+	if p.opts.StrictVars {
+		p.addTokenError(comp, token, directive, msg)
+	} else {
+		p.addTokenWarning(comp, token, directive, msg)
+	}
+}
+
+// Warnings returns the problems found so far that did not fail processing
+func (p *Processor) Warnings() ProcessErrors {
+	warnings := make(ProcessErrors, len(p.warnings))
+	copy(warnings, p.warnings)
+	return warnings
+}
+
+// addTokenError records a problem at a token's position in a component template
+func (p *Processor) addTokenError(comp *component.Component, token Token, directive string, msg string) {
+	p.addError(ProcessError{
+		Component: comp.Path,
+		Line:      token.Line,
+		Column:    token.Column,
+		Directive: directive,
+		Msg:       msg,
+	})
+}
+
+// processAssets collects a component's assets for the page as it is rendered
+// Components left out by a block guard, an {{if}} branch not taken, an empty {{range}}, or a slot no
+// template places are loaded but never rendered, so none of their CSS, scripts, or images reach the page.
 func (p *Processor) processAssets(comp *component.Component, path string) {
 	if err := p.assets.ProcessComponent(comp); err != nil {
-		p.addError(0, path, fmt.Sprintf("asset error in %s: %v", path, err))
+		p.addError(ProcessError{
+			Component: path,
+			Directive: path,
+			Msg:       fmt.Sprintf("asset error in %s: %v", path, err),
+		})
 	}
 }
 
-// processChildren handles child components recursively
-func (p *Processor) processChildren(node *blueprint.Node) []byte {
-	var buf bytes.Buffer
-	for _, child := range node.Children {
-		childContent, _ := p.Process(child)
-		if len(childContent) > 0 {
-			buf.Write(childContent)
+// processChildren handles child components recursively, passing them the parent's variable scope
+func (p *Processor) processChildren(w io.Writer, children []*blueprint.Node, scope map[string][]string) {
+	for _, child := range children {
+		p.process(w, child, scope)
+	}
+}
+
+// processChild renders the single child selected by a {{component N}} directive, N counting from 0
+func (p *Processor) processChild(w io.Writer, comp *component.Component, token Token, children []*blueprint.Node, scope map[string][]string) {
+	directive := "component " + strings.Join(token.Args, " ")
+	if len(token.Args) != 1 {
+		p.addTokenError(comp, token, directive, "expected a single child index")
+		return
+	}
+
+	index, err := strconv.Atoi(token.Args[0])
+	if err != nil {
+		p.addTokenError(comp, token, directive, fmt.Sprintf("invalid child index %q", token.Args[0]))
+		return
+	}
+	if index < 0 || index >= len(children) {
+		p.addTokenError(comp, token, directive,
+			fmt.Sprintf("child index %d out of range, block has %d children", index, len(children)))
+		return
+	}
+
+	p.process(w, children[index], scope)
+}
+
+// placedChildren returns the positions of the children an indexed {{component N}} renders
+func placedChildren(tokens []Token, children []*blueprint.Node) map[int]bool {
+	placed := make(map[int]bool)
+	for _, token := range tokens {
+		if token.Type != ComponentToken || len(token.Args) != 1 {
+			continue
+		}
+		if index, err := strconv.Atoi(token.Args[0]); err == nil && index >= 0 && index < len(children) {
+			placed[index] = true
+		}
+	}
+	return placed
+}
+
+// unplaced returns the children a plain {{component}} renders: those in the default slot that are not
+// placed by index elsewhere
+func unplaced(children []*blueprint.Node, placed map[int]bool) []*blueprint.Node {
+	rest := make([]*blueprint.Node, 0, len(children))
+	for i, child := range children {
+		if !placed[i] && child.Block.Slot == "" {
+			rest = append(rest, child)
+		}
+	}
+	return rest
+}
+
+// slotted returns the children assigned to a named slot, in order
+func slotted(children []*blueprint.Node, slot string) []*blueprint.Node {
+	var matched []*blueprint.Node
+	for _, child := range children {
+		if child.Block.Slot == slot {
+			matched = append(matched, child)
 		}
 	}
-	return buf.Bytes()
+	return matched
 }
 
-// processTemplate handles template substitution
-func (p *Processor) processTemplate(tmpl []byte, vars map[string][]string, children []*blueprint.Node) []byte {
-	tokenizer := NewTokenizer(tmpl)
-	tokens := tokenizer.Tokenize()
+// processInclude renders an included component with the including template's variables
+func (p *Processor) processInclude(w io.Writer, from *component.Component, token Token, vars map[string][]string) {
+	comp := p.registry.Get(token.Content)
+	if comp == nil {
+		p.addTokenError(from, token, "include "+token.Content,
+			fmt.Sprintf("included component not found: %s", token.Content))
+		return
+	}
+
+	p.used[comp.Path] = true
+	p.processAssets(comp, token.Content)
+	p.renderComponent(w, comp, vars, nil)
+}
+
+// renderComponent processes a component template to w, converting Markdown templates to HTML after substitution
+func (p *Processor) renderComponent(w io.Writer, comp *component.Component, vars map[string][]string, children []*blueprint.Node) {
+	// An empty template is most likely a mistake; its assets are still used. A template of only
+	// directives, such as a wrapper holding {{component}}, is fine.
+	if len(bytes.TrimSpace(comp.Template)) == 0 {
+		p.warnings = appendUnique(p.warnings, ProcessError{
+			Component: comp.Path,
+			Line:      1,
+			Directive: "template",
+			Msg:       "template is empty, the component renders nothing",
+		})
+	}
+
+	// Only output rewritten as a whole is rendered aside
+	if !comp.Markdown && len(comp.Images) == 0 && !assets.Scoped(comp) {
+		p.processTemplate(w, comp, vars, children, nil)
+		return
+	}
 
+	// The HTML of children and includes is kept out of a Markdown template until it is converted
+	var spliced *[][]byte
+	if comp.Markdown {
+		spliced = new([][]byte)
+	}
 	var buf bytes.Buffer
-	var rangeVar string
-	var rangeContent []string
-	inRange := false
-	rangeStart := -1
+	p.processTemplate(&buf, comp, vars, children, spliced)
+	output := buf.Bytes()
+
+	if comp.Markdown {
+		html, err := renderMarkdown(output, *spliced)
+		if err != nil {
+			p.addError(ProcessError{
+				Component: comp.Path,
+				Directive: comp.Path,
+				Msg:       fmt.Sprintf("rendering markdown: %v", err),
+			})
+			w.Write(unsplice(output, *spliced))
+			return
+		}
+		output = html
+	}
 
-	for i, token := range tokens {
+	if len(comp.Images) > 0 {
+		output = imageRefs(output, comp, p.opts.AssetPrefix)
+	}
+
+	// Empty output has nothing to scope
+	if assets.Scoped(comp) && len(bytes.TrimSpace(output)) > 0 {
+		scoped, ok := addRootClass(output, assets.ScopeClass(comp.Path))
+		if !ok {
+			p.addError(ProcessError{
+				Component: comp.Path,
+				Directive: "scope",
+				Msg:       "no root element to add the scope class to",
+			})
+		}
+		output = scoped
+	}
+
+	w.Write(output)
+}
+
+// rangeFrame tracks one open range while a template is rendered
+// A range over several variables iterates them in lockstep.
+type rangeFrame struct {
+	start  int // token index of the range start
+	names  []string
+	values [][]string // values of each name
+	count  int        // number of iterations
+	index  int        // current iteration
+}
+
+// bound returns the current value of a range variable, empty when a padded variable has run out
+func (f *rangeFrame) bound(name string) ([]string, bool) {
+	for k, n := range f.names {
+		if n != name {
+			continue
+		}
+		if f.index < len(f.values[k]) {
+			return f.values[k][f.index : f.index+1], true
+		}
+		return []string{""}, true
+	}
+	return nil, false
+}
+
+// processTemplate handles template substitution, writing the output to w
+// With spliced set, the output of children and includes is kept there and replaced by markers.
+func (p *Processor) processTemplate(w io.Writer, comp *component.Component, vars map[string][]string, children []*blueprint.Node, spliced *[][]byte) {
+	nested := func(render func(w io.Writer)) {
+		if spliced == nil {
+			render(w)
+			return
+		}
+		var buf bytes.Buffer
+		render(&buf)
+		w.Write(splice(spliced, buf.Bytes()))
+	}
+
+	// Ifs are matched first so ifs left open inside an unclosed range are closed before it
+	tokens, branches := p.matchIfs(comp, p.opts.Tokens.get(comp.Path, comp.Template))
+	tokens, ends := p.matchRanges(comp, tokens)
+	placed := placedChildren(tokens, children)
+
+	var frames []*rangeFrame
+
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
 		switch token.Type {
 		case TextToken:
-			if !inRange {
-				buf.WriteString(token.Content)
-			}
+			io.WriteString(w, token.Content)
+
 		case StyleToken:
+			// Markers are replaced with the asset tags once all assets are known
+			if p.hasStyles {
+				p.addTokenWarning(comp, token, "styles", "stylesheet already placed by an earlier {{styles}}, this one renders nothing")
+			}
 			p.hasStyles = true
+			io.WriteString(w, stylesMarker)
 
 		case ScriptToken:
-			p.hasScripts = true
+			fmt.Fprintf(w, scriptMarkerF, p.assets.ScriptCount())
 
+		case HeadToken:
+			p.hasHead = true
+			if p.opts.HeadTags {
+				io.WriteString(w, headTags(p.meta))
+			}
+
+		// Children, slots, and includes see the current values of enclosing ranges like the template does
 		case ComponentToken:
-			if !inRange {
-				for _, child := range children {
-					childContent, _ := p.Process(child)
-					if len(childContent) > 0 {
-						buf.Write(childContent)
-					}
-				}
+			scope := scopeVars(vars, frames)
+			if len(token.Args) == 0 {
+				nested(func(w io.Writer) { p.processChildren(w, unplaced(children, placed), scope) })
+			} else {
+				nested(func(w io.Writer) { p.processChild(w, comp, token, children, scope) })
 			}
 
+		case SlotToken:
+			scope := scopeVars(vars, frames)
+			nested(func(w io.Writer) { p.processChildren(w, slotted(children, token.Content), scope) })
+
+		case ChildCountToken:
+			io.WriteString(w, strconv.Itoa(len(children)))
+
+		case IncludeToken:
+			scope := scopeVars(vars, frames)
+			nested(func(w io.Writer) { p.processInclude(w, comp, token, scope) })
+
 		case RangeStartToken:
-			if !inRange {
-				inRange = true
-				rangeVar = token.Content
-				rangeStart = i
-				// Get array of values for range variable
-				if values, ok := vars[rangeVar]; ok {
-					rangeContent = values
-				}
+			frame := p.newRangeFrame(comp, token, vars, frames)
+			if frame.count == 0 {
+				// Nothing to iterate, skip the whole block
+				i = ends[i]
+				continue
 			}
+			frame.start = i
+			frames = append(frames, frame)
 
 		case RangeEndToken:
-			if inRange {
-				// For each value in range
-				for _, rangeValue := range rangeContent {
-					var rangeBuf bytes.Buffer
-					// Process range block tokens
-					for _, t := range tokens[rangeStart+1 : i] {
-						switch t.Type {
-						case TextToken:
-							rangeBuf.WriteString(t.Content)
-						case VarToken:
-							if t.Content == rangeVar {
-								// Use current iteration value
-								rangeBuf.WriteString(rangeValue)
-							} else if values, exists := vars[t.Content]; exists && len(values) > 0 {
-								// Other vars use first value
-								rangeBuf.WriteString(values[0])
-							}
-						}
-					}
-					buf.Write(rangeBuf.Bytes())
-				}
-				inRange = false
-				rangeStart = -1
+			if _, matched := ends[i]; !matched || len(frames) == 0 {
+				continue
+			}
+			frame := frames[len(frames)-1]
+			frame.index++
+			if frame.index < frame.count {
+				// Next iteration restarts after the range start
+				i = frame.start
+				continue
+			}
+			frames = frames[:len(frames)-1]
+
+		case IfToken:
+			if !p.condition(comp, token, vars, frames) {
+				// Continue after the else, or after the end when there is none
+				i = branches[i]
+			}
+
+		case ElseToken:
+			// Reached from a taken branch, skip the else branch
+			if end, matched := branches[i]; matched {
+				i = end
 			}
 
 		case VarToken:
-			if !inRange {
-				if values, exists := vars[token.Content]; exists {
-					buf.WriteString(values[0])
-				}
+			if !defined(token.Content, vars, frames) && !hasFilter(token.Filters, "default") {
+				p.undefinedVar(comp, token, "."+token.Content,
+					fmt.Sprintf("undefined variable .%s renders empty", token.Content))
+			}
+			p.writeFiltered(w, comp, token, "."+token.Content, lookupVar(token.Content, vars, frames))
+
+		case MetaToken:
+			p.writeFiltered(w, comp, token, "meta."+token.Content, p.meta[token.Content])
+
+		case EnvToken:
+			value, allowed := p.opts.Env[token.Content]
+			if !allowed {
+				p.addTokenError(comp, token, "env."+token.Content,
+					fmt.Sprintf("environment variable %s is not allowed, add it to the env list", token.Content))
+				continue
+			}
+			p.writeFiltered(w, comp, token, "env."+token.Content, value)
+
+		case UnknownToken:
+			if token.Content == "" {
+				p.addTokenError(comp, token, token.Content, "empty directive")
+			} else {
+				p.addTokenError(comp, token, token.Content, fmt.Sprintf("unknown directive {{%s}}", token.Content))
+			}
+		}
+	}
+}
+
+// writeFiltered writes a Var, Meta, or Env value through the token's filters, reporting a failing filter
+func (p *Processor) writeFiltered(w io.Writer, comp *component.Component, token Token, directive string, value string) {
+	value, err := p.applyFilters(value, token.Filters)
+	if err != nil {
+		p.addTokenError(comp, token, directive, err.Error())
+		return
+	}
+	io.WriteString(w, value)
+}
+
+// newRangeFrame resolves the variables of a range directive
+// Several variables iterate together, stopping at the shortest unless the "pad" keyword is given,
+// in which case iteration runs to the longest and exhausted variables are empty.
+func (p *Processor) newRangeFrame(comp *component.Component, token Token, vars map[string][]string, frames []*rangeFrame) *rangeFrame {
+	frame := &rangeFrame{}
+	pad := false
+
+	for _, arg := range token.Args {
+		switch {
+		case arg == "pad":
+			pad = true
+		case strings.HasPrefix(arg, ".") && len(arg) > 1:
+			if !defined(arg[1:], vars, frames) {
+				p.undefinedVar(comp, token, "range "+strings.Join(token.Args, " "),
+					fmt.Sprintf("undefined variable %s has nothing to iterate", arg))
 			}
+			frame.names = append(frame.names, arg[1:])
+			frame.values = append(frame.values, lookupValues(arg[1:], vars, frames))
+		default:
+			p.addTokenError(comp, token, "range "+strings.Join(token.Args, " "),
+				fmt.Sprintf("invalid range argument %q", arg))
+		}
+	}
+
+	for k, values := range frame.values {
+		if k == 0 || (pad && len(values) > frame.count) || (!pad && len(values) < frame.count) {
+			frame.count = len(values)
 		}
 	}
+	return frame
+}
+
+// matchRanges pairs range starts with their ends, recording errors for unmatched ends and unclosed ranges
+// Unclosed ranges are closed at the end of the template. The returned map holds the index of the
+// matching end for every range start and for every matched end itself.
+func (p *Processor) matchRanges(comp *component.Component, tokens []Token) ([]Token, map[int]int) {
+	ends := make(map[int]int)
+	var open []int
 
-	return buf.Bytes()
-}
\ No newline at end of file
+	for i, token := range tokens {
+		switch token.Type {
+		case RangeStartToken:
+			open = append(open, i)
+		case RangeEndToken:
+			if len(open) == 0 {
+				p.addTokenError(comp, token, "range end", "range end without matching range start")
+				continue
+			}
+			start := open[len(open)-1]
+			open = open[:len(open)-1]
+			ends[start] = i
+			ends[i] = i
+		}
+	}
+
+	// Close innermost unclosed ranges first
+	for j := len(open) - 1; j >= 0; j-- {
+		start := tokens[open[j]]
+		p.addTokenError(comp, start, "range "+strings.Join(start.Args, " "),
+			fmt.Sprintf("unclosed range started at line %d", start.Line))
+		end := len(tokens)
+		tokens = append(tokens, Token{Type: RangeEndToken, Line: start.Line, Column: start.Column})
+		ends[open[j]] = end
+		ends[end] = end
+	}
+
+	return tokens, ends
+}
+
+// lookupValues resolves a variable, preferring the current value of an enclosing range over that variable
+func lookupValues(name string, vars map[string][]string, frames []*rangeFrame) []string {
+	for i := len(frames) - 1; i >= 0; i-- {
+		if values, ok := frames[i].bound(name); ok {
+			return values
+		}
+	}
+	return vars[name]
+}
+
+// defined reports whether a variable is bound by an enclosing range or set for the block, its ancestors,
+// or globally; a variable set to an empty value is defined
+func defined(name string, vars map[string][]string, frames []*rangeFrame) bool {
+	for _, frame := range frames {
+		if _, ok := frame.bound(name); ok {
+			return true
+		}
+	}
+	_, ok := vars[name]
+	return ok
+}
+
+// lookupVar resolves the value a variable directive renders
+// Inside a range, a multi-valued variable other than the range variable is read in parallel at the
+// current iteration index and is empty once exhausted; single-valued variables are the same every iteration.
+func lookupVar(name string, vars map[string][]string, frames []*rangeFrame) string {
+	values := lookupValues(name, vars, frames)
+	if len(values) == 0 {
+		return ""
+	}
+
+	if len(frames) > 0 && len(values) > 1 {
+		index := frames[len(frames)-1].index
+		if index >= len(values) {
+			return ""
+		}
+		return values[index]
+	}
+	return values[0]
+}
+
+// scopeVars returns vars with every enclosing range variable bound to its current value
+func scopeVars(vars map[string][]string, frames []*rangeFrame) map[string][]string {
+	if len(frames) == 0 {
+		return vars
+	}
+
+	scoped := make(map[string][]string, len(vars))
+	for name, values := range vars {
+		scoped[name] = values
+	}
+	for _, frame := range frames {
+		for _, name := range frame.names {
+			scoped[name], _ = frame.bound(name)
+		}
+	}
+	return scoped
+}
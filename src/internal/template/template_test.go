@@ -0,0 +1,401 @@
+package template
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"webfactory/src/internal/blueprint"
+	"webfactory/src/internal/component"
+	"webfactory/src/internal/storage"
+)
+
+// renderPage processes a blueprint against components given by their path below components/
+func renderPage(t *testing.T, components map[string]string, content string, opts Options) (*ProcessResult, error) {
+	t.Helper()
+	p, tree := newProcessor(t, components, content, opts)
+	return p.Assembler(tree)
+}
+
+// newProcessor returns a processor over components given by their path below components/, and the parsed
+// blueprint with its components loaded
+func newProcessor(t testing.TB, components map[string]string, content string, opts Options) (*Processor, *blueprint.Node) {
+	t.Helper()
+	registry := newRegistry(components)
+	return New(registry, opts), loadTree(t, registry, content)
+}
+
+// newRegistry returns a registry over components given by their path below components/
+func newRegistry(components map[string]string) *component.Registry {
+	files := make(map[string][]byte, len(components))
+	for name, body := range components {
+		files["components/"+name] = []byte(body)
+	}
+	return component.New(storage.NewMemory(files), component.Options{Includes: Includes})
+}
+
+// loadTree parses a blueprint and loads its components into registry
+func loadTree(t testing.TB, registry *component.Registry, content string) *blueprint.Node {
+	t.Helper()
+	tree, err := blueprint.New(content)
+	if err != nil {
+		t.Fatalf("parsing blueprint: %v", err)
+	}
+	var load func(node *blueprint.Node)
+	load = func(node *blueprint.Node) {
+		if node.Block.ID != -1 {
+			if _, err := registry.Load(node.Block.Path); err != nil {
+				t.Fatalf("loading %s: %v", node.Block.Path, err)
+			}
+		}
+		for _, child := range node.Children {
+			load(child)
+		}
+	}
+	load(tree)
+	return tree
+}
+
+// mustRender is renderPage for pages expected to render, returning their HTML
+func mustRender(t *testing.T, components map[string]string, content string) string {
+	t.Helper()
+	result, err := renderPage(t, components, content, Options{})
+	if err != nil {
+		t.Fatalf("rendering: %v", err)
+	}
+	return string(result.HTML)
+}
+
+func TestMarkdownTemplate(t *testing.T) {
+	components := map[string]string{
+		"post/post.md": "# {{.title}}\n\n- one\n- two\n",
+	}
+	html := mustRender(t, components, "1 post\n  .title=Hello\n")
+
+	for _, want := range []string{"<h1>Hello</h1>", "<ul>\n<li>one</li>\n<li>two</li>\n</ul>"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("output %q does not contain %q", html, want)
+		}
+	}
+}
+
+func TestMarkdownTemplateKeepsChildHTML(t *testing.T) {
+	components := map[string]string{
+		"post/post.md":   "# Post\n\n{{component}}\n\nText with {{include card}} inline.\n",
+		"card/card.html": "<div class=\"card\">\n    <p>Card</p>\n</div>",
+	}
+	html := mustRender(t, components, "1 post\n1.1 card\n")
+
+	if strings.Contains(html, "&lt;") || strings.Contains(html, "<pre>") {
+		t.Fatalf("child HTML was converted as Markdown: %q", html)
+	}
+	if !strings.Contains(html, "<h1>Post</h1>\n<div class=\"card\">\n    <p>Card</p>\n</div>") {
+		t.Errorf("child not placed verbatim after the heading: %q", html)
+	}
+	if !strings.Contains(html, "<p>Text with <div class=\"card\">") {
+		t.Errorf("include not placed inline: %q", html)
+	}
+}
+
+func TestProcessErrors(t *testing.T) {
+	tree, err := blueprint.New("1 missing\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := component.New(storage.NewMemory(nil), component.Options{})
+
+	_, err = New(registry, Options{}).Assembler(tree)
+	var errs ProcessErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("got error %v, want ProcessErrors", err)
+	}
+	want := ProcessError{Directive: "missing", Msg: "component not found: missing"}
+	if len(errs) != 1 || errs[0] != want {
+		t.Errorf("got %+v, want %+v", errs, want)
+	}
+	if n := strings.Count(err.Error(), "processing template"); n != 1 {
+		t.Errorf("error says processing template %d times: %v", n, err)
+	}
+}
+
+func TestInheritedVars(t *testing.T) {
+	components := map[string]string{
+		"outer/outer.html": "<div class=\"{{.theme}}\">{{component}}</div>",
+		"mid/mid.html":     "<section>{{component}}</section>",
+		"leaf/leaf.html":   "<p>{{.theme}}</p>",
+	}
+	tests := []struct {
+		name      string
+		blueprint string
+		want      string
+	}{
+		{"two levels down", "1 outer\n  .theme=dark\n1.1 mid\n1.1.1 leaf\n", `<div class="dark"><section><p>dark</p></section></div>`},
+		{"child override", "1 outer\n  .theme=dark\n1.1 mid\n  .theme=light\n1.1.1 leaf\n", `<div class="dark"><section><p>light</p></section></div>`},
+		{"leaf override", "1 outer\n  .theme=dark\n1.1 mid\n1.1.1 leaf\n  .theme=\n", `<div class="dark"><section><p></p></section></div>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if html := mustRender(t, components, tt.blueprint); html != tt.want {
+				t.Errorf("got %q, want %q", html, tt.want)
+			}
+		})
+	}
+}
+
+func TestRangeScopeReachesChildren(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+	}{
+		{"include", `{{range .items}}{{include "item"}}{{range end}}`},
+		{"component", `{{range .items}}{{component}}{{range end}}`},
+		{"indexed component", `{{range .items}}{{component 0}}{{range end}}`},
+		{"slot", `{{range .items}}{{slot body}}{{range end}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blueprint := "1 list\n  .items=a\n  .items=b\n1.1 item\n"
+			if tt.name == "slot" {
+				blueprint += "  .slot=body\n"
+			}
+			html := mustRender(t, map[string]string{
+				"list/list.html": tt.template,
+				"item/item.html": "<li>{{.items}}</li>",
+			}, blueprint)
+			if want := "<li>a</li><li>b</li>"; html != want {
+				t.Errorf("got %q, want %q", html, want)
+			}
+		})
+	}
+}
+
+func TestEscapedBraces(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"variable", `<code>\{{.title\}}</code> = {{.title}}`, "<code>{{.title}}</code> = Docs"},
+		{"range", `\{{range .items\}}\{{range end\}}`, "{{range .items}}{{range end}}"},
+		{"unclosed escape", `\{{ and }}`, "{{ and }}"},
+		{"lone closing", `a \}} b`, "a }} b"},
+		{"escaped then directive", `\{{{{.title}}`, "{{Docs"},
+		{"component", `\{{component\}}`, "{{component}}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html := mustRender(t, map[string]string{"page/page.html": tt.template}, "1 page\n  .title=Docs\n")
+			if html != tt.want {
+				t.Errorf("got %q, want %q", html, tt.want)
+			}
+		})
+	}
+}
+
+func TestEscapedBracesInMarkdown(t *testing.T) {
+	html := mustRender(t, map[string]string{
+		"doc/doc.md": "Write `\\{{.title\\}}` to show {{.title}}.\n",
+	}, "1 doc\n  .title=the title\n")
+	if want := "<p>Write <code>{{.title}}</code> to show the title.</p>\n"; html != want {
+		t.Errorf("got %q, want %q", html, want)
+	}
+}
+
+func TestTrimmedInclude(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"leading", "<ul>\n  {{- include \"ui.btn\"}}\n</ul>", "<ul><b>go</b>\n</ul>"},
+		{"trailing", "<ul>\n  {{include \"ui.btn\" -}}\n</ul>", "<ul>\n  <b>go</b></ul>"},
+		{"both", "<ul>\n  {{- include \"ui.btn\" -}}\n</ul>", "<ul><b>go</b></ul>"},
+		{"in range", "<ul>{{range .items}}\n  {{- include \"ui.btn\" -}}\n{{range end}}</ul>", "<ul><b>go</b><b>go</b></ul>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			components := map[string]string{
+				"page/page.html":  tt.template,
+				"ui/btn/btn.html": "<b>go</b>",
+			}
+			if got := mustRender(t, components, "1 page\n  .items=a\n  .items=b\n"); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIncludeTwice(t *testing.T) {
+	result, err := renderPage(t, map[string]string{
+		"page/page.html":  `{{include "ui.btn"}}|{{include "ui.btn"}}`,
+		"ui/btn/btn.html": "<button>{{.label}}</button>",
+		"ui/btn/btn.css":  ".btn { color: red; }",
+	}, "1 page\n  .label=Go\n", Options{})
+	if err != nil {
+		t.Fatalf("rendering: %v", err)
+	}
+	if html := string(result.HTML); !strings.HasSuffix(html, "<button>Go</button>|<button>Go</button>") {
+		t.Errorf("include not rendered twice: %q", html)
+	}
+	if n := strings.Count(string(result.Files["styles.css"]), "color: red"); n != 1 {
+		t.Errorf("included stylesheet added %d times, want once", n)
+	}
+}
+
+func TestIncludeMissing(t *testing.T) {
+	registry := component.New(storage.NewMemory(map[string][]byte{
+		"components/page/page.html": []byte(`<p>{{include "ui.nope"}}</p>`),
+	}), component.Options{Includes: Includes})
+
+	_, err := registry.Load("page")
+	var notFound *component.NotFoundError
+	if !errors.As(err, &notFound) || notFound.Path != "ui.nope" {
+		t.Fatalf("got error %v, want the missing include ui.nope", err)
+	}
+	if !strings.HasPrefix(err.Error(), "loading include ui.nope: ") {
+		t.Errorf("error %q does not name the include", err)
+	}
+}
+
+func TestComments(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"single line", "<p>{{!-- note --}}text</p>", "<p>text</p>"},
+		{"multi-line", "<p>a{{!-- first\n  second\n--}}b</p>", "<p>ab</p>"},
+		{"hides directives", "{{!-- {{.x}} {{range .items}} {{include \"ui.nope\"}} --}}{{.x}}", "shown"},
+		{"empty", "a{{!----}}b", "ab"},
+		{"unclosed is text", "a {{!-- b", "a {{!-- b"},
+		{"dashes inside", "a{{!-- -- not the end -}} --}}b", "ab"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := renderPage(t, map[string]string{"page/page.html": tt.template}, "1 page\n  .x=shown\n", Options{})
+			if err != nil {
+				t.Fatalf("rendering: %v", err)
+			}
+			if html := string(result.HTML); html != tt.want {
+				t.Errorf("got %q, want %q", html, tt.want)
+			}
+			if len(result.Warnings) != 0 {
+				t.Errorf("comment produced warnings %v", result.Warnings)
+			}
+		})
+	}
+}
+
+func TestCommentKeepsLineNumbers(t *testing.T) {
+	_, err := renderPage(t, map[string]string{"page/page.html": "{{!-- one\ntwo\n--}} {{bogus}}"}, "1 page\n", Options{})
+	var errs ProcessErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Line != 3 || errs[0].Column != 6 {
+		t.Errorf("got error %v, want {{bogus}} at line 3:6", err)
+	}
+}
+
+func TestEmptyTemplate(t *testing.T) {
+	for _, template := range []string{"", " \n\t\n"} {
+		result, err := renderPage(t, map[string]string{
+			"page/page.html":   "<main>{{component}}</main>",
+			"empty/empty.html": template,
+			"empty/empty.css":  ".empty { margin: 0; }",
+		}, "1 page\n1.1 empty\n1.2 empty\n", Options{})
+		if err != nil {
+			t.Fatalf("rendering: %v", err)
+		}
+
+		want := []ProcessError{{Component: "empty", Line: 1, Directive: "template", Msg: "template is empty, the component renders nothing"}}
+		if len(result.Warnings) != 1 || result.Warnings[0] != want[0] {
+			t.Errorf("template %q: got warnings %v, want %v once", template, result.Warnings, want)
+		}
+		if !strings.Contains(string(result.Files["styles.css"]), ".empty { margin: 0; }") {
+			t.Errorf("template %q: stylesheet of the empty component left out", template)
+		}
+	}
+}
+
+func TestDirectiveOnlyTemplate(t *testing.T) {
+	tests := []struct {
+		name      string
+		blueprint string
+		want      string
+	}{
+		{"wraps children", "1 wrap\n1.1 text\n  .body=a\n1.2 text\n  .body=b\n", `<p class="wf-wrap">a</p><p>b</p>`},
+		{"renders nothing", "1 wrap\n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := renderPage(t, map[string]string{
+				"wrap/wrap.html":      "{{component}}",
+				"wrap/component.meta": "scope = true",
+				"text/text.html":      "<p>{{.body}}</p>",
+			}, tt.blueprint, Options{})
+			if err != nil {
+				t.Fatalf("rendering: %v", err)
+			}
+			if html := string(result.HTML); html != tt.want {
+				t.Errorf("got %q, want %q", html, tt.want)
+			}
+			if len(result.Warnings) != 0 {
+				t.Errorf("got warnings %v, want none", result.Warnings)
+			}
+		})
+	}
+}
+
+func TestCRLFAttributeValue(t *testing.T) {
+	html := mustRender(t, map[string]string{
+		"link/link.html": `<a data-size="{{.size}}" href="{{.href}}">{{.label}}</a>`,
+	}, "1 link\r\n  .size=big\r\n  .href=/docs/\r\n  .label=Docs\r\n")
+	if want := `<a data-size="big" href="/docs/">Docs</a>`; html != want {
+		t.Errorf("got %q, want %q", html, want)
+	}
+}
+
+func TestSkippedComponentAssets(t *testing.T) {
+	components := map[string]string{
+		"page/page.html":   `{{component}}{{if .promo}}{{include "promo"}}{{if end}}`,
+		"card/card.html":   "<div></div>",
+		"card/card.css":    ".card { margin: 0; }",
+		"promo/promo.html": "<aside></aside>",
+		"promo/promo.css":  ".promo { color: red; }",
+		"promo/promo.js":   "console.log('promo')",
+		"ad/ad.html":       "<ins></ins>",
+		"ad/ad.css":        ".ad { color: blue; }",
+	}
+	tests := []struct {
+		name      string
+		blueprint string
+		skipped   string
+	}{
+		{"when guard", "1 page\n  .promo=\n1.1 card\n1.2 ad\n  .when=promo\n", "ad"},
+		{"if branch", "1 page\n  .promo=\n1.1 card\n", "promo"},
+		{"unplaced slot", "1 page\n  .promo=\n1.1 card\n1.2 ad\n  .slot=sidebar\n", "ad"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := renderPage(t, components, tt.blueprint, Options{})
+			if err != nil {
+				t.Fatalf("rendering: %v", err)
+			}
+			css := string(result.Files["styles.css"])
+			if !strings.Contains(css, ".card { margin: 0; }") {
+				t.Errorf("rendered card's stylesheet missing:\n%s", css)
+			}
+			if _, used := result.Components[tt.skipped]; used || strings.Contains(css, "."+tt.skipped+" {") {
+				t.Errorf("skipped %s contributed assets: components %q, stylesheet\n%s", tt.skipped, result.Components, css)
+			}
+			if _, ok := result.Files["promo-promo.js"]; ok {
+				t.Error("skipped promo's script written")
+			}
+		})
+	}
+}
\ No newline at end of file
@@ -2,6 +2,7 @@ package template
 
 import (
 	"bytes"
+	"slices"
 	"strings"
 )
 
@@ -15,95 +16,259 @@ const (
 	ComponentToken
 	StyleToken
 	ScriptToken
+	IncludeToken
+	UnknownToken
+	MetaToken
+	HeadToken
+	ChildCountToken
+	SlotToken
+	EnvToken
+	IfToken
+	ElseToken
+	IfEndToken
 )
 
 type Token struct {
 	Type    TokenType
-	Content string // Variable name for Var/Range, raw content for Text
+	Content string   // Variable name for Var/Range/Env, key for Meta, component path for Include, slot name for Slot, condition for If, directive for Unknown, raw content for Text
+	Args    []string // Directive arguments, e.g. every ".var" and keyword of a range, or the words of an if condition
+	Filters []Filter // Filters piped after a Var, Meta, or Env value, applied in order
+	Line    int      // 1-based source line where the token begins
+	Column  int      // 1-based source column where the token begins
 }
 
 type Tokenizer struct {
-	template []byte
-	pos      int
+	template []byte // remaining, not yet tokenized input
 	tokens   []Token
+	line     int  // source line of template[0]
+	column   int  // source column of template[0]
+	trimNext bool // the last directive ended with a trim marker, so leading whitespace of the next text is dropped
 }
 
 func NewTokenizer(template []byte) *Tokenizer {
 	return &Tokenizer{
 		template: template,
 		tokens:   make([]Token, 0),
+		line:     1,
+		column:   1,
 	}
 }
 
+// emit appends a token positioned at the start of the remaining template
+func (t *Tokenizer) emit(token Token) {
+	token.Line = t.line
+	token.Column = t.column
+	t.tokens = append(t.tokens, token)
+}
+
+// advance consumes n bytes of the remaining template, tracking the source position
+func (t *Tokenizer) advance(n int) {
+	for _, c := range t.template[:n] {
+		if c == '\n' {
+			t.line++
+			t.column = 1
+		} else if c&0xC0 != 0x80 {
+			// Count runes, not UTF-8 continuation bytes
+			t.column++
+		}
+	}
+	t.template = t.template[n:]
+}
+
+// Tokenize splits the template into text and directive tokens
+// Text before, between, and after directives becomes text tokens, never empty ones; an unclosed
+// "{{" and everything after it is text. A backslash escapes braces: \{{ and \}} are the literal text
+// {{ and }}, so \{{.x\}} shows a directive instead of running it. As in Go templates, "{{- " drops the
+// whitespace before a directive and " -}}" the whitespace after it, newlines included. Comments,
+// {{!-- ... --}}, produce no tokens.
 func (t *Tokenizer) Tokenize() []Token {
-	for t.pos < len(t.template) {
-		if t.template[t.pos] == '{' && t.pos+1 < len(t.template) && t.template[t.pos+1] == '{' {
-			// Handle accumulated text before directive
-			if t.pos > 0 && len(t.template) > 0 {
-				t.tokens = append(t.tokens, Token{
-					Type:    TextToken,
-					Content: string(t.template[0:t.pos]),
-				})
-				t.template = t.template[t.pos:]
-				t.pos = 0
-			}
+	for len(t.template) > 0 {
+		start := bytes.Index(t.template, []byte("{{"))
+		if start == -1 {
+			break
+		}
+
+		// An escaped {{ is literal text, the backslash dropped
+		if start > 0 && t.template[start-1] == '\\' {
+			t.text(start - 1)
+			t.emit(Token{
+				Type:    TextToken,
+				Content: "{{",
+			})
+			t.advance(3)
+			continue
+		}
+
+		// Text before the directive
+		t.text(start)
 
-			// Find directive end
-			end := bytes.Index(t.template[2:], []byte("}}"))
+		// Comments are dropped whole, even across lines or around other directives
+		if bytes.HasPrefix(t.template, []byte("{{!--")) {
+			end := bytes.Index(t.template[5:], []byte("--}}"))
 			if end == -1 {
-				// Malformed template - treat rest as text
-				t.tokens = append(t.tokens, Token{
-					Type:    TextToken,
-					Content: string(t.template),
-				})
 				break
 			}
+			t.advance(end + 9)
+			continue
+		}
 
-			directive := strings.TrimSpace(string(t.template[2 : end+2]))
-
-			switch {
-			case directive == "component":
-				t.tokens = append(t.tokens, Token{
-					Type: ComponentToken,
-				})
-			case directive == "range end":
-				t.tokens = append(t.tokens, Token{
-					Type: RangeEndToken,
-				})
-			case strings.HasPrefix(directive, "range ."):
-				t.tokens = append(t.tokens, Token{
-					Type:    RangeStartToken,
-					Content: strings.TrimPrefix(directive, "range ."),
-				})
-			case directive == "styles":
-				t.tokens = append(t.tokens, Token{
-					Type: StyleToken,
-				})
-			case directive == "script":
-				t.tokens = append(t.tokens, Token{
-					Type: ScriptToken,
-				})
-			case strings.HasPrefix(directive, "."):
-				t.tokens = append(t.tokens, Token{
-					Type:    VarToken,
-					Content: strings.TrimPrefix(directive, "."),
-				})
-			}
+		// Find directive end
+		end := bytes.Index(t.template[2:], []byte("}}"))
+		if end == -1 {
+			break
+		}
 
-			t.template = t.template[end+4:]
-			t.pos = 0
-			continue
+		inner := string(t.template[2 : end+2])
+		trimAfter := false
+		if len(inner) > 1 && inner[0] == '-' && isSpace(inner[1]) {
+			inner = inner[1:]
+			t.trimPrevious()
+		}
+		if n := len(inner); n > 1 && inner[n-1] == '-' && isSpace(inner[n-2]) {
+			inner = inner[:n-1]
+			trimAfter = true
 		}
-		t.pos++
-	}
 
-	// Handle remaining text
-	if len(t.template) > 0 {
-		t.tokens = append(t.tokens, Token{
-			Type:    TextToken,
-			Content: string(t.template),
-		})
+		t.emit(parseDirective(strings.TrimSpace(inner)))
+		t.advance(end + 4)
+		t.trimNext = trimAfter
 	}
 
+	// Text after the last directive
+	t.text(len(t.template))
+
 	return t.tokens
-}
\ No newline at end of file
+}
+
+// Includes returns the paths of the components a template includes, in order of first use
+// Includes are found as the template is tokenized, so trimmed ones count and those in comments or
+// escaped braces don't.
+func Includes(template []byte) []string {
+	var includes []string
+	for _, token := range NewTokenizer(template).Tokenize() {
+		if token.Type == IncludeToken && token.Content != "" && !slices.Contains(includes, token.Content) {
+			includes = append(includes, token.Content)
+		}
+	}
+	return includes
+}
+
+// text emits the next n bytes as a text token, unescaping \}}, and consumes them
+func (t *Tokenizer) text(n int) {
+	if t.trimNext {
+		t.trimNext = false
+		space := n - len(bytes.TrimLeft(t.template[:n], spaceChars))
+		t.advance(space)
+		n -= space
+	}
+	if n == 0 {
+		return
+	}
+	t.emit(Token{
+		Type:    TextToken,
+		Content: strings.ReplaceAll(string(t.template[:n]), `\}}`, "}}"),
+	})
+	t.advance(n)
+}
+
+// parseDirective returns the token for the trimmed content of a {{...}} directive
+func parseDirective(directive string) Token {
+	// Variables, meta, and env values may be piped through filters
+	if strings.Contains(directive, "|") && (strings.HasPrefix(directive, ".") ||
+		strings.HasPrefix(directive, "meta.") || strings.HasPrefix(directive, "env.")) {
+		name, filters, ok := parseFilters(directive)
+		if !ok {
+			return Token{Type: UnknownToken, Content: directive}
+		}
+		token := parseDirective(name)
+		token.Filters = filters
+		return token
+	}
+
+	switch {
+	case directive == "component":
+		return Token{Type: ComponentToken}
+	case strings.HasPrefix(directive, "component "):
+		return Token{
+			Type: ComponentToken,
+			Args: strings.Fields(strings.TrimPrefix(directive, "component ")),
+		}
+	case directive == "range end":
+		return Token{Type: RangeEndToken}
+	case directive == "if end":
+		return Token{Type: IfEndToken}
+	case directive == "else":
+		return Token{Type: ElseToken}
+	case strings.HasPrefix(directive, "if "):
+		condition := strings.TrimSpace(strings.TrimPrefix(directive, "if "))
+		return Token{
+			Type:    IfToken,
+			Content: condition,
+			Args:    strings.Fields(condition),
+		}
+	case strings.HasPrefix(directive, "range ."):
+		args := strings.Fields(strings.TrimPrefix(directive, "range"))
+		return Token{
+			Type:    RangeStartToken,
+			Content: strings.TrimPrefix(args[0], "."),
+			Args:    args,
+		}
+	case strings.HasPrefix(directive, "slot "):
+		return Token{
+			Type:    SlotToken,
+			Content: strings.TrimSpace(strings.TrimPrefix(directive, "slot ")),
+		}
+	case directive == "children.count":
+		return Token{Type: ChildCountToken}
+	case directive == "head":
+		return Token{Type: HeadToken}
+	case directive == "styles":
+		return Token{Type: StyleToken}
+	case directive == "script":
+		return Token{Type: ScriptToken}
+	case strings.HasPrefix(directive, "include "):
+		return Token{
+			Type:    IncludeToken,
+			Content: strings.Trim(strings.TrimSpace(strings.TrimPrefix(directive, "include ")), `"`),
+		}
+	case strings.HasPrefix(directive, "meta."):
+		return Token{
+			Type:    MetaToken,
+			Content: strings.TrimPrefix(directive, "meta."),
+		}
+	case strings.HasPrefix(directive, "env."):
+		return Token{
+			Type:    EnvToken,
+			Content: strings.TrimPrefix(directive, "env."),
+		}
+	case strings.HasPrefix(directive, "."):
+		return Token{
+			Type:    VarToken,
+			Content: strings.TrimPrefix(directive, "."),
+		}
+	default:
+		return Token{
+			Type:    UnknownToken,
+			Content: directive,
+		}
+	}
+}
+
+// trimPrevious drops the trailing whitespace of the text token before a directive with a trim marker
+func (t *Tokenizer) trimPrevious() {
+	last := len(t.tokens) - 1
+	if last < 0 || t.tokens[last].Type != TextToken {
+		return
+	}
+	t.tokens[last].Content = strings.TrimRight(t.tokens[last].Content, spaceChars)
+	if t.tokens[last].Content == "" {
+		t.tokens = t.tokens[:last]
+	}
+}
+
+// spaceChars is the whitespace removed by trim markers
+const spaceChars = " \t\r\n"
+
+func isSpace(c byte) bool {
+	return strings.IndexByte(spaceChars, c) != -1
+}
@@ -0,0 +1,77 @@
+package template
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// describe lists tokens as "content@line:column", text quoted and directives by their type's name
+func describe(tokens []Token) []string {
+	names := map[TokenType]string{VarToken: "var", ComponentToken: "component", RangeStartToken: "range", RangeEndToken: "range end", UnknownToken: "unknown"}
+	var out []string
+	for _, token := range tokens {
+		content := fmt.Sprintf("%q", token.Content)
+		if token.Type != TextToken {
+			content = names[token.Type] + " " + token.Content
+		}
+		out = append(out, fmt.Sprintf("%s@%d:%d", strings.TrimSpace(content), token.Line, token.Column))
+	}
+	return out
+}
+
+func TestTokenizePositions(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     []string
+	}{
+		{"single directive", "{{.x}}", []string{"var x@1:1"}},
+		{"leading directive", "{{.x}} after", []string{"var x@1:1", `" after"@1:7`}},
+		{"trailing directive", "before {{.x}}", []string{`"before "@1:1`, "var x@1:8"}},
+		{"starts and ends with directives", "{{.a}} mid {{.b}}", []string{"var a@1:1", `" mid "@1:7`, "var b@1:12"}},
+		{"consecutive", "{{.a}}{{.b}}{{component}}", []string{"var a@1:1", "var b@1:7", "component@1:13"}},
+		{"text between lines", "<p>\n  {{.a}}\n</p>{{.b}}", []string{`"<p>\n  "@1:1`, "var a@2:3", `"\n</p>"@2:9`, "var b@3:5"}},
+		{"multi-byte text", "é {{.a}}", []string{`"é "@1:1`, "var a@1:3"}},
+		{"no directives", "plain", []string{`"plain"@1:1`}},
+		{"empty", "", nil},
+		{"unclosed", "a {{.x} b", []string{`"a "@1:1`, `"{{.x} b"@1:3`}},
+		{"unclosed after directive", "{{.a}}{{.b", []string{"var a@1:1", `"{{.b"@1:7`}},
+		{"unknown", "{{nope}}!", []string{"unknown nope@1:1", `"!"@1:9`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := describe(NewTokenizer([]byte(tt.template)).Tokenize()); !slices.Equal(got, tt.want) {
+				t.Errorf("Tokenize(%q)\ngot  %q\nwant %q", tt.template, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIncludes(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     []string
+	}{
+		{"plain", `{{include "ui.btn"}}`, []string{"ui.btn"}},
+		{"unquoted", `{{include ui.btn}}`, []string{"ui.btn"}},
+		{"leading trim", `a {{- include "ui.btn"}}`, []string{"ui.btn"}},
+		{"trailing trim", `{{include "ui.btn" -}} b`, []string{"ui.btn"}},
+		{"both trims", `{{- include "ui.btn" -}}`, []string{"ui.btn"}},
+		{"in range", `{{range .items}}{{- include "ui.item" -}}{{range end}}`, []string{"ui.item"}},
+		{"repeated", `{{include a}}{{include b}}{{include a}}`, []string{"a", "b"}},
+		{"in comment", `{{!-- {{include "ui.old"}} --}}`, nil},
+		{"escaped", `\{{include "ui.btn"\}}`, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Includes([]byte(tt.template)); !slices.Equal(got, tt.want) {
+				t.Errorf("Includes(%q) = %q, want %q", tt.template, got, tt.want)
+			}
+		})
+	}
+}
\ No newline at end of file
@@ -0,0 +1,33 @@
+package template
+
+import (
+	"slices"
+	"sync"
+)
+
+// Tokens caches the tokenized templates of components by component path
+// Components don't change during a build, so sharing Tokens across the pages of a build tokenizes each
+// template once. It is safe for concurrent use; cached tokens are never modified.
+type Tokens struct {
+	mu     sync.Mutex
+	tokens map[string][]Token // component path -> tokens of its template
+}
+
+// NewTokens creates an empty token cache
+func NewTokens() *Tokens {
+	return &Tokens{tokens: make(map[string][]Token)}
+}
+
+// get returns the tokens of a component's template, tokenizing it on first use
+// The slice is clipped to its length, so appending to it copies instead of writing into the cache.
+func (t *Tokens) get(path string, template []byte) []Token {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tokens, ok := t.tokens[path]
+	if !ok {
+		tokens = slices.Clip(NewTokenizer(template).Tokenize())
+		t.tokens[path] = tokens
+	}
+	return tokens
+}
@@ -0,0 +1,99 @@
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// cardList is a page rendering the card component once per item
+var cardList = map[string]string{
+	"list/list.html": `<ul>{{range .items}}{{include "card"}}{{range end}}</ul>`,
+	"card/card.html": `<li class="{{.kind}}">{{if .items == "b"}}<b>{{.items | upper}}</b>{{else}}{{.items}}{{if end}}</li>`,
+}
+
+// cardListBlueprint returns a blueprint listing n items
+func cardListBlueprint(n int) string {
+	var b strings.Builder
+	b.WriteString("1 list\n  .kind=card\n")
+	for i := range n {
+		fmt.Fprintf(&b, "  .items=%c\n", 'a'+i%26)
+	}
+	return b.String()
+}
+
+func TestTokensCached(t *testing.T) {
+	tokens := NewTokens()
+	first := tokens.get("card", []byte("<p>{{.x}}</p>"))
+	if again := tokens.get("card", []byte("changed")); &again[0] != &first[0] {
+		t.Error("template tokenized again for the same component")
+	}
+	if other := tokens.get("other", []byte("<p>{{.x}}</p>")); &other[0] == &first[0] {
+		t.Error("another component got the cached tokens")
+	}
+	if grown := append(first, Token{}); &grown[0] == &first[0] {
+		t.Error("appending to cached tokens writes into the cache")
+	}
+}
+
+func TestTokensConcurrent(t *testing.T) {
+	tokens := NewTokens()
+	want := describe(NewTokenizer([]byte(cardList["card/card.html"])).Tokenize())
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := describe(tokens.get("card", []byte(cardList["card/card.html"]))); strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("got tokens %q, want %q", got, want)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSharedTokensOutput(t *testing.T) {
+	content := cardListBlueprint(5)
+	uncached, err := renderPage(t, cardList, content, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `<ul><li class="card">a</li><li class="card"><b>B</b></li><li class="card">c</li>`; !bytes.HasPrefix(uncached.HTML, []byte(want)) {
+		t.Fatalf("got %s, want it to start with %s", uncached.HTML, want)
+	}
+
+	shared := NewTokens()
+	for page := range 3 {
+		cached, err := renderPage(t, cardList, content, Options{Tokens: shared})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(cached.HTML, uncached.HTML) {
+			t.Errorf("page %d with shared tokens\n%s\nwithout\n%s", page, cached.HTML, uncached.HTML)
+		}
+	}
+}
+
+func BenchmarkRepeatedComponent(b *testing.B) {
+	p, tree := newProcessor(b, cardList, cardListBlueprint(100), Options{})
+	registry := p.registry
+
+	b.Run("shared tokens", func(b *testing.B) {
+		tokens := NewTokens()
+		for range b.N {
+			if _, err := New(registry, Options{Tokens: tokens}).Assembler(tree); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("tokens per page", func(b *testing.B) {
+		for range b.N {
+			if _, err := New(registry, Options{}).Assembler(tree); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,96 @@
+package template
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUndefinedVarWarnings(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     []ProcessError
+	}{
+		{
+			name:     "undefined",
+			template: "<h2>{{.subtitle}}</h2>",
+			want:     []ProcessError{{Line: 1, Column: 5, Directive: ".subtitle", Msg: "undefined variable .subtitle renders empty"}},
+		},
+		{
+			name:     "satisfied by default",
+			template: `<h2>{{.subtitle | default "None"}}</h2>`,
+		},
+		{
+			name:     "defined",
+			template: "<h1>{{.title}}</h1>",
+		},
+		{
+			name:     "tested by if",
+			template: "{{if .subtitle}}<h2>{{.subtitle}}</h2>{{if end}}",
+			want:     []ProcessError{{Line: 1, Column: 1, Directive: "if .subtitle", Msg: "undefined variable .subtitle tests as empty"}},
+		},
+		{
+			name:     "compared by if",
+			template: `{{if .mode == "dark"}}dark{{if end}}`,
+			want:     []ProcessError{{Line: 1, Column: 1, Directive: `if .mode == "dark"`, Msg: "undefined variable .mode tests as empty"}},
+		},
+		{
+			name:     "tested by if exists",
+			template: "{{if .subtitle exists}}<h2>{{.subtitle}}</h2>{{if end}}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := renderPage(t, map[string]string{"page/page.html": tt.template}, "1 page\n  .title=Hi\n", Options{})
+			if err != nil {
+				t.Fatalf("rendering: %v", err)
+			}
+			for i := range tt.want {
+				tt.want[i].Component = "page"
+			}
+			if len(result.Warnings) != len(tt.want) || len(tt.want) > 0 && result.Warnings[0] != tt.want[0] {
+				t.Errorf("got warnings %v, want %v", result.Warnings, tt.want)
+			}
+		})
+	}
+}
+
+func TestStrictVars(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     ProcessErrors
+	}{
+		{
+			name:     "undefined",
+			template: "<h1>{{.title}}</h1>\n<h2>{{.subtitle}}</h2>",
+			want:     ProcessErrors{{Component: "page", Line: 2, Column: 5, Directive: ".subtitle", Msg: "undefined variable .subtitle renders empty"}},
+		},
+		{
+			name:     "tested by if",
+			template: "{{if .subtitle}}x{{if end}}",
+			want:     ProcessErrors{{Component: "page", Line: 1, Column: 1, Directive: "if .subtitle", Msg: "undefined variable .subtitle tests as empty"}},
+		},
+		{name: "satisfied by default", template: `<h2>{{.subtitle | default "None"}}</h2>`},
+		{name: "satisfied by a global", template: "<p>{{.site}}</p>"},
+		{name: "defined", template: "<h1>{{.title}}</h1>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{StrictVars: true, Globals: map[string][]string{"site": {"Docs"}}}
+			result, err := renderPage(t, map[string]string{"page/page.html": tt.template}, "1 page\n  .title=Hi\n", opts)
+			if tt.want == nil {
+				if err != nil || len(result.Warnings) != 0 {
+					t.Errorf("got error %v and warnings %v, want neither", err, result.Warnings)
+				}
+				return
+			}
+			var errs ProcessErrors
+			if !errors.As(err, &errs) || len(errs) != 1 || errs[0] != tt.want[0] {
+				t.Errorf("got error %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package version
+
+import (
+	"fmt"
+	"html"
+)
+
+// Build metadata, stamped at link time with
+// -ldflags "-X webfactory/src/internal/version.Version=... -X ...Commit=... -X ...Date=..."
+var (
+	Version = "dev"
+	Commit  = "unknown"
+	Date    = "unknown"
+)
+
+// String returns the version line for this build
+func String() string {
+	return Format(Version, Commit, Date)
+}
+
+// Format builds a version line from the given metadata
+func Format(version, commit, date string) string {
+	return fmt.Sprintf("webfactory %s (commit %s, built %s)", version, commit, date)
+}
+
+// GeneratorTag returns a meta generator tag identifying this build
+func GeneratorTag() string {
+	return fmt.Sprintf(`<meta name="generator" content="webfactory %s">`, html.EscapeString(Version))
+}
@@ -0,0 +1,24 @@
+package version
+
+import "testing"
+
+func TestFormat(t *testing.T) {
+	got := Format("v1.2.0", "abc1234", "2024-05-01")
+	if want := "webfactory v1.2.0 (commit abc1234, built 2024-05-01)"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestString(t *testing.T) {
+	if got, want := String(), "webfactory dev (commit unknown, built unknown)"; got != want {
+		t.Errorf("unstamped build reports %q, want %q", got, want)
+	}
+}
+
+func TestGeneratorTag(t *testing.T) {
+	defer func(v string) { Version = v }(Version)
+	Version = `1.0"<x>`
+	if got, want := GeneratorTag(), `<meta name="generator" content="webfactory 1.0&#34;&lt;x&gt;">`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,193 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Watcher polls directory trees and reports changes after a quiet period
+type Watcher struct {
+	roots    []string
+	exclude  []string
+	interval time.Duration
+	debounce debounce
+	files    map[string]fileState
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// New creates a Watcher for the roots, ignoring any of the excluded paths
+func New(roots []string, exclude []string, interval, delay time.Duration) *Watcher {
+	w := &Watcher{
+		roots:    roots,
+		exclude:  exclude,
+		interval: interval,
+		debounce: debounce{delay: delay},
+	}
+	w.files = w.scan()
+	return w
+}
+
+// Op describes how a watched file changed
+type Op int
+
+const (
+	Created Op = iota
+	Modified
+	Deleted
+)
+
+func (o Op) String() string {
+	switch o {
+	case Created:
+		return "created"
+	case Modified:
+		return "modified"
+	case Deleted:
+		return "deleted"
+	}
+	return "unknown"
+}
+
+// Change is a single file change reported by the watcher
+type Change struct {
+	Path string
+	Op   Op
+}
+
+// Run polls until stop is closed, calling onChange once per burst of changes with the coalesced changes
+func (w *Watcher) Run(stop <-chan struct{}, onChange func(changes []Change)) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	pending := make(map[string]Op)
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			files := w.scan()
+			changes := diff(w.files, files)
+			w.files = files
+
+			for _, c := range changes {
+				coalesce(pending, c)
+			}
+
+			// A burst whose changes cancel out, like a file created and deleted again, triggers nothing
+			if w.debounce.observe(len(changes) > 0, now) && len(pending) > 0 {
+				batch := make([]Change, 0, len(pending))
+				for path, op := range pending {
+					batch = append(batch, Change{Path: path, Op: op})
+				}
+				sort.Slice(batch, func(i, j int) bool { return batch[i].Path < batch[j].Path })
+				pending = make(map[string]Op)
+				onChange(batch)
+			}
+		}
+	}
+}
+
+// coalesce merges a change into the pending set so each path reports its net change over the burst
+func coalesce(pending map[string]Op, c Change) {
+	prev, exists := pending[c.Path]
+	if !exists {
+		pending[c.Path] = c.Op
+		return
+	}
+
+	switch {
+	case prev == Created && c.Op == Deleted:
+		// Short-lived file, nothing changed overall
+		delete(pending, c.Path)
+	case prev == Created:
+		// Still a new file however often it was written
+	case prev == Deleted && c.Op == Created:
+		pending[c.Path] = Modified
+	default:
+		pending[c.Path] = c.Op
+	}
+}
+
+// scan records the modification time and size of every file under the roots
+func (w *Watcher) scan() map[string]fileState {
+	files := make(map[string]fileState)
+
+	for _, root := range w.roots {
+		filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if w.excluded(path) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !info.IsDir() {
+				files[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+			}
+			return nil
+		})
+	}
+
+	return files
+}
+
+// excluded reports whether path is one of the excluded paths or below one
+func (w *Watcher) excluded(path string) bool {
+	for _, ex := range w.exclude {
+		if path == ex || strings.HasPrefix(path, ex+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// diff returns the changes between two scans
+func diff(before, after map[string]fileState) []Change {
+	var changes []Change
+	for path, state := range after {
+		prev, exists := before[path]
+		switch {
+		case !exists:
+			changes = append(changes, Change{Path: path, Op: Created})
+		case prev != state:
+			changes = append(changes, Change{Path: path, Op: Modified})
+		}
+	}
+	for path := range before {
+		if _, exists := after[path]; !exists {
+			changes = append(changes, Change{Path: path, Op: Deleted})
+		}
+	}
+	return changes
+}
+
+// debounce coalesces a burst of changes into a single trigger once no change has been seen for delay
+type debounce struct {
+	delay   time.Duration
+	pending bool
+	last    time.Time
+}
+
+// observe records whether a change was seen at now and reports whether the pending burst should fire
+func (d *debounce) observe(changed bool, now time.Time) bool {
+	if changed {
+		d.pending = true
+		d.last = now
+		return false
+	}
+
+	if d.pending && now.Sub(d.last) >= d.delay {
+		d.pending = false
+		return true
+	}
+	return false
+}
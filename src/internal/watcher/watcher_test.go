@@ -0,0 +1,137 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDebounce(t *testing.T) {
+	start := time.Now()
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+	d := debounce{delay: 100 * time.Millisecond}
+
+	steps := []struct {
+		ms      int
+		changed bool
+		fire    bool
+	}{
+		{0, false, false},   // nothing pending
+		{10, true, false},   // burst starts
+		{60, true, false},   // burst continues, restarting the quiet period
+		{150, false, false}, // quiet for 90ms only
+		{160, false, true},  // quiet for the full delay
+		{400, false, false}, // fired once per burst
+	}
+	for _, step := range steps {
+		if got := d.observe(step.changed, at(step.ms)); got != step.fire {
+			t.Errorf("at %dms: observe(%v) = %v, want %v", step.ms, step.changed, got, step.fire)
+		}
+	}
+}
+
+// watch runs a watcher on dir until the test ends, sending each batch it reports
+func watch(t *testing.T, dir string) <-chan []Change {
+	t.Helper()
+	w := New([]string{dir}, nil, 5*time.Millisecond, 50*time.Millisecond)
+	batches := make(chan []Change, 10)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.Run(stop, func(changes []Change) { batches <- changes })
+	}()
+	t.Cleanup(func() {
+		close(stop)
+		<-done
+	})
+	return batches
+}
+
+func TestRunTriggersOnce(t *testing.T) {
+	dir := t.TempDir()
+	batches := watch(t, dir)
+
+	for _, name := range []string{"a.html", "b.html"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case batch := <-batches:
+		want := []Change{{filepath.Join(dir, "a.html"), Created}, {filepath.Join(dir, "b.html"), Created}}
+		if len(batch) != len(want) || batch[0] != want[0] || batch[1] != want[1] {
+			t.Errorf("got batch %v, want %v", batch, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no batch reported for created files")
+	}
+
+	select {
+	case batch := <-batches:
+		t.Errorf("burst reported again: %v", batch)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestRunSkipsCancelledBurst(t *testing.T) {
+	dir := t.TempDir()
+	batches := watch(t, dir)
+
+	path := filepath.Join(dir, "tmp.swp")
+	if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond) // seen by a scan, within the burst
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case batch := <-batches:
+		t.Errorf("burst of a created and deleted file reported %v, want nothing", batch)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	tests := []struct {
+		name string
+		ops  []Op
+		want Op
+		gone bool // no net change is left
+	}{
+		{name: "created", ops: []Op{Created}, want: Created},
+		{name: "created then modified", ops: []Op{Created, Modified, Modified}, want: Created},
+		{name: "created then deleted", ops: []Op{Created, Modified, Deleted}, gone: true},
+		{name: "deleted then created", ops: []Op{Deleted, Created}, want: Modified},
+		{name: "modified then deleted", ops: []Op{Modified, Deleted}, want: Deleted},
+		{name: "deleted, created, deleted", ops: []Op{Deleted, Created, Deleted}, want: Deleted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pending := make(map[string]Op)
+			for _, op := range tt.ops {
+				coalesce(pending, Change{Path: "a.html", Op: op})
+			}
+			got, exists := pending["a.html"]
+			if exists == tt.gone || (exists && got != tt.want) {
+				t.Errorf("coalesced %v to %v (pending %v), want %v (gone %v)", tt.ops, got, exists, tt.want, tt.gone)
+			}
+		})
+	}
+}
+
+func TestCoalesceKeepsPathsApart(t *testing.T) {
+	pending := make(map[string]Op)
+	coalesce(pending, Change{Path: "a.html", Op: Created})
+	coalesce(pending, Change{Path: "b.html", Op: Deleted})
+	coalesce(pending, Change{Path: "a.html", Op: Modified})
+
+	if len(pending) != 2 || pending["a.html"] != Created || pending["b.html"] != Deleted {
+		t.Errorf("got %v, want a.html created and b.html deleted", pending)
+	}
+}
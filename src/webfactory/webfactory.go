@@ -0,0 +1,342 @@
+// Package webfactory builds static sites from blueprints and components
+// It is the supported entry point for running webfactory from other Go programs; the webfactory
+// command is a thin wrapper around it.
+package webfactory
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"webfactory/src/internal/assets"
+	"webfactory/src/internal/blueprint"
+	"webfactory/src/internal/builder"
+	"webfactory/src/internal/component"
+	"webfactory/src/internal/storage"
+	"webfactory/src/internal/template"
+)
+
+// Options configures a site build
+type Options struct {
+	Sources []string // Source directories in priority order, earlier ones override later ones
+	FS      fs.FS    // Source file system used instead of Sources, such as an embed.FS
+	Library string   // Shared component directory searched after the sources' components, with Sources only
+	Target  string   // Output directory
+	Sink    Sink     // Receives the output instead of Target when set
+
+	Generator       bool // Stamp a meta generator tag with the webfactory version into every page
+	HeadTags        bool // Generate title and meta tags from blueprint front matter
+	DedupeCSS       bool // Drop duplicate CSS rules from the merged stylesheet
+	CSSMap          bool // Write css/<page>.css.map mapping each page's stylesheet to component files, with PageCSS and not DedupeCSS
+	PageCSS         bool // Link each page to its own stylesheet, css/<page>.css, holding only its components' styles
+	FollowSymlinks  bool // Follow symbolic links within Sources and Library, refusing links out of them and loops
+	InlineThreshold int  // Embed CSS and JS assets smaller than this many bytes in the page, 0 disables
+	Integrity       bool // Add Subresource Integrity attributes to script tags, and with PageCSS stylesheet tags
+	Preload         bool // Emit preload hints for assets of components marked preload
+	Redirects       bool // Write pages whose HTML is identical to another page's as redirects to that page
+	FailOnWarning   bool // Fail Build with every template warning, such as an undefined variable, once pages are written
+	StrictVars      bool // Fail pages using a variable that is not set and has no default filter, instead of warning
+
+	// Options of the written output, used with Target only
+	Gzip        bool        // Also write a .gz copy of text outputs
+	Brotli      bool        // Also write a .br copy of text outputs
+	CompressMin int         // Skip pre-compressing files smaller than this many bytes
+	FileMode    os.FileMode // Permissions of output files, 0644 when zero
+	DirMode     os.FileMode // Permissions of created output directories, 0755 when zero
+	DryRun      bool        // Report the files that would be written in Result.Planned instead of writing them
+	Clean       bool        // After a successful build, remove files in Target it did not write, listed in Result.Removed
+
+	Feed      Feed
+	Filters   map[string]FilterFunc            // Custom template filters, used as {{.var | name arg}}
+	Env       []string                         // Environment variables templates may read as {{env.NAME}}
+	BasePath  string                           // URL path the site is served under, like /docs/; asset URLs are relative when empty
+	BaseURL   string                           // Absolute site URL, like https://example.com; root-relative links in pages are rewritten under it
+	ErrorPage string                           // Blueprint, relative to blueprints/, built as 404.html instead of 404.blueprint
+	SCSS      SCSSCompiler                     // Compiles component .scss files; without one SCSS is an error
+	CSS       []CSSTransform                   // Run over each page's merged stylesheet in order, such as an autoprefixer
+	Data      map[string]DataParser            // Parsers of .data files by extension such as ".yaml", besides the built-in JSON
+	Progress  func(format string, args ...any) // Receives per-blueprint and per-file progress, may be nil
+}
+
+// SCSSCompiler compiles a component's SCSS file to CSS, such as by calling a Sass library
+// The path is the file's location in the source, for resolving imports and for messages. Files whose
+// names start with an underscore are partials and are not compiled on their own.
+type SCSSCompiler func(path string, source []byte) ([]byte, error)
+
+// CSSTransform rewrites a page's merged stylesheet, after duplicate rules are dropped
+// Transforms run in the order given, each receiving the output of the one before; an error fails the page.
+type CSSTransform func(css []byte) ([]byte, error)
+
+// DataParser turns the content of a blueprint block's .data file into variables, each with its values
+// A returned error fails the page.
+type DataParser func(content []byte) (map[string][]string, error)
+
+// FilterFunc is a custom template filter, transforming a value with the arguments given in the template
+// A returned error fails the page, reported with the directive's template position.
+type FilterFunc func(value string, args ...string) (string, error)
+
+// Feed configures an RSS feed built from a collection of pages
+type Feed struct {
+	Collection  string // Blueprint directory whose pages become feed items, empty disables the feed
+	Path        string // Output path of the feed, feed.xml when empty
+	Title       string
+	Description string
+	BaseURL     string // Absolute site URL that item links are resolved against
+}
+
+// Sink receives the files of a generated site, by path relative to the site root
+type Sink interface {
+	Write(path string, content []byte) error
+}
+
+// PanicError is a panic raised while building one blueprint, recovered so the other pages still build
+// Build returns every recovered panic, each wrapped with its blueprint, once the other pages are written.
+type PanicError = builder.PanicError
+
+// Graph is the component structure of a site: which components pages use and how they nest
+// Its DOT method renders it for Graphviz.
+type Graph = builder.Graph
+
+// Result summarizes a completed build
+type Result struct {
+	Pages    int           // Blueprints built into pages
+	CSS      int           // Unique stylesheet files emitted
+	JS       int           // Unique script files emitted
+	Bytes    int           // Total size of the emitted pages and assets, without compressed copies
+	Duration time.Duration // Wall-clock time of the build
+	Warnings []string      // Template warnings, such as undefined variables, prefixed with their blueprint
+	Skipped  int           // Output files, compressed copies included, left untouched as their content was unchanged
+	Planned  []PlannedWrite
+	Removed  []string // Files in Target removed by Clean, or that it would remove in a dry run
+}
+
+// PlannedWrite is a file a dry run would have written
+type PlannedWrite struct {
+	Path      string
+	Bytes     int
+	Overwrite bool // the file already exists on disk
+}
+
+// LintReport lists the problems found by Lint
+type LintReport struct {
+	Errors   []string
+	Warnings []string
+}
+
+// OK reports whether lint found no errors
+func (r *LintReport) OK() bool {
+	return len(r.Errors) == 0
+}
+
+// Site is a configured site that can be built repeatedly, as watch mode does
+type Site struct {
+	builder *builder.Builder
+	store   *storage.Storage // nil when writing to a caller's Sink
+	clean   bool
+}
+
+// New validates opts and creates a Site
+func New(opts Options) (*Site, error) {
+	var source storage.Source
+	switch {
+	case opts.FS != nil:
+		source = storage.NewFS(opts.FS)
+	case len(opts.Sources) > 0:
+		source = storage.NewDisk(opts.Sources, opts.Library, storage.DiskOptions{FollowSymlinks: opts.FollowSymlinks})
+	default:
+		return nil, fmt.Errorf("no source: set Sources or FS")
+	}
+
+	if opts.BaseURL != "" {
+		if u, err := url.Parse(opts.BaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("base URL %q is not an absolute URL like https://example.com", opts.BaseURL)
+		}
+	}
+
+	if opts.CSSMap && opts.DedupeCSS {
+		return nil, fmt.Errorf("a CSS source map can't be combined with dropping duplicate CSS rules")
+	}
+	if opts.CSSMap && !opts.PageCSS {
+		return nil, fmt.Errorf("a CSS source map needs PageCSS, each page writing its own stylesheet")
+	}
+
+	site := &Site{}
+	sink := storage.Sink(opts.Sink)
+	if opts.Sink == nil {
+		if opts.Target == "" {
+			return nil, fmt.Errorf("no target: set Target or Sink")
+		}
+		output := storage.Options{
+			Gzip:        opts.Gzip,
+			Brotli:      opts.Brotli,
+			CompressMin: opts.CompressMin,
+			FileMode:    opts.FileMode,
+			DirMode:     opts.DirMode,
+			DryRun:      opts.DryRun,
+		}
+		if err := output.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid output options: %w", err)
+		}
+		site.store = storage.New(opts.Target, output)
+		sink = site.store
+	}
+	if opts.Clean {
+		if err := checkClean(opts); err != nil {
+			return nil, err
+		}
+		site.clean = true
+	}
+
+	filters := make(map[string]template.FilterFunc, len(opts.Filters))
+	for name, fn := range opts.Filters {
+		filters[name] = template.FilterFunc(fn)
+	}
+	if err := template.CheckFilters(filters); err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]blueprint.DataParser, len(opts.Data))
+	for ext, fn := range opts.Data {
+		if !strings.HasPrefix(ext, ".") || fn == nil {
+			return nil, fmt.Errorf("invalid data parser for %q, expected an extension like .yaml and a function", ext)
+		}
+		data[strings.ToLower(ext)] = blueprint.DataParser(fn)
+	}
+
+	transforms := make([]assets.CSSTransform, 0, len(opts.CSS))
+	for i, fn := range opts.CSS {
+		if fn == nil {
+			return nil, fmt.Errorf("CSS transform %d has no function", i+1)
+		}
+		transforms = append(transforms, assets.CSSTransform(fn))
+	}
+
+	site.builder = builder.New(source, sink, builder.Options{
+		Generator: opts.Generator,
+		HeadTags:  opts.HeadTags,
+		Assets: assets.Options{
+			DedupeRules:     opts.DedupeCSS,
+			SourceMap:       opts.CSSMap,
+			InlineThreshold: opts.InlineThreshold,
+			Integrity:       opts.Integrity,
+			Preload:         opts.Preload,
+			CSSTransforms:   transforms,
+		},
+		Progress:      opts.Progress,
+		Feed:          builder.FeedOptions(opts.Feed),
+		Filters:       filters,
+		Env:           opts.Env,
+		BasePath:      opts.BasePath,
+		BaseURL:       opts.BaseURL,
+		SCSS:          component.SCSSCompiler(opts.SCSS),
+		Data:          data,
+		Redirects:     opts.Redirects,
+		ErrorPage:     opts.ErrorPage,
+		FailOnWarning: opts.FailOnWarning,
+		StrictVars:    opts.StrictVars,
+		PageCSS:       opts.PageCSS,
+	})
+
+	return site, nil
+}
+
+// checkClean refuses Clean without a Target, or with a Target that holds sources it would remove
+func checkClean(opts Options) error {
+	if opts.Target == "" {
+		return fmt.Errorf("clean needs a Target to remove files from")
+	}
+	target, err := filepath.Abs(opts.Target)
+	if err != nil {
+		return err
+	}
+	for _, dir := range append(slices.Clone(opts.Sources), opts.Library) {
+		if dir == "" {
+			continue
+		}
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		if rel, err := filepath.Rel(target, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("clean would remove %s, which is inside the target %s", dir, opts.Target)
+		}
+	}
+	return nil
+}
+
+// Build builds the site once with opts
+func Build(opts Options) (Result, error) {
+	return BuildContext(context.Background(), opts)
+}
+
+// BuildContext builds the site once with opts, stopping early when ctx is cancelled
+func BuildContext(ctx context.Context, opts Options) (Result, error) {
+	site, err := New(opts)
+	if err != nil {
+		return Result{}, err
+	}
+	return site.Build(ctx)
+}
+
+// Build processes all blueprints and writes the site, returning what it emitted
+// Cancelling ctx stops the build between blueprints with the context's error.
+func (s *Site) Build(ctx context.Context) (Result, error) {
+	if s.store != nil {
+		s.store.Reset()
+	}
+
+	stats, err := s.builder.Build(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{
+		Pages:    stats.Pages,
+		CSS:      stats.CSS,
+		JS:       stats.JS,
+		Bytes:    stats.Bytes,
+		Duration: stats.Duration,
+		Warnings: stats.Warnings,
+	}
+	if s.store != nil {
+		result.Skipped = s.store.Skipped()
+		for _, w := range s.store.Planned() {
+			result.Planned = append(result.Planned, PlannedWrite(w))
+		}
+	}
+	// Only a complete build knows every file that belongs in the target
+	if s.clean {
+		removed, err := s.store.Clean()
+		if err != nil {
+			return Result{}, fmt.Errorf("cleaning target: %w", err)
+		}
+		result.Removed = removed
+	}
+	return result, nil
+}
+
+// Graph reads every blueprint and the components it uses, without rendering or writing pages
+func (s *Site) Graph(ctx context.Context) (*Graph, error) {
+	return s.builder.Graph(ctx)
+}
+
+// Inspect parses the blueprint at path, relative to the blueprints directory, and returns its block tree
+// as indented text: front matter, then each block's index, component path, and variables
+func (s *Site) Inspect(path string) (string, error) {
+	return s.builder.Dump(path)
+}
+
+// Lint checks every blueprint and component the way Build does, without writing output
+func (s *Site) Lint(ctx context.Context) (*LintReport, error) {
+	report, err := s.builder.Lint(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &LintReport{Errors: report.Errors, Warnings: report.Warnings}, nil
+}
\ No newline at end of file
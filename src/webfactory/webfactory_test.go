@@ -0,0 +1,239 @@
+package webfactory_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"webfactory/src/webfactory"
+)
+
+// site is a small site by slash-separated path below the source root
+var site = map[string]string{
+	"globals.vars":                  ".site = Demo\n",
+	"blueprints/index.blueprint":    "1 layout\n1.1 card\n  .title=Home\n",
+	"blueprints/about.blueprint":    "1 layout\n1.1 card\n  .title=About\n",
+	"components/layout/layout.html": "<title>{{.site}}</title>{{styles}}{{component}}",
+	"components/card/card.html":     "<div class=\"card\">{{.title}}</div>",
+	"components/card/card.css":      ".card { padding: 1rem; }",
+}
+
+// mapSink is a Sink collecting the output in a map
+type mapSink map[string]string
+
+func (s mapSink) Write(path string, content []byte) error {
+	s[filepath.ToSlash(path)] = string(content)
+	return nil
+}
+
+func TestBuildDirectory(t *testing.T) {
+	source, target := t.TempDir(), t.TempDir()
+	for name, content := range site {
+		path := filepath.Join(source, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := webfactory.Build(webfactory.Options{Sources: []string{source}, Target: target})
+	if err != nil {
+		t.Fatalf("building: %v", err)
+	}
+	if result.Pages != 2 || result.CSS != 1 || result.Bytes == 0 {
+		t.Errorf("got result %+v, want 2 pages and 1 stylesheet", result)
+	}
+
+	index, err := os.ReadFile(filepath.Join(target, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<title>Demo</title><link rel="stylesheet" href="css/styles.css"><div class="card">Home</div>`
+	if string(index) != want {
+		t.Errorf("index.html = %q, want %q", index, want)
+	}
+	if _, err := os.Stat(filepath.Join(target, "css", "styles.css")); err != nil {
+		t.Errorf("stylesheet not written: %v", err)
+	}
+
+	// Building again leaves the unchanged files alone, the shared stylesheet once for each page
+	result, err = webfactory.Build(webfactory.Options{Sources: []string{source}, Target: target})
+	if err != nil || result.Skipped != 4 {
+		t.Errorf("rebuild skipped %d writes (error %v), want all 4", result.Skipped, err)
+	}
+}
+
+func TestBuildFSToSink(t *testing.T) {
+	fsys := make(fstest.MapFS, len(site))
+	for name, content := range site {
+		fsys[name] = &fstest.MapFile{Data: []byte(content)}
+	}
+	sink := make(mapSink)
+
+	site, err := webfactory.New(webfactory.Options{FS: fsys, Sink: sink, HeadTags: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if _, err := site.Build(context.Background()); err != nil {
+			t.Fatalf("building: %v", err)
+		}
+	}
+
+	var paths []string
+	for path := range sink {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	if want := []string{"about.html", "css/styles.css", "index.html"}; !slices.Equal(paths, want) {
+		t.Errorf("sink received %q, want %q", paths, want)
+	}
+	if !strings.Contains(sink["about.html"], `<div class="card">About</div>`) {
+		t.Errorf("about.html = %q", sink["about.html"])
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	fsys := fstest.MapFS{
+		"blueprints/index.blueprint": {Data: []byte("1 card\n  .title=Home\n")},
+		"components/card/card.html":  {Data: []byte("<p>{{.title}}</p>")},
+	}
+	target := t.TempDir()
+
+	result, err := webfactory.Build(webfactory.Options{FS: fsys, Target: target, DryRun: true})
+	if err != nil {
+		t.Fatalf("building: %v", err)
+	}
+	if len(result.Planned) != 1 || result.Planned[0].Path != filepath.Join(target, "index.html") || result.Planned[0].Bytes != len("<p>Home</p>") {
+		t.Errorf("planned %+v, want index.html", result.Planned)
+	}
+	if entries, _ := os.ReadDir(target); len(entries) != 0 {
+		t.Errorf("dry run wrote %d files", len(entries))
+	}
+}
+
+func TestClean(t *testing.T) {
+	fsys := fstest.MapFS{}
+	for name, content := range site {
+		fsys[name] = &fstest.MapFile{Data: []byte(content)}
+	}
+	target := t.TempDir()
+	for _, name := range []string{"old.html", "blog/gone.html"} {
+		path := filepath.Join(target, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("<p>stale</p>"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := webfactory.Build(webfactory.Options{FS: fsys, Target: target, Clean: true})
+	if err != nil {
+		t.Fatalf("building: %v", err)
+	}
+	if want := []string{filepath.Join(target, "blog", "gone.html"), filepath.Join(target, "old.html")}; !slices.Equal(result.Removed, want) {
+		t.Errorf("removed %q, want %q", result.Removed, want)
+	}
+	var left []string
+	filepath.WalkDir(target, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(target, path)
+			left = append(left, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	if want := []string{"about.html", "css/styles.css", "index.html"}; !slices.Equal(left, want) {
+		t.Errorf("target holds %q, want only the built files %q", left, want)
+	}
+}
+
+func TestNewInvalidOptions(t *testing.T) {
+	fsys := fstest.MapFS{}
+	tests := []struct {
+		name string
+		opts webfactory.Options
+		err  string
+	}{
+		{"no source", webfactory.Options{Target: "out"}, "no source: set Sources or FS"},
+		{"no target", webfactory.Options{FS: fsys}, "no target: set Target or Sink"},
+		{"relative base URL", webfactory.Options{FS: fsys, Target: "out", BaseURL: "/docs"}, `base URL "/docs" is not an absolute URL`},
+		{"source map with dedupe", webfactory.Options{FS: fsys, Target: "out", CSSMap: true, DedupeCSS: true}, "can't be combined"},
+		{"source map without page CSS", webfactory.Options{FS: fsys, Target: "out", CSSMap: true}, "CSS source map needs PageCSS"},
+		{"data parser without dot", webfactory.Options{FS: fsys, Target: "out", Data: map[string]webfactory.DataParser{"yaml": nil}}, `invalid data parser for "yaml"`},
+		{"filter shadowing a built-in", webfactory.Options{FS: fsys, Target: "out", Filters: map[string]webfactory.FilterFunc{"upper": shout}}, "filter upper collides with a built-in filter"},
+		{"clean without a target", webfactory.Options{FS: fsys, Sink: mapSink{}, Clean: true}, "clean needs a Target"},
+		{"clean over the sources", webfactory.Options{Sources: []string{"out/site"}, Target: "out", Clean: true}, "clean would remove"},
+		{"nil CSS transform", webfactory.Options{FS: fsys, Target: "out", CSS: []webfactory.CSSTransform{nil}}, "CSS transform 1 has no function"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := webfactory.New(tt.opts); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+// shout is a custom filter uppercasing a value, failing on an empty one
+func shout(value string, args ...string) (string, error) {
+	if value == "" {
+		return "", fmt.Errorf("nothing to shout")
+	}
+	return strings.ToUpper(value) + "!", nil
+}
+
+func TestCustomFilter(t *testing.T) {
+	build := func(title string) (mapSink, error) {
+		fsys := fstest.MapFS{
+			"blueprints/index.blueprint": {Data: []byte("1 card\n  .title=" + title + "\n")},
+			"components/card/card.html":  {Data: []byte("<p>{{.title | shout}}</p>")},
+		}
+		sink := make(mapSink)
+		_, err := webfactory.Build(webfactory.Options{FS: fsys, Sink: sink, Filters: map[string]webfactory.FilterFunc{"shout": shout}})
+		return sink, err
+	}
+
+	sink, err := build("hi")
+	if err != nil {
+		t.Fatalf("building: %v", err)
+	}
+	if got := sink["index.html"]; got != "<p>HI!</p>" {
+		t.Errorf("index.html = %q", got)
+	}
+
+	if _, err := build(""); err == nil || !strings.Contains(err.Error(), "card line 1:4 [.title]: filter shout: nothing to shout") {
+		t.Errorf("got error %v, want the failing filter", err)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	fsys := fstest.MapFS{
+		"blueprints/blog/post.blueprint": {Data: []byte("---\ntitle = Post\n---\n@alias card = ui.card\n1 layout\n1.1 card\n  .title=Hi\n2 @include footer.blueprint\n")},
+		"blueprints/footer.blueprint":    {Data: []byte("1 footer\n")},
+	}
+	site, err := webfactory.New(webfactory.Options{FS: fsys, Sink: make(mapSink)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := site.Inspect("blog/post.blueprint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "---\ntitle = Post\n---\n1 layout\n  1.1 ui.card\n    .title = Hi\n2 footer\n"; got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	if _, err := site.Inspect("missing.blueprint"); err == nil || !strings.HasPrefix(err.Error(), "parsing blueprint missing.blueprint: ") {
+		t.Errorf("got error %v, want the missing blueprint reported", err)
+	}
+}
\ No newline at end of file